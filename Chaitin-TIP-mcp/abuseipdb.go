@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const abuseIPDBBaseURL = "https://api.abuseipdb.com"
+
+// abuseIPDBProvider queries the AbuseIPDB v2 check endpoint. AbuseIPDB only covers IP addresses.
+type abuseIPDBProvider struct {
+	api *apiProvider
+}
+
+func newAbuseIPDBProvider(config *ProviderConfig) (Provider, error) {
+	api, err := newAPIProvider(providerAbuseIPDB, abuseIPDBBaseURL, config)
+	if err != nil {
+		return nil, err
+	}
+	return &abuseIPDBProvider{api: api}, nil
+}
+
+func (p *abuseIPDBProvider) Name() string {
+	return providerAbuseIPDB
+}
+
+// abuseIPDBCheck is the response of /api/v2/check
+type abuseIPDBCheck struct {
+	Data struct {
+		IPAddress            string `json:"ipAddress"`
+		AbuseConfidenceScore int    `json:"abuseConfidenceScore"`
+		CountryCode          string `json:"countryCode"`
+		UsageType            string `json:"usageType"`
+		ISP                  string `json:"isp"`
+		Domain               string `json:"domain"`
+		IsWhitelisted        *bool  `json:"isWhitelisted"`
+		IsTor                bool   `json:"isTor"`
+		TotalReports         int    `json:"totalReports"`
+		NumDistinctUsers     int    `json:"numDistinctUsers"`
+		LastReportedAt       string `json:"lastReportedAt"`
+	} `json:"data"`
+}
+
+func (p *abuseIPDBProvider) LookupIP(ctx context.Context, ip string) (*ProviderResult, error) {
+	query := url.Values{}
+	query.Set("ipAddress", ip)
+	query.Set("maxAgeInDays", "90")
+	header := http.Header{}
+	header.Set("Key", p.api.key)
+
+	var check abuseIPDBCheck
+	if err := p.api.get(ctx, indicatorIP, "/api/v2/check", query, header, &check); err != nil {
+		return nil, err
+	}
+	data := check.Data
+
+	score := data.AbuseConfidenceScore
+	result := &ProviderResult{
+		Provider: providerAbuseIPDB,
+		Verdict:  scoreVerdict(score),
+		Score:    &score,
+		Link:     "https://www.abuseipdb.com/check/" + url.PathEscape(ip),
+		Data: map[string]any{
+			"country_code":   data.CountryCode,
+			"usage_type":     data.UsageType,
+			"isp":            data.ISP,
+			"domain":         data.Domain,
+			"total_reports":  data.TotalReports,
+			"distinct_users": data.NumDistinctUsers,
+			"last_reported":  data.LastReportedAt,
+		},
+	}
+	if data.UsageType != "" {
+		result.Tags = append(result.Tags, data.UsageType)
+	}
+	if data.IsTor {
+		result.Tags = append(result.Tags, "tor")
+	}
+	if data.IsWhitelisted != nil && *data.IsWhitelisted {
+		result.Tags = append(result.Tags, "whitelisted")
+	}
+	return result, nil
+}
+
+func (p *abuseIPDBProvider) LookupDomain(ctx context.Context, domain string) (*ProviderResult, error) {
+	return nil, errUnsupported
+}
+
+func (p *abuseIPDBProvider) LookupHash(ctx context.Context, hash string) (*ProviderResult, error) {
+	return nil, errUnsupported
+}
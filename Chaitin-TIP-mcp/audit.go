@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Arguments whose names contain one of these words are never written to the audit log
+var sensitiveArgs = []string{"password", "secret", "token", "key", "authorization", "cookie"}
+
+// AuditConfig configures the tool call audit log
+type AuditConfig struct {
+	Path      string `json:"path"`                 // JSON Lines file, only ever appended to
+	HashChain bool   `json:"hash_chain,omitempty"` // Hash every entry together with the previous entry's hash to make tampering evident
+}
+
+// auditEntry is one line of the audit log
+type auditEntry struct {
+	Seq        uint64                 `json:"seq"`
+	Time       time.Time              `json:"time"`
+	User       string                 `json:"user,omitempty"`    // OS user running the server
+	Session    string                 `json:"session,omitempty"` // MCP session ID
+	Client     string                 `json:"client,omitempty"`  // Client name and version from the initialize request
+	Tool       string                 `json:"tool"`
+	Arguments  map[string]interface{} `json:"arguments,omitempty"` // Sanitized arguments
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+	PrevHash   string                 `json:"prev_hash,omitempty"` // Hash of the previous entry when hash chaining is enabled
+	ChainEnd   bool                   `json:"chain_end,omitempty"` // Last chained entry, written when hash chaining is switched off
+}
+
+// auditLogger appends tool calls to the audit log
+type auditLogger struct {
+	mu        sync.Mutex
+	file      *os.File
+	hashChain bool
+	seq       uint64
+	lastHash  string
+	user      string
+	client    string // The stdio transport serves a single client
+}
+
+// auditLog is the process-wide audit log; nil when auditing is disabled
+var auditLog *auditLogger
+
+// openAuditLog opens the audit log for appending, continuing the sequence
+// number and hash chain from the last entry already in the file. When the
+// existing log is chained but hash chaining is now off, a chained chain_end
+// entry is written first so verification can tell a disabled chain from
+// stripped hashes.
+func openAuditLog(config *AuditConfig) (*auditLogger, error) {
+	seq, lastHash, err := auditTail(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(config.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+
+	logger := &auditLogger{
+		file:      file,
+		hashChain: config.HashChain,
+		seq:       seq,
+		lastHash:  lastHash,
+	}
+	if u, err := user.Current(); err == nil {
+		logger.user = u.Username
+	}
+
+	if !config.HashChain && lastHash != "" {
+		logger.hashChain = true
+		err := logger.write(&auditEntry{
+			Time:      time.Now().UTC(),
+			Tool:      "audit",
+			Arguments: map[string]interface{}{"hash_chain": false},
+			Status:    statusOK,
+			ChainEnd:  true,
+		})
+		logger.hashChain = false
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return logger, nil
+}
+
+// Close closes the audit log
+func (l *auditLogger) Close() error {
+	return l.file.Close()
+}
+
+// write appends an entry, filling in the sequence number and hash chain
+func (l *auditLogger) write(entry *auditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Seq = l.seq + 1
+	entry.User = l.user
+	entry.Client = l.client
+	if l.hashChain {
+		entry.PrevHash = l.lastHash
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %v", err)
+	}
+
+	// The hash covers the whole entry without the hash field, which is
+	// appended last so each line can be verified on its own
+	var hash string
+	if l.hashChain {
+		hash = auditHash(data)
+		data = append(data[:len(data)-1], fmt.Sprintf(`,"hash":%q}`, hash)...)
+	}
+	data = append(data, '\n')
+
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	l.seq = entry.Seq
+	l.lastHash = hash
+	return nil
+}
+
+// trackClient records the client name and version from the initialize request
+func (l *auditLogger) trackClient(hooks *server.Hooks) {
+	hooks.AddAfterInitialize(func(id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+		info := message.Params.ClientInfo
+		l.mu.Lock()
+		l.client = strings.TrimSpace(info.Name + " " + info.Version)
+		l.mu.Unlock()
+	})
+}
+
+// auditTool records every call of the wrapped handler. It returns the
+// handler unchanged when auditing is disabled.
+func auditTool(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if auditLog == nil {
+		return handler
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := handler(ctx, request)
+
+		entry := &auditEntry{
+			Time:       start.UTC(),
+			Tool:       request.Params.Name,
+			Arguments:  sanitizeArgs(request.Params.Arguments),
+			Status:     statusOK,
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			entry.Session = session.SessionID()
+		}
+		switch {
+		case err != nil:
+			entry.Status = statusError
+			entry.Error = err.Error()
+		case result != nil && result.IsError:
+			entry.Status = statusError
+			for _, content := range result.Content {
+				if text, ok := content.(mcp.TextContent); ok {
+					entry.Error = text.Text
+					break
+				}
+			}
+		}
+
+		if writeErr := auditLog.write(entry); writeErr != nil {
+			log.Printf("Failed to write audit log: %v", writeErr)
+		}
+		return result, err
+	}
+}
+
+// sanitizeArgs copies the arguments, replacing sensitive values
+func sanitizeArgs(args map[string]interface{}) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	clean := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		lower := strings.ToLower(k)
+		sensitive := false
+		for _, s := range sensitiveArgs {
+			if strings.Contains(lower, s) {
+				sensitive = true
+				break
+			}
+		}
+		if sensitive {
+			clean[k] = "[REDACTED]"
+		} else {
+			clean[k] = v
+		}
+	}
+	return clean
+}
+
+// auditHash returns the hex SHA-256 of an entry
+func auditHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// chainedAuditEntry holds the fields needed to follow the hash chain
+type chainedAuditEntry struct {
+	Seq      uint64 `json:"seq"`
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+	ChainEnd bool   `json:"chain_end"`
+}
+
+// auditTail returns the sequence number and hash of the last entry, or zero values for a new log
+func auditTail(path string) (uint64, string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var last chainedAuditEntry
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			last = chainedAuditEntry{}
+			if jsonErr := json.Unmarshal(line, &last); jsonErr != nil {
+				return 0, "", fmt.Errorf("failed to parse audit log: %v", jsonErr)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to read audit log: %v", err)
+		}
+	}
+	// A chain that was switched off starts afresh when it is switched back on
+	if last.ChainEnd {
+		return last.Seq, "", nil
+	}
+	return last.Seq, last.Hash, nil
+}
+
+// verifyAuditLog checks the hash chain and returns the number of entries verified.
+// Entries written before hash chaining was enabled, or after a chain_end entry,
+// have no hash and are accepted. An unchained entry in the middle of a chain is
+// rejected, otherwise an attacker could strip the hash from an entry and edit it.
+// A chained entry after an unchained section starts a new chain. With
+// requireChain every entry must be chained.
+func verifyAuditLog(r io.Reader, requireChain bool) (int, error) {
+	const prefix = `,"hash":"`
+	const suffixLen = len(prefix) + sha256.Size*2 + len(`"}`)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	count := 0
+	prevHash := ""
+	chained := false // whether the next entry must continue a chain
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var entry chainedAuditEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return count, fmt.Errorf("line %d: invalid json: %v", line, err)
+		}
+		if entry.Hash == "" {
+			if requireChain || chained {
+				return count, fmt.Errorf("line %d (seq %d): entry is not chained", line, entry.Seq)
+			}
+			count++
+			continue
+		}
+
+		if len(data) < suffixLen || !bytes.HasPrefix(data[len(data)-suffixLen:], []byte(prefix)) ||
+			string(data[len(data)-suffixLen+len(prefix):len(data)-2]) != entry.Hash {
+			return count, fmt.Errorf("line %d (seq %d): malformed hash field", line, entry.Seq)
+		}
+		if !chained {
+			prevHash = ""
+		}
+		if entry.PrevHash != prevHash {
+			return count, fmt.Errorf("line %d (seq %d): chain broken, prev_hash does not match previous entry", line, entry.Seq)
+		}
+		body := append(append([]byte{}, data[:len(data)-suffixLen]...), '}')
+		if auditHash(body) != entry.Hash {
+			return count, fmt.Errorf("line %d (seq %d): hash mismatch, entry was modified", line, entry.Seq)
+		}
+		prevHash = entry.Hash
+		chained = !entry.ChainEnd
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	return count, nil
+}
+
+// runAuditVerify implements "audit verify [-file path] [-require-chain]"
+func runAuditVerify(config *Config, args []string) error {
+	verifyFlags := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	file := verifyFlags.String("file", "", "Audit log to verify (defaults to audit.path from the config)")
+	requireChain := verifyFlags.Bool("require-chain", false, "Require every entry to be chained, including entries from before hash_chain was enabled or after it was disabled; "+
+		"defaults to true when audit.hash_chain is set in the config")
+	if err := verifyFlags.Parse(args); err != nil {
+		return err
+	}
+
+	// With hash_chain enabled an unchained entry may have had its hash
+	// stripped, so the whole log must be chained unless the flag says otherwise
+	explicitRequire := false
+	verifyFlags.Visit(func(f *flag.Flag) {
+		if f.Name == "require-chain" {
+			explicitRequire = true
+		}
+	})
+	chainFromConfig := false
+	if config != nil && config.Audit != nil && config.Audit.HashChain && !explicitRequire {
+		*requireChain = true
+		chainFromConfig = true
+	}
+
+	path := *file
+	if path == "" {
+		if config == nil || config.Audit == nil {
+			return fmt.Errorf("audit is not configured, use -file to specify the audit log")
+		}
+		path = config.Audit.Path
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	count, err := verifyAuditLog(f, *requireChain)
+	if err != nil && chainFromConfig {
+		return fmt.Errorf("audit log verification failed after %d entries: %v\n"+
+			"audit.hash_chain is enabled, so every entry must be chained; use -require-chain=false "+
+			"if the log has entries from before hash_chain was enabled", count, err)
+	}
+	if err != nil {
+		return fmt.Errorf("audit log verification failed after %d entries: %v", count, err)
+	}
+	fmt.Printf("Audit log is intact, %d entries verified\n", count)
+	return nil
+}
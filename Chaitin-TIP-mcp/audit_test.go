@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAuditEntries opens the log with the given hash_chain setting and appends n entries
+func writeAuditEntries(t *testing.T, path string, hashChain bool, n int) {
+	t.Helper()
+	logger, err := openAuditLog(&AuditConfig{Path: path, HashChain: hashChain})
+	if err != nil {
+		t.Fatalf("openAuditLog: %v", err)
+	}
+	defer logger.Close()
+	for i := 0; i < n; i++ {
+		if err := logger.write(&auditEntry{Time: time.Now().UTC(), Tool: "ip_lookup", Status: statusOK}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func verifyAuditFile(t *testing.T, path string, requireChain bool) (int, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return verifyAuditLog(f, requireChain)
+}
+
+func TestVerifyAuditLogHashChainToggle(t *testing.T) {
+	tests := []struct {
+		name   string
+		phases []bool // hash_chain setting each time the log is opened
+		count  int    // entries verified, including the chain_end entry
+		strict bool   // whether -require-chain passes
+	}{
+		{name: "always chained", phases: []bool{true, true}, count: 4, strict: true},
+		{name: "enabled later", phases: []bool{false, true}, count: 4},
+		{name: "disabled later", phases: []bool{true, false}, count: 5},
+		{name: "disabled then enabled", phases: []bool{true, false, true}, count: 7},
+		{name: "never chained", phases: []bool{false, false}, count: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			for _, hashChain := range tt.phases {
+				writeAuditEntries(t, path, hashChain, 2)
+			}
+
+			count, err := verifyAuditFile(t, path, false)
+			if err != nil {
+				t.Fatalf("verifyAuditLog: %v", err)
+			}
+			if count != tt.count {
+				t.Errorf("verified %d entries, want %d", count, tt.count)
+			}
+
+			_, err = verifyAuditFile(t, path, true)
+			if tt.strict && err != nil {
+				t.Errorf("verifyAuditLog with requireChain: %v", err)
+			}
+			if !tt.strict && err == nil {
+				t.Errorf("verifyAuditLog with requireChain succeeded, want an unchained entry error")
+			}
+		})
+	}
+}
+
+// An entry whose hash was stripped in the middle of a chain must fail verification
+func TestVerifyAuditLogStrippedHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeAuditEntries(t, path, true, 3)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	i := bytes.LastIndex(lines[1], []byte(`,"hash":`))
+	lines[1] = append(bytes.Replace(lines[1][:i], []byte(`"ip_lookup"`), []byte(`"domain_lookup"`), 1), '}')
+	if err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = verifyAuditFile(t, path, false)
+	if err == nil || !strings.Contains(err.Error(), "not chained") {
+		t.Fatalf("verifyAuditLog error = %v, want not chained", err)
+	}
+}
+
+// A log with every hash stripped passes a plain verify, so audit verify
+// requires the chain when the config has hash_chain enabled
+func TestRunAuditVerifyHashChainConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeAuditEntries(t, path, false, 2)
+
+	tests := []struct {
+		name      string
+		hashChain bool
+		args      []string
+		wantErr   bool
+	}{
+		{"hash_chain off", false, nil, false},
+		{"hash_chain on", true, nil, true},
+		{"hash_chain on, -require-chain=false", true, []string{"-require-chain=false"}, false},
+		{"hash_chain off, -require-chain", false, []string{"-require-chain"}, true},
+	}
+	for _, tt := range tests {
+		config := &Config{Audit: &AuditConfig{Path: path, HashChain: tt.hashChain}}
+		err := runAuditVerify(config, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: runAuditVerify error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "not chained") {
+			t.Errorf("%s: runAuditVerify error = %v, want not chained", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/time/rate"
+)
+
+// Batch lookup defaults
+const (
+	defaultBatchConcurrency = 5
+	defaultBatchRate        = 10 // requests per second
+	defaultBatchMaxIPs      = 500
+)
+
+// Verdicts derived from a lookup result
+const (
+	verdictMalicious  = "malicious"
+	verdictSuspicious = "suspicious"
+	verdictClean      = "clean"
+	verdictUnknown    = "unknown"
+)
+
+// BatchConfig limits how hard batch lookups hit the Chaitin API
+type BatchConfig struct {
+	Concurrency int     `json:"concurrency,omitempty"` // Parallel requests, defaults to 5
+	Rate        float64 `json:"rate,omitempty"`        // Requests per second, defaults to 10
+	MaxIPs      int     `json:"max_ips,omitempty"`     // Maximum unique IPs per call, defaults to 500
+}
+
+// batchResult is the outcome for one IP. Failures are reported per IP
+// instead of failing the whole call.
+type batchResult struct {
+	IP        string             `json:"ip"`
+	Verdict   string             `json:"verdict,omitempty"`
+	Result    *ChaitinIPResponse `json:"result,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	Watchlist []WatchEntry       `json:"watchlist,omitempty"` // Matching local watchlist entries
+}
+
+// batchSummary counts results by verdict
+type batchSummary struct {
+	Total      int `json:"total"`
+	Malicious  int `json:"malicious"`
+	Suspicious int `json:"suspicious"`
+	Clean      int `json:"clean"`
+	Unknown    int `json:"unknown"`
+	Failed     int `json:"failed"`
+	Watchlist  int `json:"watchlist"` // Indicators matching the local watchlist
+}
+
+// batchResponse is returned by the batch_ip_lookup tool
+type batchResponse struct {
+	Summary batchSummary  `json:"summary"`
+	Results []batchResult `json:"results"`
+	Invalid []string      `json:"invalid,omitempty"` // Input entries that are not IP addresses
+	Skipped int           `json:"skipped,omitempty"` // Unique IPs beyond max_ips that were not looked up
+}
+
+// batchLimits bounds the requests made by tools that look up many indicators
+type batchLimits struct {
+	concurrency int
+	limiter     *rate.Limiter
+	maxItems    int
+}
+
+// newBatchLimits applies defaults to the batch config. One limiter is shared
+// by all batch tools so parallel calls stay within the same request budget.
+func newBatchLimits(config *BatchConfig) *batchLimits {
+	if config == nil {
+		config = &BatchConfig{}
+	}
+	limits := &batchLimits{
+		concurrency: config.Concurrency,
+		maxItems:    config.MaxIPs,
+	}
+	if limits.concurrency <= 0 {
+		limits.concurrency = defaultBatchConcurrency
+	}
+	if limits.maxItems <= 0 {
+		limits.maxItems = defaultBatchMaxIPs
+	}
+	limit := config.Rate
+	if limit <= 0 {
+		limit = defaultBatchRate
+	}
+	limits.limiter = rate.NewLimiter(rate.Limit(limit), limits.concurrency)
+	return limits
+}
+
+// run calls fn for indexes 0..n-1 with bounded concurrency, waiting on the
+// rate limiter before each call. It returns once every call has finished.
+func (l *batchLimits) run(ctx context.Context, n int, fn func(i int) error, onError func(i int, err error)) {
+	sem := make(chan struct{}, l.concurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := l.limiter.Wait(ctx); err != nil {
+				onError(i, err)
+				return
+			}
+			if err := fn(i); err != nil {
+				onError(i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// registerBatchTool adds the batch_ip_lookup tool
+func registerBatchTool(s *server.MCPServer, client *Client, limits *batchLimits, watch *watchlist) {
+	batchTool := mcp.NewTool("batch_ip_lookup",
+		mcp.WithDescription("Look up many IP addresses at once using Chaitin Threat Intelligence. "+
+			"Accepts a list of IPs and/or free text such as firewall logs; IPs are extracted and deduplicated. "+
+			"Returns per-IP results and a summary of malicious, suspicious and clean counts."),
+		mcp.WithArray("ips",
+			mcp.Description("IP addresses to look up"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithString("text",
+			mcp.Description("Free text to extract IP addresses from, e.g. pasted log lines"),
+		),
+		mcp.WithBoolean("force_refresh",
+			mcp.Description("Bypass the lookup cache and query the API for every IP"),
+		),
+	)
+
+	s.AddTool(batchTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var inputs []string
+		if items, ok := request.Params.Arguments["ips"].([]interface{}); ok {
+			for _, item := range items {
+				if str, ok := item.(string); ok {
+					inputs = append(inputs, str)
+				}
+			}
+		}
+		text, _ := request.Params.Arguments["text"].(string)
+		if len(inputs) == 0 && strings.TrimSpace(text) == "" {
+			return toolError("either ips or text is required"), nil
+		}
+
+		ips, invalid := collectIPs(inputs, text)
+		if len(ips) == 0 {
+			return toolError("no valid IP addresses found in the input"), nil
+		}
+		response := batchResponse{Invalid: invalid}
+		if len(ips) > limits.maxItems {
+			response.Skipped = len(ips) - limits.maxItems
+			ips = ips[:limits.maxItems]
+		}
+
+		forceRefresh, _ := request.Params.Arguments["force_refresh"].(bool)
+		response.Results = batchLookup(withForceRefresh(ctx, forceRefresh), client, ips, limits)
+		for i := range response.Results {
+			response.Results[i].Watchlist = watch.match(indicatorIP, response.Results[i].IP)
+		}
+		response.Summary = summarize(response.Results)
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format result: %v", err)
+		}
+		return mcp.NewToolResultText(string(jsonResult)), nil
+	}))
+}
+
+// collectIPs parses list entries and extracts IPs from free text, returning
+// unique IPs in first-seen order and the list entries that were not IPs
+func collectIPs(inputs []string, text string) ([]string, []string) {
+	var ips, invalid []string
+	seen := make(map[netip.Addr]bool)
+	add := func(addr netip.Addr) {
+		addr = addr.Unmap()
+		if !seen[addr] {
+			seen[addr] = true
+			ips = append(ips, addr.String())
+		}
+	}
+
+	for _, input := range inputs {
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(input)
+		if err != nil {
+			invalid = append(invalid, input)
+			continue
+		}
+		add(addr)
+	}
+	for _, addr := range extractIPs(refang(text)) {
+		add(addr)
+	}
+	return ips, invalid
+}
+
+// extractIPs finds IPv4 and IPv6 addresses in free text
+func extractIPs(text string) []netip.Addr {
+	isIPChar := func(r rune) bool {
+		return r == '.' || r == ':' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+	}
+	var addrs []netip.Addr
+	for _, token := range strings.FieldsFunc(text, func(r rune) bool { return !isIPChar(r) }) {
+		// The raw token comes first so IPv6 addresses such as ::1 and 2001:db8:: keep their colons
+		if addr, err := netip.ParseAddr(token); err == nil {
+			addrs = append(addrs, addr)
+			continue
+		}
+		// Sentence punctuation around the address, e.g. "...1.2.3.4." or "2001:db8::1:"
+		token = strings.Trim(token, ".")
+		if addr, err := netip.ParseAddr(token); err == nil {
+			addrs = append(addrs, addr)
+			continue
+		}
+		if addr, err := netip.ParseAddr(strings.TrimSuffix(token, ":")); err == nil {
+			addrs = append(addrs, addr)
+			continue
+		}
+		// IPv4 with a port, e.g. 1.2.3.4:443
+		if host, _, ok := strings.Cut(token, ":"); ok && strings.Count(token, ":") == 1 {
+			if addr, err := netip.ParseAddr(host); err == nil && addr.Is4() {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// batchLookup queries each IP within the batch limits, returning results in
+// input order. Cached results are filled in first so they do not wait for
+// the rate limiter.
+func batchLookup(ctx context.Context, client *Client, ips []string, limits *batchLimits) []batchResult {
+	results := make([]batchResult, len(ips))
+	var pending []int
+	for i, ip := range ips {
+		results[i].IP = ip
+		if resp, err, ok := client.cached(ctx, indicatorIP, ip); ok {
+			results[i].apply(resp, err)
+		} else {
+			pending = append(pending, i)
+		}
+	}
+	limits.run(ctx, len(pending), func(n int) error {
+		result := &results[pending[n]]
+		return result.apply(client.LookupIP(ctx, result.IP))
+	}, func(n int, err error) {
+		results[pending[n]].Error = err.Error()
+	})
+	return results
+}
+
+// apply records a lookup result. "Not found" is an unknown verdict rather
+// than a failure; other errors are returned.
+func (r *batchResult) apply(resp *ChaitinIPResponse, err error) error {
+	if errors.Is(err, errNotFound) {
+		r.Verdict = verdictUnknown
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.Result = resp
+	r.Verdict = responseVerdict(resp)
+	return nil
+}
+
+// summarize counts results by verdict
+func summarize(results []batchResult) batchSummary {
+	summary := batchSummary{Total: len(results)}
+	for _, r := range results {
+		summary.add(r.Verdict, r.Error != "")
+		if len(r.Watchlist) > 0 {
+			summary.Watchlist++
+		}
+	}
+	return summary
+}
+
+// add counts one looked up indicator
+func (s *batchSummary) add(verdict string, failed bool) {
+	switch {
+	case failed:
+		s.Failed++
+	case verdict == verdictMalicious:
+		s.Malicious++
+	case verdict == verdictSuspicious:
+		s.Suspicious++
+	case verdict == verdictClean:
+		s.Clean++
+	default:
+		s.Unknown++
+	}
+}
+
+// responseVerdict derives a verdict from the reputation field of a lookup
+// result, the same field parseIPIntel reports as Reputation. Labels that
+// are not listed here, such as risk levels, leave the verdict unknown.
+func responseVerdict(resp *ChaitinResponse) string {
+	if resp == nil || resp.Code != 0 {
+		return verdictUnknown
+	}
+	value, _ := resp.Data[reputationKey].(string)
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "malicious", "black":
+		return verdictMalicious
+	case "suspicious", "gray", "grey":
+		return verdictSuspicious
+	case "clean", "white", "safe", "benign":
+		return verdictClean
+	}
+	return verdictUnknown
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractIPs(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"blocked 1.2.3.4 and 5.6.7.8.", []string{"1.2.3.4", "5.6.7.8"}},
+		{"conn 1.2.3.4:443 -> 10.0.0.1:8080", []string{"1.2.3.4", "10.0.0.1"}},
+		{"src 9.9.9.9: denied", []string{"9.9.9.9"}},
+		{"loopback ::1 reached", []string{"::1"}},
+		{"prefix 2001:db8:: announced", []string{"2001:db8::"}},
+		{"seen from 2001:db8::1.", []string{"2001:db8::1"}},
+		{"peer [2001:db8::2]:443", []string{"2001:db8::2"}},
+		{"addr ::ffff:1.2.3.4 mapped", []string{"::ffff:1.2.3.4"}},
+		{"version 1.2.3 and 12:30", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, addr := range extractIPs(tt.text) {
+			got = append(got, addr.String())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("extractIPs(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -18,7 +18,7 @@ build() {
     fi
     
     echo "Building for $os/$arch..."
-    GOOS=$os GOARCH=$arch go build -o "build/chaitin-mcp-${os}-${arch}${extension}" main.go
+    GOOS=$os GOARCH=$arch go build -o "build/chaitin-mcp-${os}-${arch}${extension}" .
 }
 
 # Linux
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Cache defaults. Hashes describe a fixed file so their verdict changes
+// rarely, while IP reputation moves fastest.
+const (
+	defaultCacheMaxEntries  = 10000
+	defaultCacheNegativeTTL = 15 * time.Minute
+	defaultCacheSaveEvery   = 5 * time.Minute
+)
+
+var defaultCacheTTLs = map[string]time.Duration{
+	indicatorIP:     time.Hour,
+	indicatorDomain: 6 * time.Hour,
+	indicatorURL:    6 * time.Hour,
+	indicatorHash:   24 * time.Hour,
+}
+
+// Cache lookup outcomes, used as the metrics label
+const (
+	cacheHit         = "hit"
+	cacheNegativeHit = "negative_hit"
+	cacheMiss        = "miss"
+)
+
+// CacheConfig configures the lookup cache
+type CacheConfig struct {
+	MaxEntries  int                 `json:"max_entries,omitempty"`  // Least recently used entries are evicted beyond this, defaults to 10000
+	TTL         map[string]Duration `json:"ttl,omitempty"`          // Per indicator type (ip, domain, url, hash)
+	NegativeTTL Duration            `json:"negative_ttl,omitempty"` // How long "no intelligence found" is remembered, defaults to 15m
+	Path        string              `json:"path,omitempty"`         // Persist the cache to this file across restarts when set
+}
+
+// Duration is a time.Duration written as a string such as "90s" or "6h" in the config
+type Duration time.Duration
+
+// UnmarshalJSON accepts a duration string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// forceRefreshKey marks a context whose lookups must bypass the cache
+type forceRefreshKey struct{}
+
+// withForceRefresh makes lookups made with the context skip cached results.
+// Fresh results are still stored.
+func withForceRefresh(ctx context.Context, force bool) context.Context {
+	if !force {
+		return ctx
+	}
+	return context.WithValue(ctx, forceRefreshKey{}, true)
+}
+
+func forceRefresh(ctx context.Context) bool {
+	force, _ := ctx.Value(forceRefreshKey{}).(bool)
+	return force
+}
+
+// cacheEntry is one cached lookup. A nil response records that the API had no intelligence.
+type cacheEntry struct {
+	Indicator string           `json:"indicator"`
+	Value     string           `json:"value"`
+	Expires   time.Time        `json:"expires"`
+	Response  *ChaitinResponse `json:"response,omitempty"`
+}
+
+// cacheStats counts cache activity since the server started
+type cacheStats struct {
+	Hits         uint64         `json:"hits"`
+	NegativeHits uint64         `json:"negative_hits"`
+	Misses       uint64         `json:"misses"`
+	Evictions    uint64         `json:"evictions"`
+	Entries      int            `json:"entries"`
+	ByType       map[string]int `json:"entries_by_type"`
+	HitRatio     float64        `json:"hit_ratio"`
+	MaxEntries   int            `json:"max_entries"`
+	Persisted    string         `json:"path,omitempty"`
+}
+
+// lookupCache is a TTL cache of lookup results with least recently used eviction
+type lookupCache struct {
+	mu          sync.Mutex
+	entries     map[string]*list.Element // key -> element holding *cacheEntry
+	order       *list.List               // Front is most recently used
+	maxEntries  int
+	ttls        map[string]time.Duration
+	negativeTTL time.Duration
+	path        string
+	saveMu      sync.Mutex // Serializes saves from persist and Client.Close
+	dirty       bool
+	stats       cacheStats
+	now         func() time.Time
+}
+
+// newLookupCache applies defaults to the config and loads the persisted cache, if any
+func newLookupCache(config *CacheConfig) (*lookupCache, error) {
+	c := &lookupCache{
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+		maxEntries:  config.MaxEntries,
+		ttls:        make(map[string]time.Duration, len(defaultCacheTTLs)),
+		negativeTTL: time.Duration(config.NegativeTTL),
+		path:        config.Path,
+		now:         time.Now,
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultCacheMaxEntries
+	}
+	if c.negativeTTL <= 0 {
+		c.negativeTTL = defaultCacheNegativeTTL
+	}
+	for indicator, ttl := range defaultCacheTTLs {
+		c.ttls[indicator] = ttl
+	}
+	for indicator, ttl := range config.TTL {
+		c.ttls[indicator] = time.Duration(ttl)
+	}
+
+	if c.path != "" {
+		if err := c.load(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func cacheKey(indicator, value string) string {
+	return indicator + "\x00" + value
+}
+
+// get returns a cached response, or errNotFound for a cached negative
+// result. ok is false on a miss.
+func (c *lookupCache) get(indicator, value string) (resp *ChaitinResponse, err error, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	outcome := cacheMiss
+	defer func() { observeCache(indicator, outcome) }()
+
+	elem, found := c.entries[cacheKey(indicator, value)]
+	if !found {
+		c.stats.Misses++
+		return nil, nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !c.now().Before(entry.Expires) {
+		c.remove(elem)
+		c.stats.Misses++
+		return nil, nil, false
+	}
+	c.order.MoveToFront(elem)
+	if entry.Response == nil {
+		c.stats.NegativeHits++
+		outcome = cacheNegativeHit
+		return nil, errNotFound, true
+	}
+	c.stats.Hits++
+	outcome = cacheHit
+	return entry.Response, nil, true
+}
+
+// put stores a lookup result. Only successes and "not found" are cached;
+// other errors are transient and must be retried.
+func (c *lookupCache) put(indicator, value string, resp *ChaitinResponse, err error) {
+	ttl := c.ttls[indicator]
+	switch {
+	case err == nil:
+	case errors.Is(err, errNotFound):
+		resp, ttl = nil, c.negativeTTL
+	default:
+		return
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(&cacheEntry{Indicator: indicator, Value: value, Expires: c.now().Add(ttl), Response: resp})
+	c.dirty = true
+}
+
+// insert adds or replaces an entry as the most recently used, evicting beyond maxEntries
+func (c *lookupCache) insert(entry *cacheEntry) {
+	key := cacheKey(entry.Indicator, entry.Value)
+	if elem, found := c.entries[key]; found {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.order.PushFront(entry)
+		cacheEntries.WithLabelValues(entry.Indicator).Inc()
+	}
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+		cacheEvictions.Inc()
+	}
+}
+
+func (c *lookupCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*cacheEntry)
+	delete(c.entries, cacheKey(entry.Indicator, entry.Value))
+	cacheEntries.WithLabelValues(entry.Indicator).Dec()
+}
+
+// snapshot returns the current stats
+func (c *lookupCache) snapshot() cacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	stats.MaxEntries = c.maxEntries
+	stats.Persisted = c.path
+	stats.ByType = make(map[string]int)
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		stats.ByType[elem.Value.(*cacheEntry).Indicator]++
+	}
+	if lookups := stats.Hits + stats.NegativeHits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits+stats.NegativeHits) / float64(lookups)
+	}
+	return stats
+}
+
+// persistedCache is the on-disk format, entries ordered from most to least recently used
+type persistedCache struct {
+	Entries []*cacheEntry `json:"entries"`
+}
+
+// load reads the persisted cache, dropping expired entries. A missing file is an empty cache.
+func (c *lookupCache) load() error {
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cache file: %v", err)
+	}
+	var persisted persistedCache
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return fmt.Errorf("failed to parse cache file: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for i := len(persisted.Entries) - 1; i >= 0; i-- {
+		if entry := persisted.Entries[i]; entry != nil && now.Before(entry.Expires) {
+			c.insert(entry)
+		}
+	}
+	return nil
+}
+
+// save writes the cache to disk if it changed since the last save. The
+// file is replaced atomically so a crash never leaves it half written.
+func (c *lookupCache) save() (err error) {
+	if c.path == "" {
+		return nil
+	}
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	now := c.now()
+	persisted := persistedCache{Entries: make([]*cacheEntry, 0, c.order.Len())}
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		if entry := elem.Value.(*cacheEntry); now.Before(entry.Expires) {
+			persisted.Entries = append(persisted.Entries, entry)
+		}
+	}
+	// dirty is cleared before writing so that a put during the write marks
+	// the cache dirty again, and restored if the write fails
+	c.dirty = false
+	c.mu.Unlock()
+	defer func() {
+		if err != nil {
+			c.mu.Lock()
+			c.dirty = true
+			c.mu.Unlock()
+		}
+	}()
+
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	return nil
+}
+
+// persist saves the cache periodically until ctx is done. The final save is
+// left to Client.Close, which cancels ctx first and is called by serveStdio
+// once the server stops, including on SIGINT and SIGTERM.
+func (c *lookupCache) persist(ctx context.Context) {
+	ticker := time.NewTicker(defaultCacheSaveEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.save(); err != nil {
+				log.Printf("Failed to save cache: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// registerCacheTool adds the cache_stats tool
+func registerCacheTool(s *server.MCPServer, client *Client) {
+	statsTool := mcp.NewTool("cache_stats",
+		mcp.WithDescription("Show lookup cache statistics: hits, misses, evictions and cached entries by indicator type"),
+	)
+	s.AddTool(statsTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if client.cache == nil {
+			return mcp.NewToolResultText("The lookup cache is disabled, set cache in the config to enable it"), nil
+		}
+		jsonResult, err := json.MarshalIndent(client.cache.snapshot(), "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format result: %v", err)
+		}
+		return mcp.NewToolResultText(string(jsonResult)), nil
+	}))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, config *CacheConfig) (*lookupCache, *time.Time) {
+	t.Helper()
+	c, err := newLookupCache(config)
+	if err != nil {
+		t.Fatalf("newLookupCache: %v", err)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, now := newTestCache(t, &CacheConfig{TTL: map[string]Duration{indicatorDomain: Duration(time.Minute)}})
+	resp := &ChaitinResponse{Data: map[string]any{"reputation": "black"}}
+	c.put(indicatorIP, "1.2.3.4", resp, nil)
+	c.put(indicatorDomain, "evil.com", resp, nil)
+	c.put(indicatorIP, "5.6.7.8", nil, errUpstream)
+
+	tests := []struct {
+		after     time.Duration
+		indicator string
+		value     string
+		want      bool
+	}{
+		{0, indicatorIP, "1.2.3.4", true},
+		{0, indicatorIP, "5.6.7.8", false}, // Transient errors are not cached
+		{59 * time.Second, indicatorDomain, "evil.com", true},
+		{time.Minute, indicatorDomain, "evil.com", false},
+		{59 * time.Minute, indicatorIP, "1.2.3.4", true},
+		{time.Hour, indicatorIP, "1.2.3.4", false},
+	}
+	start := *now
+	for _, tt := range tests {
+		*now = start.Add(tt.after)
+		got, err, ok := c.get(tt.indicator, tt.value)
+		if ok != tt.want {
+			t.Errorf("get(%s) after %v: ok = %v, want %v", tt.value, tt.after, ok, tt.want)
+		}
+		if ok && (err != nil || got != resp) {
+			t.Errorf("get(%s) = %v, %v", tt.value, got, err)
+		}
+	}
+	if stats := c.snapshot(); stats.Entries != 0 {
+		t.Errorf("Entries = %d, want expired entries removed", stats.Entries)
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	c, now := newTestCache(t, &CacheConfig{NegativeTTL: Duration(time.Minute)})
+	c.put(indicatorIP, "1.2.3.4", nil, fmt.Errorf("lookup: %w", errNotFound))
+
+	if resp, err, ok := c.get(indicatorIP, "1.2.3.4"); !ok || resp != nil || !errors.Is(err, errNotFound) {
+		t.Fatalf("get = %v, %v, %v, want cached errNotFound", resp, err, ok)
+	}
+	*now = now.Add(time.Minute)
+	if _, _, ok := c.get(indicatorIP, "1.2.3.4"); ok {
+		t.Error("negative result outlived negative_ttl")
+	}
+	if stats := c.snapshot(); stats.NegativeHits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c, _ := newTestCache(t, &CacheConfig{MaxEntries: 2})
+	resp := &ChaitinResponse{}
+	c.put(indicatorIP, "1.1.1.1", resp, nil)
+	c.put(indicatorIP, "2.2.2.2", resp, nil)
+	c.get(indicatorIP, "1.1.1.1") // 2.2.2.2 is now least recently used
+	c.put(indicatorIP, "3.3.3.3", resp, nil)
+
+	for value, want := range map[string]bool{"1.1.1.1": true, "2.2.2.2": false, "3.3.3.3": true} {
+		if _, _, ok := c.get(indicatorIP, value); ok != want {
+			t.Errorf("get(%s): ok = %v, want %v", value, ok, want)
+		}
+	}
+	if stats := c.snapshot(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestCachePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, _ := newTestCache(t, &CacheConfig{Path: path})
+	c.put(indicatorIP, "1.2.3.4", &ChaitinResponse{Data: map[string]any{"reputation": "black"}}, nil)
+	c.put(indicatorIP, "5.6.7.8", nil, errNotFound)
+	if err := c.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	loaded, err := newLookupCache(&CacheConfig{Path: path})
+	if err != nil {
+		t.Fatalf("newLookupCache: %v", err)
+	}
+	if resp, _, ok := loaded.get(indicatorIP, "1.2.3.4"); !ok || resp.Data["reputation"] != "black" {
+		t.Errorf("loaded get = %v, %v", resp, ok)
+	}
+	if _, err, ok := loaded.get(indicatorIP, "5.6.7.8"); !ok || !errors.Is(err, errNotFound) {
+		t.Errorf("loaded negative get = %v, %v", err, ok)
+	}
+}
+
+// A failed save must leave the cache dirty so the next save retries
+func TestCacheSaveFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	c, _ := newTestCache(t, &CacheConfig{Path: filepath.Join(dir, "cache.json")})
+	c.put(indicatorIP, "1.2.3.4", &ChaitinResponse{}, nil)
+	if err := c.save(); err == nil {
+		t.Fatal("save into a missing directory succeeded")
+	}
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := os.Stat(c.path); err != nil {
+		t.Errorf("cache file not written after retry: %v", err)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// defaultBaseURL is the public Chaitin API, overridable for testing and private deployments
+const defaultBaseURL = "https://ip-0.rivers.chaitin.cn"
+
+// redacted replaces the secret key wherever it would otherwise be shown
+const redacted = "[REDACTED]"
+
+// Indicator types supported by the Chaitin API
+const (
+	indicatorIP     = "ip"
+	indicatorDomain = "domain"
+	indicatorURL    = "url"
+	indicatorHash   = "hash"
+)
+
+// indicatorTypes lists the indicator types an endpoint can be configured for
+var indicatorTypes = []string{indicatorIP, indicatorDomain, indicatorURL, indicatorHash}
+
+// defaultEndpoints maps indicator types to their Chaitin API path. The value
+// is sent in a query parameter named after the indicator type. Only the IP
+// endpoint is known; domain, URL and hash lookups are disabled until their
+// paths are set in the endpoints config.
+var defaultEndpoints = map[string]string{
+	indicatorIP: "/api/share/s",
+}
+
+// ChaitinResponse represents the response structure from Chaitin API
+type ChaitinResponse struct {
+	Code int            `json:"code"`
+	Msg  string         `json:"msg"`
+	Data map[string]any `json:"data"`
+}
+
+// ChaitinIPResponse is the response to an IP lookup
+type ChaitinIPResponse = ChaitinResponse
+
+// Kinds of API errors, matched with errors.Is
+var (
+	errNotFound       = errors.New("no intelligence found")
+	errQuotaExhausted = errors.New("API quota exhausted")
+	errRateLimited    = errors.New("rate limited by the API")
+	errUnauthorized   = errors.New("secret key was rejected")
+	errUpstream       = errors.New("API request failed")
+)
+
+// Phrases in the API message that identify an error kind. The API does not
+// document its codes, so the message and HTTP status are used instead. The
+// phrases are specific on purpose: a bare word such as "auth" or "token"
+// also appears in unrelated messages. Rate limits are checked before quotas
+// so that "rate limit exceeded" is not taken for an exhausted quota.
+var apiErrorKeywords = []struct {
+	kind     error
+	keywords []string
+}{
+	{errRateLimited, []string{"rate limit", "too many requests", "too frequent", "请求过于频繁", "访问频繁"}},
+	{errQuotaExhausted, []string{"quota", "insufficient balance", "次数不足", "次数已用完", "额度不足", "额度已用完", "配额", "余额不足"}},
+	{errUnauthorized, []string{"invalid sk", "sk invalid", "sk error", "invalid secret", "invalid api key", "invalid key", "unauthorized", "authentication failed", "permission denied", "密钥无效", "密钥错误", "无权限", "认证失败"}},
+	{errNotFound, []string{"not found", "no data", "no result", "not exist", "未找到", "不存在", "无数据", "无结果"}},
+}
+
+// APIError is a non-2xx HTTP status or a non-zero code from the Chaitin API
+type APIError struct {
+	StatusCode int    // HTTP status code
+	Code       int    // Code field of the response, 0 if the body was not JSON
+	Msg        string // Msg field of the response
+	Kind       error  // One of the err* kinds above
+}
+
+func (e *APIError) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("%v (HTTP %d, code %d): %s", e.Kind, e.StatusCode, e.Code, msg)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// classifyAPIError works out the kind of an API error from the HTTP status and message
+func classifyAPIError(statusCode int, msg string) error {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errUnauthorized
+	case http.StatusNotFound:
+		return errNotFound
+	case http.StatusTooManyRequests:
+		// A 429 is a quota error when the message says so, otherwise a short-term limit
+		if strings.Contains(strings.ToLower(msg), "quota") {
+			return errQuotaExhausted
+		}
+		return errRateLimited
+	case http.StatusPaymentRequired:
+		return errQuotaExhausted
+	}
+	lower := strings.ToLower(msg)
+	for _, entry := range apiErrorKeywords {
+		for _, keyword := range entry.keywords {
+			if strings.Contains(lower, keyword) {
+				return entry.kind
+			}
+		}
+	}
+	return errUpstream
+}
+
+// Client queries the Chaitin threat intelligence API. One client is shared by all lookup tools.
+type Client struct {
+	sk        string
+	baseURL   *url.URL
+	endpoints map[string]string
+	httpCli   *http.Client
+	cache     *lookupCache       // nil when caching is disabled
+	stopCache context.CancelFunc // Stops saving the cache periodically
+}
+
+// NewClient creates a Chaitin API client from the config
+func NewClient(config *Config) (*Client, error) {
+	rawBase := config.BaseURL
+	if rawBase == "" {
+		rawBase = defaultBaseURL
+	}
+	baseURL, err := url.Parse(rawBase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base url: %v", err)
+	}
+
+	endpoints := make(map[string]string, len(defaultEndpoints))
+	for indicator, path := range defaultEndpoints {
+		endpoints[indicator] = path
+	}
+	for indicator, path := range config.Endpoints {
+		endpoints[indicator] = path
+	}
+
+	client := &Client{
+		sk:        config.SK,
+		baseURL:   baseURL,
+		endpoints: endpoints,
+		httpCli: &http.Client{
+			Timeout:       30 * time.Second,
+			CheckRedirect: sameHostRedirects(baseURL),
+		},
+	}
+
+	if config.Cache != nil {
+		client.cache, err = newLookupCache(config.Cache)
+		if err != nil {
+			return nil, err
+		}
+		if config.Cache.Path != "" {
+			ctx, cancel := context.WithCancel(context.Background())
+			client.stopCache = cancel
+			go client.cache.persist(ctx)
+		}
+	}
+	return client, nil
+}
+
+// Close saves the cache to disk when it is persisted
+func (c *Client) Close() error {
+	if c.cache == nil {
+		return nil
+	}
+	if c.stopCache != nil {
+		c.stopCache()
+	}
+	return c.cache.save()
+}
+
+// LookupIP looks up threat intelligence for an IP address
+func (c *Client) LookupIP(ctx context.Context, ip string) (*ChaitinIPResponse, error) {
+	return c.lookup(ctx, indicatorIP, ip)
+}
+
+// LookupDomain looks up threat intelligence for a domain
+func (c *Client) LookupDomain(ctx context.Context, domain string) (*ChaitinResponse, error) {
+	return c.lookup(ctx, indicatorDomain, domain)
+}
+
+// LookupURL looks up threat intelligence for a URL
+func (c *Client) LookupURL(ctx context.Context, rawURL string) (*ChaitinResponse, error) {
+	return c.lookup(ctx, indicatorURL, rawURL)
+}
+
+// LookupHash looks up threat intelligence for an MD5, SHA-1 or SHA-256 file hash
+func (c *Client) LookupHash(ctx context.Context, hash string) (*ChaitinResponse, error) {
+	return c.lookup(ctx, indicatorHash, hash)
+}
+
+// Supports reports whether an API endpoint is configured for the indicator type
+func (c *Client) Supports(indicator string) bool {
+	return c.endpoints[indicator] != ""
+}
+
+// lookup returns the cached result for an indicator or queries the API,
+// caching what it returns
+func (c *Client) lookup(ctx context.Context, indicator, value string) (*ChaitinResponse, error) {
+	return c.limitedLookup(ctx, nil, indicator, value)
+}
+
+// limitedLookup is lookup, waiting on limiter only when the API has to be
+// queried so cache hits are never throttled. A nil limiter does not wait.
+func (c *Client) limitedLookup(ctx context.Context, limiter *rate.Limiter, indicator, value string) (*ChaitinResponse, error) {
+	if !c.Supports(indicator) {
+		return nil, fmt.Errorf("%w: no Chaitin endpoint is configured for %s lookups, set endpoints.%s", errUnsupported, indicator, indicator)
+	}
+	if resp, err, ok := c.cached(ctx, indicator, value); ok {
+		return resp, err
+	}
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.query(ctx, indicator, value)
+	if c.cache != nil {
+		c.cache.put(indicator, value, resp, err)
+	}
+	return resp, err
+}
+
+// cached returns a cached result, ok is false when the API has to be queried
+func (c *Client) cached(ctx context.Context, indicator, value string) (*ChaitinResponse, error, bool) {
+	if c.cache == nil || forceRefresh(ctx) {
+		return nil, nil, false
+	}
+	return c.cache.get(indicator, value)
+}
+
+// query queries the endpoint for one indicator type
+func (c *Client) query(ctx context.Context, indicator, value string) (*ChaitinResponse, error) {
+	path := c.endpoints[indicator]
+
+	// The URL carries the secret key, so only the path is recorded on the
+	// span. Requests are never retried, so there is no resend count to record.
+	ctx, span := tracer().Start(ctx, "GET "+path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("server.address", c.baseURL.Hostname()),
+			attribute.String("url.path", path),
+			attribute.String("chaitin."+indicator, value),
+		),
+	)
+	defer span.End()
+
+	query := url.Values{}
+	query.Set(indicator, value)
+	req, err := c.newRequest(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := c.do(req)
+	if err != nil {
+		observeUpstream(indicator, 0, time.Since(start))
+		span.SetStatus(codes.Error, "request failed")
+		return nil, err
+	}
+	defer resp.Body.Close()
+	observeUpstream(indicator, resp.StatusCode, time.Since(start))
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	// Error statuses may come with a JSON body explaining the error, or with none
+	var result ChaitinResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			// A 404 without a JSON body means the endpoint path is wrong, not that the indicator is unknown
+			kind := classifyAPIError(resp.StatusCode, "")
+			if kind == errNotFound {
+				kind = errUpstream
+			}
+			return nil, &APIError{StatusCode: resp.StatusCode, Kind: kind}
+		}
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || result.Code != 0 {
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Code:       result.Code,
+			Msg:        c.redact(result.Msg),
+			Kind:       classifyAPIError(resp.StatusCode, result.Msg),
+		}
+		if !errors.Is(apiErr, errNotFound) {
+			span.SetStatus(codes.Error, apiErr.Error())
+		}
+		return nil, apiErr
+	}
+
+	return &result, nil
+}
+
+// newRequest builds a GET request for an API path, adding the secret key to
+// the query. Every request goes through here so the key is only ever
+// handled by newRequest, do and redact.
+func (c *Client) newRequest(ctx context.Context, path string, query url.Values) (*http.Request, error) {
+	reqURL := c.baseURL.JoinPath(path)
+	query.Set("sk", c.sk)
+	reqURL.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", c.redact(err.Error()))
+	}
+	return req, nil
+}
+
+// do sends a request. Transport errors include the request URL, so the key
+// is redacted from them while keeping the cause for errors.Is.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpCli.Do(req)
+	if err != nil {
+		return nil, newRedactedError("failed to query Chaitin API: "+c.redact(err.Error()), err)
+	}
+	return resp, nil
+}
+
+// redact removes the secret key from a string
+func (c *Client) redact(s string) string {
+	return redactSecret(s, c.sk)
+}
+
+// redactSecret removes a secret, raw or URL-encoded, from a string
+func redactSecret(s, secret string) string {
+	if secret == "" {
+		return s
+	}
+	for _, form := range []string{secret, url.QueryEscape(secret), url.PathEscape(secret)} {
+		s = strings.ReplaceAll(s, form, redacted)
+	}
+	return s
+}
+
+// sameHostRedirects only follows redirects within the host and scheme of
+// base. Redirects keep the query, so following one to another host would
+// hand it the key, and following https to http would send it in plaintext.
+func sameHostRedirects(base *url.URL) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if req.URL.Host != base.Host {
+			return fmt.Errorf("refusing redirect to another host %s", req.URL.Host)
+		}
+		if req.URL.Scheme != base.Scheme {
+			return fmt.Errorf("refusing redirect from %s to %s", base.Scheme, req.URL.Scheme)
+		}
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+// redactedError carries a message with the secret key removed while still
+// unwrapping to the cause of the original error
+type redactedError struct {
+	msg string
+	err error
+}
+
+// newRedactedError wraps a request error. A *url.Error keeps the request
+// URL with the key in its query, so only its cause is kept for unwrapping.
+func newRedactedError(msg string, err error) *redactedError {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestClassifyAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		msg    string
+		kind   error
+	}{
+		{"401", http.StatusUnauthorized, "", errUnauthorized},
+		{"403", http.StatusForbidden, "whatever", errUnauthorized},
+		{"404", http.StatusNotFound, "", errNotFound},
+		{"402", http.StatusPaymentRequired, "", errQuotaExhausted},
+		{"429", http.StatusTooManyRequests, "slow down", errRateLimited},
+		{"429 quota", http.StatusTooManyRequests, "Daily quota exceeded", errQuotaExhausted},
+		{"quota message", http.StatusOK, "quota exhausted for today", errQuotaExhausted},
+		{"quota chinese", http.StatusOK, "查询次数不足", errQuotaExhausted},
+		{"rate limit exceeded", http.StatusOK, "rate limit exceeded", errRateLimited},
+		{"rate limit chinese", http.StatusOK, "请求过于频繁，请稍后再试", errRateLimited},
+		{"invalid sk", http.StatusOK, "Invalid SK", errUnauthorized},
+		{"key chinese", http.StatusOK, "密钥无效", errUnauthorized},
+		{"not found", http.StatusOK, "IP not found", errNotFound},
+		{"no data chinese", http.StatusOK, "无数据", errNotFound},
+		// Messages that merely mention a word of another kind stay generic
+		{"author", http.StatusOK, "contact the author of this feed", errUpstream},
+		{"token", http.StatusOK, "unexpected token in request", errUpstream},
+		{"exceeded length", http.StatusOK, "parameter exceeded maximum length", errUpstream},
+		{"empty parameter", http.StatusOK, "parameter ip is empty", errUpstream},
+		{"internal", http.StatusInternalServerError, "", errUpstream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyAPIError(tt.status, tt.msg); got != tt.kind {
+				t.Errorf("classifyAPIError(%d, %q) = %v, want %v", tt.status, tt.msg, got, tt.kind)
+			}
+		})
+	}
+}
+
+// stubChaitin starts a Chaitin API stub and a client pointed at it
+func stubChaitin(t *testing.T, sk string, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := NewClient(&Config{SK: sk, BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// Errors from the API are returned as APIError with the kind that lookupError reports
+func TestLookupIPErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		kind   error
+	}{
+		{"not found", http.StatusOK, `{"code": 1, "msg": "no data"}`, errNotFound},
+		{"quota exhausted", http.StatusOK, `{"code": 1001, "msg": "quota exhausted"}`, errQuotaExhausted},
+		{"quota status", http.StatusPaymentRequired, `{"code": 1001, "msg": "payment required"}`, errQuotaExhausted},
+		{"rate limited", http.StatusTooManyRequests, ``, errRateLimited},
+		{"unauthorized", http.StatusUnauthorized, ``, errUnauthorized},
+		// A 404 without a JSON body means the endpoint path is wrong
+		{"wrong path", http.StatusNotFound, `404 page not found`, errUpstream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := stubChaitin(t, "test-sk", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			_, err := client.LookupIP(context.Background(), "1.2.3.4")
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("LookupIP() error = %v, want an APIError", err)
+			}
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("LookupIP() error = %v, want kind %v", err, tt.kind)
+			}
+		})
+	}
+}
+
+// A secret key with characters that change when URL-encoded
+const testSK = "sk-a+b/c=d&e f"
+
+// assertNoSecret fails when s contains the secret key in raw or URL-encoded form
+func assertNoSecret(t *testing.T, what, s string) {
+	t.Helper()
+	for _, form := range []string{testSK, url.QueryEscape(testSK), url.PathEscape(testSK)} {
+		if strings.Contains(s, form) {
+			t.Errorf("%s contains the secret key as %q: %s", what, form, s)
+		}
+	}
+}
+
+func TestSecretKeyRedacted(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		closed  bool // Close the server before the request to get a transport error
+	}{
+		{
+			name: "api message",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				msg := "invalid sk " + r.URL.Query().Get("sk") + " (" + r.URL.RawQuery + ")"
+				writeJSON(t, w, map[string]any{"code": 1, "msg": msg})
+			},
+		},
+		{
+			name: "redirect to another host",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://other.invalid/api/share/s?"+r.URL.RawQuery, http.StatusFound)
+			},
+		},
+		{
+			name:   "transport error",
+			closed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			if tt.closed {
+				server.Close()
+			} else {
+				defer server.Close()
+			}
+			client, err := NewClient(&Config{SK: testSK, BaseURL: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The lookup goes through the audited tool wrapper as it does in the server
+			path := filepath.Join(t.TempDir(), "audit.log")
+			logger, err := openAuditLog(&AuditConfig{Path: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			auditLog = logger
+			defer func() {
+				auditLog = nil
+				logger.Close()
+			}()
+
+			var lookupErr error
+			handler := auditTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				_, lookupErr = client.LookupIP(ctx, "1.2.3.4")
+				if lookupErr == nil {
+					return mcp.NewToolResultText("ok"), nil
+				}
+				return lookupError(lookupErr), nil
+			})
+			var request mcp.CallToolRequest
+			request.Params.Name = "ip_lookup"
+			result, err := handler(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if lookupErr == nil {
+				t.Fatal("LookupIP succeeded, want an error")
+			}
+			assertNoSecret(t, "error", lookupErr.Error())
+			// Nothing in the unwrapped chain may carry the key either, such as a *url.Error
+			for err := errors.Unwrap(lookupErr); err != nil; err = errors.Unwrap(err) {
+				assertNoSecret(t, fmt.Sprintf("unwrapped %T", err), fmt.Sprintf("%+v", err))
+			}
+			var urlErr *url.Error
+			if errors.As(lookupErr, &urlErr) {
+				t.Errorf("error unwraps to a *url.Error holding the request URL: %v", urlErr.URL)
+			}
+			var apiErr *APIError
+			if errors.As(lookupErr, &apiErr) {
+				assertNoSecret(t, "APIError.Msg", apiErr.Msg)
+			}
+			assertNoSecret(t, "tool result", result.Content[0].(mcp.TextContent).Text)
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), `"status":"error"`) {
+				t.Errorf("audit log has no error entry: %s", data)
+			}
+			assertNoSecret(t, "audit log", string(data))
+		})
+	}
+}
+
+func TestSameHostRedirects(t *testing.T) {
+	base, _ := url.Parse("https://webdir.example.com")
+	check := sameHostRedirects(base)
+	tests := []struct {
+		target string
+		ok     bool
+	}{
+		{"https://webdir.example.com/api/share/s?sk=x", true},
+		{"https://other.example.com/api/share/s?sk=x", false},
+		// Same host, but the key would cross the network in plaintext
+		{"http://webdir.example.com/api/share/s?sk=x", false},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, tt.target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := check(req, nil); (err == nil) != tt.ok {
+			t.Errorf("redirect to %s: error = %v, want allowed %v", tt.target, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables. Each one may also be given as <NAME>_FILE pointing
+// at a file holding the value, which is how Docker and Kubernetes mount secrets.
+const (
+	EnvSK         = "CHAITIN_SK"
+	EnvBaseURL    = "CHAITIN_BASE_URL"
+	fileEnvSuffix = "_FILE"
+)
+
+// Config holds the server configuration
+type Config struct {
+	SK     string `json:"sk"`                // Chaitin API secret key
+	SKFile string `json:"sk_file,omitempty"` // Read the secret key from this file when sk is empty
+
+	// BaseURL overrides the Chaitin API address, e.g. for a private deployment or a local stub
+	BaseURL string `json:"base_url,omitempty"`
+
+	MetricsListen string         `json:"metrics_listen,omitempty"` // Serve Prometheus metrics on this address when set
+	Tracing       *TracingConfig `json:"tracing,omitempty"`        // Export OpenTelemetry traces when set
+	Audit         *AuditConfig   `json:"audit,omitempty"`          // Record every tool call when set
+	Batch         *BatchConfig   `json:"batch,omitempty"`          // Concurrency and rate limits for batch lookups
+	Cache         *CacheConfig   `json:"cache,omitempty"`          // Cache lookup results when set
+
+	// RejectPrivateIPs makes ip_lookup refuse private and reserved addresses instead of querying them
+	RejectPrivateIPs bool `json:"reject_private_ips,omitempty"`
+
+	// Endpoints overrides the Chaitin API path per indicator type (ip, domain, url, hash)
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+
+	// Providers enables additional threat intelligence sources for multi_lookup
+	Providers *ProvidersConfig `json:"providers,omitempty"`
+
+	// Watchlist loads local IOC lists that lookups are matched against
+	Watchlist *WatchlistConfig `json:"watchlist,omitempty"`
+
+	// Export identifies this producer in STIX and MISP exports
+	Export *ExportConfig `json:"export,omitempty"`
+}
+
+// LoadConfig reads an optional JSON or YAML config file and applies
+// environment variable overrides. An empty path uses the environment only.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		if err := unmarshalConfig(path, data, &config); err != nil {
+			return nil, err
+		}
+	}
+
+	sk, ok, err := lookupEnv(EnvSK)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		config.SK = sk
+	}
+	if baseURL, ok := os.LookupEnv(EnvBaseURL); ok {
+		config.BaseURL = baseURL
+	}
+	if config.SK == "" && config.SKFile != "" {
+		sk, err := readSecret(config.SKFile)
+		if err != nil {
+			return nil, fmt.Errorf("sk_file: %v", err)
+		}
+		config.SK = sk
+	}
+
+	// Provider keys come from <NAME>_API_KEY, then api_key, then api_key_file
+	for name, provider := range config.Providers.configured() {
+		key, ok, err := lookupEnv(strings.ToUpper(name) + "_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			provider.APIKey = key
+		}
+		if provider.APIKey == "" && provider.APIKeyFile != "" {
+			key, err := readSecret(provider.APIKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("providers.%s.api_key_file: %v", name, err)
+			}
+			provider.APIKey = key
+		}
+	}
+
+	return &config, nil
+}
+
+// Validate reports every problem found in the config
+func (c *Config) Validate() error {
+	var errs []error
+	if c.SK == "" {
+		errs = append(errs, fmt.Errorf("sk: is required (or set sk_file / %s / %s%s)", EnvSK, EnvSK, fileEnvSuffix))
+	}
+	if err := checkBaseURL(c.BaseURL); err != nil {
+		errs = append(errs, fmt.Errorf("base_url: %v", err))
+	}
+	indicators := make([]string, 0, len(c.Endpoints))
+	for indicator := range c.Endpoints {
+		indicators = append(indicators, indicator)
+	}
+	sort.Strings(indicators)
+	for _, indicator := range indicators {
+		path := c.Endpoints[indicator]
+		if !slices.Contains(indicatorTypes, indicator) {
+			errs = append(errs, fmt.Errorf("endpoints.%s: unknown indicator type, must be one of ip, domain, url, hash", indicator))
+		} else if !strings.HasPrefix(path, "/") {
+			errs = append(errs, fmt.Errorf("endpoints.%s: path must start with /", indicator))
+		}
+	}
+	if b := c.Batch; b != nil {
+		if b.Concurrency < 0 {
+			errs = append(errs, fmt.Errorf("batch.concurrency: must not be negative"))
+		}
+		if b.Rate < 0 {
+			errs = append(errs, fmt.Errorf("batch.rate: must not be negative"))
+		}
+		if b.MaxIPs < 0 {
+			errs = append(errs, fmt.Errorf("batch.max_ips: must not be negative"))
+		}
+	}
+	if cache := c.Cache; cache != nil {
+		if cache.MaxEntries < 0 {
+			errs = append(errs, fmt.Errorf("cache.max_entries: must not be negative"))
+		}
+		if cache.NegativeTTL < 0 {
+			errs = append(errs, fmt.Errorf("cache.negative_ttl: must not be negative"))
+		}
+		indicators := make([]string, 0, len(cache.TTL))
+		for indicator := range cache.TTL {
+			indicators = append(indicators, indicator)
+		}
+		sort.Strings(indicators)
+		for _, indicator := range indicators {
+			if _, ok := defaultCacheTTLs[indicator]; !ok {
+				errs = append(errs, fmt.Errorf("cache.ttl.%s: unknown indicator type, must be one of ip, domain, url, hash", indicator))
+			} else if cache.TTL[indicator] < 0 {
+				errs = append(errs, fmt.Errorf("cache.ttl.%s: must not be negative", indicator))
+			}
+		}
+	}
+	providers := c.Providers.configured()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		provider := providers[name]
+		if provider.APIKey == "" {
+			errs = append(errs, fmt.Errorf("providers.%s.api_key: is required (or set api_key_file / %s_API_KEY)", name, strings.ToUpper(name)))
+		}
+		if err := checkBaseURL(provider.BaseURL); err != nil {
+			errs = append(errs, fmt.Errorf("providers.%s.base_url: %v", name, err))
+		}
+		if provider.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("providers.%s.timeout: must not be negative", name))
+		}
+	}
+	if w := c.Watchlist; w != nil {
+		for i, file := range w.Files {
+			if strings.TrimSpace(file) == "" {
+				errs = append(errs, fmt.Errorf("watchlist.files[%d]: must not be empty", i))
+			}
+		}
+	}
+	if e := c.Export; e != nil {
+		if _, err := newExportOptions(e, formatSTIX, ""); err != nil {
+			errs = append(errs, fmt.Errorf("export.tlp: %v", err))
+		}
+	}
+	if c.Audit != nil && c.Audit.Path == "" {
+		errs = append(errs, fmt.Errorf("audit.path: is required"))
+	}
+	if t := c.Tracing; t != nil {
+		switch t.Exporter {
+		case exporterOTLP, exporterStdout:
+		default:
+			errs = append(errs, fmt.Errorf("tracing.exporter: unsupported exporter %q, must be one of otlp, stdout", t.Exporter))
+		}
+		if t.SampleRatio < 0 || t.SampleRatio > 1 {
+			errs = append(errs, fmt.Errorf("tracing.sample_ratio: must be between 0 and 1"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkBaseURL checks an optional API base URL
+func checkBaseURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil:
+		return err
+	case u.Scheme != "http" && u.Scheme != "https":
+		return fmt.Errorf("scheme must be http or https")
+	case u.Host == "":
+		return fmt.Errorf("host is required")
+	case u.RawQuery != "" || u.User != nil:
+		return fmt.Errorf("must not contain a query or credentials")
+	}
+	return nil
+}
+
+// unmarshalConfig decodes YAML files (.yaml/.yml) or JSON files, rejecting
+// unknown fields so typos are reported instead of silently ignored.
+// YAML is converted to JSON first, so both formats go through the same
+// decoder and the same json tags and UnmarshalJSON methods.
+func unmarshalConfig(path string, data []byte, config *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("failed to parse yaml config: %v", err)
+		}
+		converted, err := json.Marshal(normalizeYAML(raw))
+		if err != nil {
+			return fmt.Errorf("failed to parse yaml config: %v", err)
+		}
+		data = converted
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("failed to parse config: %v", err)
+	}
+	return nil
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values YAML produces
+// for non-string keys into maps JSON can encode
+func normalizeYAML(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = normalizeYAML(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			converted[fmt.Sprint(k)] = normalizeYAML(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeYAML(item)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// lookupEnv reads name from the environment, falling back to the file named by <name>_FILE
+func lookupEnv(name string) (string, bool, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true, nil
+	}
+	path, ok := os.LookupEnv(name + fileEnvSuffix)
+	if !ok || path == "" {
+		return "", false, nil
+	}
+	value, err := readSecret(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s%s: %v", name, fileEnvSuffix, err)
+	}
+	return value, true, nil
+}
+
+// readSecret reads a secret file and trims surrounding whitespace
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %v", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return value, nil
+}
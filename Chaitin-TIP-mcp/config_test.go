@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file into a temporary directory
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// The same config written as JSON and as YAML loads to the same value and
+// reports the same validation errors
+func TestLoadConfigYAMLJSONParity(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		yaml      string
+		wantError string
+	}{
+		{
+			name: "full config",
+			json: `{
+				"sk": "test-sk",
+				"base_url": "https://tip.example.com",
+				"reject_private_ips": true,
+				"endpoints": {"domain": "/api/domain"},
+				"batch": {"concurrency": 2, "rate": 1.5},
+				"cache": {"ttl": {"ip": "1h", "hash": 86400}, "negative_ttl": "5m"},
+				"providers": {"abuseipdb": {"api_key": "abuse-key", "timeout": "10s"}},
+				"watchlist": {"files": ["iocs.txt"]},
+				"export": {"tlp": "amber"},
+				"audit": {"path": "audit.log", "hash_chain": true}
+			}`,
+			yaml: `
+sk: test-sk
+base_url: https://tip.example.com
+reject_private_ips: true
+endpoints:
+  domain: /api/domain
+batch:
+  concurrency: 2
+  rate: 1.5
+cache:
+  ttl:
+    ip: 1h
+    hash: 86400
+  negative_ttl: 5m
+providers:
+  abuseipdb:
+    api_key: abuse-key
+    timeout: 10s
+watchlist:
+  files: [iocs.txt]
+export:
+  tlp: amber
+audit:
+  path: audit.log
+  hash_chain: true
+`,
+		},
+		{
+			// YAML decodes an unquoted true to a bool map key
+			name:      "non-string endpoint key",
+			json:      `{"sk": "test-sk", "endpoints": {"true": "/api/one"}}`,
+			yaml:      "sk: test-sk\nendpoints:\n  true: /api/one\n",
+			wantError: "endpoints.true: unknown indicator type",
+		},
+		{
+			name:      "invalid values",
+			json:      `{"batch": {"rate": -1}, "cache": {"ttl": {"ip": "-1m"}}}`,
+			yaml:      "batch:\n  rate: -1\ncache:\n  ttl:\n    ip: -1m\n",
+			wantError: "batch.rate: must not be negative",
+		},
+	}
+	t.Setenv(EnvSK, "")
+	os.Unsetenv(EnvSK)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromJSON, err := LoadConfig(writeConfig(t, "config.json", tt.json))
+			if err != nil {
+				t.Fatalf("LoadConfig(json): %v", err)
+			}
+			fromYAML, err := LoadConfig(writeConfig(t, "config.yaml", tt.yaml))
+			if err != nil {
+				t.Fatalf("LoadConfig(yaml): %v", err)
+			}
+			if !reflect.DeepEqual(fromJSON, fromYAML) {
+				t.Errorf("yaml config = %+v, want %+v", fromYAML, fromJSON)
+			}
+
+			jsonErr, yamlErr := fromJSON.Validate(), fromYAML.Validate()
+			if errorString(jsonErr) != errorString(yamlErr) {
+				t.Errorf("yaml Validate() = %v, json Validate() = %v", yamlErr, jsonErr)
+			}
+			if tt.wantError == "" && jsonErr != nil {
+				t.Errorf("Validate() = %v, want nil", jsonErr)
+			}
+			if tt.wantError != "" && !strings.Contains(errorString(jsonErr), tt.wantError) {
+				t.Errorf("Validate() = %v, want error containing %q", jsonErr, tt.wantError)
+			}
+		})
+	}
+}
+
+// Unknown fields are rejected in both formats
+func TestLoadConfigUnknownField(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.json": `{"sk": "test-sk", "sk_fle": "typo"}`,
+		"config.yml":  "sk: test-sk\nsk_fle: typo\n",
+	} {
+		if _, err := LoadConfig(writeConfig(t, name, content)); err == nil || !strings.Contains(err.Error(), "sk_fle") {
+			t.Errorf("LoadConfig(%s) error = %v, want unknown field sk_fle", name, err)
+		}
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,678 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/time/rate"
+)
+
+// Export formats
+const (
+	formatSTIX = "stix"
+	formatMISP = "misp"
+)
+
+const defaultExportOrganization = "Chaitin TIP MCP"
+
+// stixTimeFormat is the millisecond precision timestamp STIX 2.1 producers use
+const stixTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// stixNamespace is the UUIDv5 namespace STIX 2.1 defines for deterministic cyber observable IDs
+var stixNamespace = uuid.MustParse("00abedb4-aa42-466c-9c01-fed23315a9b7")
+
+// tlpMarkings are the TLP marking definitions predefined by STIX 2.1
+var tlpMarkings = map[string]string{
+	"white": "marking-definition--613f2e26-407d-48c7-9eca-b8e91df99dc9",
+	"green": "marking-definition--34098fce-860f-48ae-8e50-ebd3cc5e41da",
+	"amber": "marking-definition--f88d31f6-486f-44da-b317-01333bde0b82",
+	"red":   "marking-definition--5e57c739-391a-4eb3-b6be-7d15ca92d5ed",
+}
+
+// ExportConfig sets how exported documents identify their producer
+type ExportConfig struct {
+	Organization     string `json:"organization,omitempty"`      // Producer name in STIX identities and MISP events, defaults to "Chaitin TIP MCP"
+	TLP              string `json:"tlp,omitempty"`               // Default TLP marking: white, green, amber or red
+	IncludeWatchlist bool   `json:"include_watchlist,omitempty"` // Query the local watchlist too, putting its tags and comments in exported documents
+}
+
+// exportProviders returns the providers exports query. The watchlist holds
+// internal blocklists whose tags and verdicts are not meant for partners,
+// so it is only queried when export.include_watchlist is set.
+func exportProviders(providers []Provider, watch *watchlist, config *ExportConfig) []Provider {
+	if config == nil || !config.IncludeWatchlist {
+		return providers
+	}
+	return append(slices.Clip(providers), watch)
+}
+
+// exportOptions controls one export
+type exportOptions struct {
+	Format       string
+	Organization string
+	TLP          string
+}
+
+// newExportOptions applies the export config defaults
+func newExportOptions(config *ExportConfig, format, tlp string) (exportOptions, error) {
+	opts := exportOptions{Format: format, Organization: defaultExportOrganization, TLP: tlp}
+	if config != nil {
+		if config.Organization != "" {
+			opts.Organization = config.Organization
+		}
+		if opts.TLP == "" {
+			opts.TLP = config.TLP
+		}
+	}
+	if opts.Format != formatSTIX && opts.Format != formatMISP {
+		return opts, fmt.Errorf("unsupported format %q, must be one of stix, misp", opts.Format)
+	}
+	opts.TLP = strings.ToLower(opts.TLP)
+	if opts.TLP == "clear" {
+		opts.TLP = "white"
+	}
+	if _, ok := tlpMarkings[opts.TLP]; !ok && opts.TLP != "" {
+		return opts, fmt.Errorf("unsupported TLP %q, must be one of white, green, amber, red", opts.TLP)
+	}
+	return opts, nil
+}
+
+// exportResponse reports what was looked up alongside the exported document
+type exportResponse struct {
+	Summary  batchSummary `json:"summary"`
+	Invalid  []string     `json:"invalid,omitempty"`  // Input entries that are not IPs, domains or hashes
+	Reserved []string     `json:"reserved,omitempty"` // Listed private or reserved indicators, which are never exported
+	Skipped  int          `json:"skipped,omitempty"`  // Indicators beyond max_ips that were not looked up
+}
+
+// registerExportTool adds the export_intel tool
+func registerExportTool(s *server.MCPServer, providers []Provider, limits *batchLimits, config *ExportConfig) {
+	exportTool := mcp.NewTool("export_intel",
+		mcp.WithDescription("Look up IP addresses, domains and file hashes in every enabled threat intelligence provider "+
+			"and export the results as a STIX 2.1 bundle or a MISP event for sharing with partners. "+
+			"Malicious and suspicious indicators become STIX indicators with sightings, and MISP attributes flagged for IDS."),
+		mcp.WithString("format",
+			mcp.Required(),
+			mcp.Description("The document format"),
+			mcp.Enum(formatSTIX, formatMISP),
+		),
+		mcp.WithArray("indicators",
+			mcp.Description("IP addresses, domains or MD5/SHA-1/SHA-256 hashes to export"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithString("text",
+			mcp.Description("Free text to extract public IPs, domains and hashes from, such as an incident report"),
+		),
+		mcp.WithString("tlp",
+			mcp.Description("TLP marking for the document; defaults to export.tlp from the config"),
+			mcp.Enum("white", "green", "amber", "red"),
+		),
+		mcp.WithBoolean("force_refresh",
+			mcp.Description("Bypass the lookup cache and query the API for every indicator"),
+		),
+	)
+
+	s.AddTool(exportTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		format, _ := request.Params.Arguments["format"].(string)
+		tlp, _ := request.Params.Arguments["tlp"].(string)
+		opts, err := newExportOptions(config, format, tlp)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		var inputs []string
+		if items, ok := request.Params.Arguments["indicators"].([]interface{}); ok {
+			for _, item := range items {
+				if str, ok := item.(string); ok {
+					inputs = append(inputs, str)
+				}
+			}
+		}
+		text, _ := request.Params.Arguments["text"].(string)
+		items, invalid, reserved := collectExportIndicators(inputs, text)
+		if len(items) == 0 {
+			return toolError("no public IP addresses, domains or hashes found in the input"), nil
+		}
+
+		response := exportResponse{Invalid: invalid, Reserved: reserved}
+		if len(items) > limits.maxItems {
+			response.Skipped = len(items) - limits.maxItems
+			items = items[:limits.maxItems]
+		}
+
+		forceRefresh, _ := request.Params.Arguments["force_refresh"].(bool)
+		results := exportLookup(withForceRefresh(ctx, forceRefresh), providers, limits, items)
+		response.Summary = exportSummary(results)
+
+		document, err := buildExport(results, opts, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		jsonSummary, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format result: %v", err)
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{
+			mcp.NewTextContent(string(jsonSummary)),
+			mcp.NewTextContent(string(document)),
+		}}, nil
+	}))
+}
+
+// collectExportIndicators validates the listed indicators and extracts public
+// IPs, domains and hashes from the text, deduplicated in first-seen order.
+// Private and reserved indicators are never exported, whether listed or
+// found in the text; listed ones are returned in reserved with the reason.
+func collectExportIndicators(inputs []string, text string) (items []ioc, invalid, reserved []string) {
+	seen := make(map[ioc]bool)
+	add := func(item ioc) {
+		if !seen[item] {
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+
+	for _, input := range inputs {
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		value, kind, err := normalizeIndicator(refang(input), "")
+		if err != nil {
+			invalid = append(invalid, input)
+			continue
+		}
+		item := ioc{Type: kind, Value: value}
+		if reason := reservedReason(item); reason != "" {
+			reserved = append(reserved, fmt.Sprintf("%s (%s)", input, reason))
+			continue
+		}
+		add(item)
+	}
+	for _, found := range extractIOCs(refang(text)) {
+		switch found.Type {
+		case indicatorIP, indicatorDomain, indicatorHash:
+			if reservedReason(found) == "" {
+				add(found)
+			}
+		}
+	}
+	return items, invalid, reserved
+}
+
+// exportLookup queries every provider for each indicator within the batch
+// limits. Each indicator fans out to several providers, so the rate limit is
+// applied per provider request rather than per indicator: Chaitin waits on
+// the shared batch limiter only when a lookup misses its cache, and every
+// other provider gets a limiter of its own so it never spends the Chaitin
+// request budget.
+func exportLookup(ctx context.Context, providers []Provider, limits *batchLimits, items []ioc) []*multiResponse {
+	limited := make([]Provider, len(providers))
+	for i, provider := range providers {
+		switch provider := provider.(type) {
+		case *watchlist:
+			// The watchlist is local and costs no API request
+			limited[i] = provider
+		case *chaitinProvider:
+			limited[i] = &chaitinProvider{client: provider.client, limiter: limits.limiter}
+		default:
+			limiter := rate.NewLimiter(limits.limiter.Limit(), limits.limiter.Burst())
+			limited[i] = &limitedProvider{Provider: provider, limiter: limiter}
+		}
+	}
+	perItem := &batchLimits{concurrency: limits.concurrency, limiter: rate.NewLimiter(rate.Inf, 0), maxItems: limits.maxItems}
+
+	results := make([]*multiResponse, len(items))
+	perItem.run(ctx, len(items), func(i int) error {
+		results[i] = multiLookup(ctx, limited, items[i].Type, items[i].Value)
+		return nil
+	}, func(i int, err error) {
+		results[i] = &multiResponse{
+			Indicator: items[i].Value,
+			Type:      items[i].Type,
+			Verdict:   verdictUnknown,
+			Summary:   fmt.Sprintf("lookup failed: %v", err),
+		}
+	})
+	return results
+}
+
+// limitedProvider waits on a rate limiter before each lookup
+type limitedProvider struct {
+	Provider
+	limiter *rate.Limiter
+}
+
+func (p *limitedProvider) LookupIP(ctx context.Context, ip string) (*ProviderResult, error) {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return p.Provider.LookupIP(ctx, ip)
+}
+
+func (p *limitedProvider) LookupDomain(ctx context.Context, domain string) (*ProviderResult, error) {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return p.Provider.LookupDomain(ctx, domain)
+}
+
+func (p *limitedProvider) LookupHash(ctx context.Context, hash string) (*ProviderResult, error) {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return p.Provider.LookupHash(ctx, hash)
+}
+
+// exportSummary counts the results by verdict. Lookups where no provider
+// returned a verdict count as failed.
+func exportSummary(results []*multiResponse) batchSummary {
+	summary := batchSummary{Total: len(results)}
+	for _, result := range results {
+		summary.add(result.Verdict, len(result.Votes) == 0)
+		for _, source := range result.Sources {
+			if source.Provider == watchlistProvider && source.ProviderResult != nil && source.Note == "" {
+				summary.Watchlist++
+			}
+		}
+	}
+	return summary
+}
+
+// exportConfidence is the share of providers with an opinion that agree with
+// the merged verdict, as a 0-100 confidence
+func exportConfidence(result *multiResponse) int {
+	if result.Verdict == verdictUnknown {
+		return 0
+	}
+	total := 0
+	for verdict, providers := range result.Votes {
+		if verdict != verdictUnknown {
+			total += len(providers)
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return len(result.Votes[result.Verdict]) * 100 / total
+}
+
+// exportTags merges the tags reported by all sources, without duplicates
+func exportTags(result *multiResponse) []string {
+	var tags []string
+	for _, source := range result.Sources {
+		if source.ProviderResult == nil {
+			continue
+		}
+		for _, tag := range source.Tags {
+			if !containsFold(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// flagged reports whether a verdict should be shared as something to detect
+func flagged(verdict string) bool {
+	return verdict == verdictMalicious || verdict == verdictSuspicious
+}
+
+// buildExport renders the lookup results in the requested format
+func buildExport(results []*multiResponse, opts exportOptions, now time.Time) ([]byte, error) {
+	var document any
+	switch opts.Format {
+	case formatSTIX:
+		document = stixBundle(results, opts, now)
+	case formatMISP:
+		document = mispEvent(results, opts, now)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", opts.Format)
+	}
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format export: %v", err)
+	}
+	return data, nil
+}
+
+// stixProperties holds an exported STIX 2.1 object; the object types differ too much for one struct
+type stixProperties map[string]any
+
+// stixBundle builds a STIX 2.1 bundle. Every indicator becomes a cyber
+// observable with observed-data; malicious and suspicious ones also get an
+// indicator and a sighting carrying the confidence.
+func stixBundle(results []*multiResponse, opts exportOptions, now time.Time) stixProperties {
+	timestamp := now.UTC().Format(stixTimeFormat)
+	identity := stixProperties{
+		"type":           "identity",
+		"spec_version":   "2.1",
+		"id":             "identity--" + uuid.NewSHA1(stixNamespace, []byte(opts.Organization)).String(),
+		"created":        timestamp,
+		"modified":       timestamp,
+		"name":           opts.Organization,
+		"identity_class": "organization",
+	}
+	objects := []stixProperties{identity}
+
+	// common holds the properties shared by the objects this export creates
+	common := func(kind string) stixProperties {
+		object := stixProperties{
+			"type":           kind,
+			"spec_version":   "2.1",
+			"id":             kind + "--" + uuid.NewString(),
+			"created":        timestamp,
+			"modified":       timestamp,
+			"created_by_ref": identity["id"],
+		}
+		if marking, ok := tlpMarkings[opts.TLP]; ok {
+			object["object_marking_refs"] = []string{marking}
+		}
+		return object
+	}
+	if marking, ok := tlpMarkings[opts.TLP]; ok {
+		objects = append(objects, stixProperties{
+			"type":            "marking-definition",
+			"spec_version":    "2.1",
+			"id":              marking,
+			"created":         "2017-01-20T00:00:00.000Z",
+			"definition_type": "tlp",
+			"name":            "TLP:" + strings.ToUpper(opts.TLP),
+			"definition":      map[string]string{"tlp": opts.TLP},
+		})
+	}
+
+	for _, result := range results {
+		observable, pattern := stixObservable(result.Type, result.Indicator)
+		observedData := common("observed-data")
+		observedData["first_observed"] = timestamp
+		observedData["last_observed"] = timestamp
+		observedData["number_observed"] = 1
+		observedData["object_refs"] = []string{observable["id"].(string)}
+		objects = append(objects, observable, observedData)
+
+		if !flagged(result.Verdict) {
+			continue
+		}
+		confidence := exportConfidence(result)
+		indicatorType := "malicious-activity"
+		if result.Verdict == verdictSuspicious {
+			indicatorType = "anomalous-activity"
+		}
+		indicator := common("indicator")
+		indicator["name"] = fmt.Sprintf("%s %s %s", result.Verdict, result.Type, result.Indicator)
+		indicator["description"] = result.Summary
+		indicator["indicator_types"] = []string{indicatorType}
+		indicator["pattern"] = pattern
+		indicator["pattern_type"] = "stix"
+		indicator["valid_from"] = timestamp
+		indicator["confidence"] = confidence
+		if tags := exportTags(result); len(tags) > 0 {
+			indicator["labels"] = tags
+		}
+		var references []map[string]string
+		for _, source := range result.Sources {
+			if source.ProviderResult != nil && source.Link != "" {
+				references = append(references, map[string]string{"source_name": source.Provider, "url": source.Link})
+			}
+		}
+		if len(references) > 0 {
+			indicator["external_references"] = references
+		}
+
+		sighting := common("sighting")
+		sighting["sighting_of_ref"] = indicator["id"]
+		sighting["observed_data_refs"] = []string{observedData["id"].(string)}
+		sighting["where_sighted_refs"] = []string{identity["id"].(string)}
+		sighting["first_seen"] = timestamp
+		sighting["last_seen"] = timestamp
+		sighting["count"] = 1
+		sighting["confidence"] = confidence
+		sighting["description"] = result.Summary
+		objects = append(objects, indicator, sighting)
+	}
+
+	return stixProperties{
+		"type":    "bundle",
+		"id":      "bundle--" + uuid.NewString(),
+		"objects": objects,
+	}
+}
+
+// stixEscaper and stixUnescaper convert string constants in STIX patterns,
+// where \ and ' are escaped with a backslash
+var (
+	stixEscaper   = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	stixUnescaper = strings.NewReplacer(`\\`, `\`, `\'`, `'`)
+)
+
+// stixObservable returns the cyber observable for an indicator and the
+// pattern matching it. Observable IDs are deterministic as STIX 2.1
+// requires, so partners merge repeated exports of the same indicator.
+func stixObservable(kind, value string) (stixProperties, string) {
+	var object stixProperties
+	var pattern string
+	switch kind {
+	case indicatorIP:
+		scoType := "ipv4-addr"
+		if addr, err := netip.ParseAddr(value); err == nil && addr.Is6() {
+			scoType = "ipv6-addr"
+		}
+		object = stixProperties{"type": scoType, "value": value}
+		pattern = fmt.Sprintf("[%s:value = '%s']", scoType, stixEscaper.Replace(value))
+	case indicatorDomain:
+		object = stixProperties{"type": "domain-name", "value": value}
+		pattern = fmt.Sprintf("[domain-name:value = '%s']", stixEscaper.Replace(value))
+	case indicatorHash:
+		algorithm := hashAlgorithm(value)
+		object = stixProperties{"type": "file", "hashes": map[string]string{algorithm: value}}
+		pattern = fmt.Sprintf("[file:hashes.'%s' = '%s']", algorithm, stixEscaper.Replace(value))
+	}
+
+	// The ID contributing properties are value for addresses and domains, hashes for files
+	contributing := make(stixProperties)
+	for key, v := range object {
+		if key != "type" {
+			contributing[key] = v
+		}
+	}
+	canonical, _ := json.Marshal(contributing)
+	object["spec_version"] = "2.1"
+	object["id"] = object["type"].(string) + "--" + uuid.NewSHA1(stixNamespace, canonical).String()
+	return object, pattern
+}
+
+// hashAlgorithm names a hash by its length, as STIX hash keys
+func hashAlgorithm(hash string) string {
+	switch len(hash) {
+	case 32:
+		return "MD5"
+	case 40:
+		return "SHA-1"
+	default:
+		return "SHA-256"
+	}
+}
+
+// mispAttribute is an attribute of a MISP event
+type mispAttribute struct {
+	UUID         string    `json:"uuid"`
+	Type         string    `json:"type"`
+	Category     string    `json:"category"`
+	Value        string    `json:"value"`
+	ToIDS        bool      `json:"to_ids"`
+	Comment      string    `json:"comment,omitempty"`
+	Timestamp    string    `json:"timestamp"`
+	Distribution string    `json:"distribution"`
+	Tag          []mispTag `json:"Tag,omitempty"`
+}
+
+type mispTag struct {
+	Name string `json:"name"`
+}
+
+type mispOrg struct {
+	Name string `json:"name"`
+}
+
+// mispEventBody is a MISP event in the format accepted by the events/add API and JSON import
+type mispEventBody struct {
+	UUID          string          `json:"uuid"`
+	Info          string          `json:"info"`
+	Date          string          `json:"date"`
+	Timestamp     string          `json:"timestamp"`
+	ThreatLevelID string          `json:"threat_level_id"` // 1 high, 2 medium, 3 low, 4 undefined
+	Analysis      string          `json:"analysis"`        // 2 completed
+	Distribution  string          `json:"distribution"`    // 0 your organisation only
+	Published     bool            `json:"published"`
+	Orgc          mispOrg         `json:"Orgc"`
+	Tag           []mispTag       `json:"Tag,omitempty"`
+	Attribute     []mispAttribute `json:"Attribute"`
+}
+
+type mispEventDocument struct {
+	Event mispEventBody `json:"Event"`
+}
+
+// mispEvent builds a MISP event with one attribute per indicator. Malicious
+// and suspicious attributes are flagged for IDS; verdict and confidence are
+// recorded as machine tags.
+func mispEvent(results []*multiResponse, opts exportOptions, now time.Time) mispEventDocument {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	event := mispEventBody{
+		UUID:          uuid.NewString(),
+		Info:          fmt.Sprintf("Threat intelligence lookup results (%d indicators)", len(results)),
+		Date:          now.UTC().Format(time.DateOnly),
+		Timestamp:     timestamp,
+		ThreatLevelID: "3",
+		Analysis:      "2",
+		Distribution:  "0",
+		Orgc:          mispOrg{Name: opts.Organization},
+		Attribute:     []mispAttribute{},
+	}
+	if opts.TLP != "" {
+		event.Tag = []mispTag{{Name: "tlp:" + opts.TLP}}
+	}
+
+	for _, result := range results {
+		switch {
+		case result.Verdict == verdictMalicious:
+			event.ThreatLevelID = "1"
+		case result.Verdict == verdictSuspicious && event.ThreatLevelID != "1":
+			event.ThreatLevelID = "2"
+		}
+
+		attribute := mispAttribute{
+			UUID:         uuid.NewString(),
+			Value:        result.Indicator,
+			ToIDS:        flagged(result.Verdict),
+			Comment:      result.Summary,
+			Timestamp:    timestamp,
+			Distribution: "5", // Inherit from the event
+			Tag: []mispTag{
+				{Name: fmt.Sprintf("chaitin-tip:verdict=%q", result.Verdict)},
+				{Name: fmt.Sprintf("chaitin-tip:confidence=%q", strconv.Itoa(exportConfidence(result)))},
+			},
+		}
+		switch result.Type {
+		case indicatorIP:
+			attribute.Type, attribute.Category = "ip-dst", "Network activity"
+		case indicatorDomain:
+			attribute.Type, attribute.Category = "domain", "Network activity"
+		case indicatorHash:
+			attribute.Type, attribute.Category = strings.ToLower(strings.ReplaceAll(hashAlgorithm(result.Indicator), "-", "")), "Payload delivery"
+		}
+		for _, tag := range exportTags(result) {
+			attribute.Tag = append(attribute.Tag, mispTag{Name: tag})
+		}
+		event.Attribute = append(event.Attribute, attribute)
+	}
+	return mispEventDocument{Event: event}
+}
+
+// runExport implements "export -format stix|misp [-o path] [-tlp level] [-input path] [indicator ...]"
+func runExport(config *Config, args []string) error {
+	exportFlags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := exportFlags.String("format", formatSTIX, "Document format: stix or misp")
+	output := exportFlags.String("o", "", "Write the document to this file instead of stdout")
+	tlp := exportFlags.String("tlp", "", "TLP marking: white, green, amber or red (defaults to export.tlp from the config)")
+	input := exportFlags.String("input", "", "Read indicators from this file, or - for stdin; any text is accepted")
+	if err := exportFlags.Parse(args); err != nil {
+		return err
+	}
+
+	opts, err := newExportOptions(config.Export, *format, *tlp)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	if *input != "" {
+		var data []byte
+		if *input == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(*input)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %v", err)
+		}
+		text = string(data)
+	}
+	items, invalid, reserved := collectExportIndicators(exportFlags.Args(), text)
+	for _, entry := range invalid {
+		fmt.Fprintf(os.Stderr, "Skipping invalid indicator %q\n", entry)
+	}
+	for _, entry := range reserved {
+		fmt.Fprintf(os.Stderr, "Skipping private or reserved indicator %s\n", entry)
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no public IP addresses, domains or hashes to export")
+	}
+
+	client, err := NewClient(config)
+	if err != nil {
+		return fmt.Errorf("failed to create Chaitin API client: %v", err)
+	}
+	defer client.Close()
+	watch, err := loadWatchlist(config.Watchlist)
+	if err != nil {
+		return fmt.Errorf("failed to load watchlist: %v", err)
+	}
+	providers, err := newProviders(client, config.Providers)
+	if err != nil {
+		return fmt.Errorf("failed to set up providers: %v", err)
+	}
+
+	// The CLI has no max_ips cap; the rate limit still applies
+	limits := newBatchLimits(config.Batch)
+	results := exportLookup(context.Background(), exportProviders(providers, watch, config.Export), limits, items)
+
+	document, err := buildExport(results, opts, time.Now())
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(append(document, '\n'))
+		return err
+	}
+	if err := os.WriteFile(*output, document, 0600); err != nil {
+		return fmt.Errorf("failed to write export: %v", err)
+	}
+
+	summary := exportSummary(results)
+	fmt.Fprintf(os.Stderr, "Exported %d indicators to %s (%d malicious, %d suspicious)\n",
+		len(results), *output, summary.Malicious, summary.Suspicious)
+	return nil
+}
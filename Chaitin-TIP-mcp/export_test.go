@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestStixObservable(t *testing.T) {
+	tests := []struct {
+		kind    string
+		value   string
+		scoType string
+		pattern string
+	}{
+		{indicatorIP, "1.2.3.4", "ipv4-addr", `[ipv4-addr:value = '1.2.3.4']`},
+		{indicatorIP, "2001:db8::1", "ipv6-addr", `[ipv6-addr:value = '2001:db8::1']`},
+		{indicatorDomain, "evil.com", "domain-name", `[domain-name:value = 'evil.com']`},
+		{indicatorHash, "d41d8cd98f00b204e9800998ecf8427e", "file", `[file:hashes.'MD5' = 'd41d8cd98f00b204e9800998ecf8427e']`},
+		// Quotes and backslashes in values are escaped
+		{indicatorDomain, `it's\evil`, "domain-name", `[domain-name:value = 'it\'s\\evil']`},
+	}
+	for _, tt := range tests {
+		object, pattern := stixObservable(tt.kind, tt.value)
+		if object["type"] != tt.scoType || pattern != tt.pattern {
+			t.Errorf("stixObservable(%s, %q) = %v, %s, want %s, %s", tt.kind, tt.value, object["type"], pattern, tt.scoType, tt.pattern)
+		}
+		// IDs are deterministic so repeated exports merge
+		if again, _ := stixObservable(tt.kind, tt.value); again["id"] != object["id"] {
+			t.Errorf("stixObservable(%q) ids differ: %v, %v", tt.value, object["id"], again["id"])
+		}
+		// The watchlist reads exported patterns back
+		if match := stixValueRe.FindStringSubmatch(pattern); match != nil && stixUnescaper.Replace(match[2]) != tt.value {
+			t.Errorf("pattern %s reads back as %q", pattern, stixUnescaper.Replace(match[2]))
+		}
+	}
+}
+
+// exportResults is a malicious IP and a clean domain as merged by multiLookup
+func exportResults() []*multiResponse {
+	return []*multiResponse{
+		{
+			Indicator: "1.2.3.4",
+			Type:      indicatorIP,
+			Verdict:   verdictMalicious,
+			Summary:   "malicious by chaitin",
+			Votes:     map[string][]string{verdictMalicious: {"chaitin"}, verdictClean: {"abuseipdb"}},
+			Sources: []sourceResult{
+				{Provider: "chaitin", ProviderResult: &ProviderResult{Verdict: verdictMalicious, Tags: []string{"scanner"}, Link: "https://x.threatbook.cn/1.2.3.4"}},
+				{Provider: "abuseipdb", ProviderResult: &ProviderResult{Verdict: verdictClean, Tags: []string{"Scanner"}}},
+			},
+		},
+		{
+			Indicator: "example.org",
+			Type:      indicatorDomain,
+			Verdict:   verdictClean,
+			Votes:     map[string][]string{verdictClean: {"chaitin"}},
+			Sources:   []sourceResult{{Provider: "chaitin", ProviderResult: &ProviderResult{Verdict: verdictClean}}},
+		},
+	}
+}
+
+func TestExportSTIX(t *testing.T) {
+	data, err := buildExport(exportResults(), exportOptions{Format: formatSTIX, Organization: "SOC", TLP: "amber"}, time.Now())
+	if err != nil {
+		t.Fatalf("buildExport: %v", err)
+	}
+	var bundle struct {
+		Type    string           `json:"type"`
+		Objects []map[string]any `json:"objects"`
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if bundle.Type != "bundle" {
+		t.Errorf("type = %q", bundle.Type)
+	}
+
+	var types []string
+	var indicator map[string]any
+	for _, object := range bundle.Objects {
+		types = append(types, object["type"].(string))
+		if object["type"] == "indicator" {
+			indicator = object
+		}
+	}
+	want := []string{"identity", "marking-definition", "ipv4-addr", "observed-data", "indicator", "sighting", "domain-name", "observed-data"}
+	if !slices.Equal(types, want) {
+		t.Fatalf("object types = %v, want %v", types, want)
+	}
+	if indicator["pattern"] != "[ipv4-addr:value = '1.2.3.4']" || indicator["confidence"] != float64(50) {
+		t.Errorf("indicator = %v", indicator)
+	}
+	if labels, _ := json.Marshal(indicator["labels"]); string(labels) != `["scanner"]` {
+		t.Errorf("labels = %s, want tags merged case-insensitively", labels)
+	}
+	if refs, _ := json.Marshal(indicator["object_marking_refs"]); string(refs) != `["`+tlpMarkings["amber"]+`"]` {
+		t.Errorf("object_marking_refs = %s", refs)
+	}
+}
+
+func TestExportMISP(t *testing.T) {
+	data, err := buildExport(exportResults(), exportOptions{Format: formatMISP, Organization: "SOC", TLP: "green"}, time.Now())
+	if err != nil {
+		t.Fatalf("buildExport: %v", err)
+	}
+	var document mispEventDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	event := document.Event
+	if event.ThreatLevelID != "1" || event.Orgc.Name != "SOC" || len(event.Tag) != 1 || event.Tag[0].Name != "tlp:green" {
+		t.Errorf("event = %+v", event)
+	}
+
+	tests := []struct {
+		typ      string
+		category string
+		toIDS    bool
+		verdict  string
+	}{
+		{"ip-dst", "Network activity", true, `chaitin-tip:verdict="malicious"`},
+		{"domain", "Network activity", false, `chaitin-tip:verdict="clean"`},
+	}
+	if len(event.Attribute) != len(tests) {
+		t.Fatalf("got %d attributes, want %d", len(event.Attribute), len(tests))
+	}
+	for i, tt := range tests {
+		attribute := event.Attribute[i]
+		if attribute.Type != tt.typ || attribute.Category != tt.category || attribute.ToIDS != tt.toIDS || attribute.Tag[0].Name != tt.verdict {
+			t.Errorf("attribute %d = %+v", i, attribute)
+		}
+	}
+}
+
+// countingProvider counts lookups and reports every indicator as clean
+type countingProvider struct {
+	name  string
+	calls atomic.Int32
+}
+
+func (p *countingProvider) Name() string { return p.name }
+
+func (p *countingProvider) LookupIP(ctx context.Context, ip string) (*ProviderResult, error) {
+	p.calls.Add(1)
+	return &ProviderResult{Provider: p.name, Verdict: verdictClean}, nil
+}
+
+func (p *countingProvider) LookupDomain(ctx context.Context, domain string) (*ProviderResult, error) {
+	return p.LookupIP(ctx, domain)
+}
+
+func (p *countingProvider) LookupHash(ctx context.Context, hash string) (*ProviderResult, error) {
+	return p.LookupIP(ctx, hash)
+}
+
+// Each provider request takes a token from that provider's own limiter, not
+// each indicator, and Chaitin cache hits take no token at all
+func TestExportLookupRateLimit(t *testing.T) {
+	client, err := NewClient(&Config{SK: "test-sk", Cache: &CacheConfig{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := []ioc{{indicatorIP, "1.1.1.1"}, {indicatorIP, "2.2.2.2"}, {indicatorIP, "3.3.3.3"}}
+	for _, item := range items {
+		client.cache.put(indicatorIP, item.Value, &ChaitinResponse{Data: map[string]any{"reputation": "white"}}, nil)
+	}
+	first, second := &countingProvider{name: "first"}, &countingProvider{name: "second"}
+	watch := &watchlist{entries: make(map[string][]*WatchEntry)}
+	limits := &batchLimits{concurrency: 1, limiter: rate.NewLimiter(rate.Every(time.Hour), 2), maxItems: 10}
+
+	// Waiting an hour for the next token would pass the deadline, so Wait fails at once
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	providers := []Provider{&chaitinProvider{client: client}, first, second, watch}
+	results := exportLookup(ctx, providers, limits, items)
+
+	if calls := first.calls.Load(); calls != 2 {
+		t.Errorf("first provider requests = %d, want 2 (its limiter burst)", calls)
+	}
+	if calls := second.calls.Load(); calls != 2 {
+		t.Errorf("second provider requests = %d, want 2 (its limiter burst)", calls)
+	}
+	failed := make(map[string]int)
+	for _, result := range results {
+		for _, source := range result.Sources {
+			if source.Error != "" {
+				failed[source.Provider]++
+			}
+		}
+	}
+	if want := map[string]int{"first": 1, "second": 1}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("failed provider requests = %v, want %v", failed, want)
+	}
+	if tokens := limits.limiter.Tokens(); tokens < 2 {
+		t.Errorf("Chaitin limiter tokens = %.1f, want 2: cache hits and other providers must not spend them", tokens)
+	}
+}
+
+// Listed private and reserved indicators are reported instead of exported,
+// the same as ones found in the text
+func TestCollectExportIndicators(t *testing.T) {
+	items, invalid, reserved := collectExportIndicators(
+		[]string{"8.8.8.8", "10.0.0.1", "printer.local", "not an indicator", "evil[.]com"},
+		"seen from 192.168.1.5 and 1.2.3.4",
+	)
+	want := []ioc{{indicatorIP, "8.8.8.8"}, {indicatorDomain, "evil.com"}, {indicatorIP, "1.2.3.4"}}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items = %v, want %v", items, want)
+	}
+	if want := []string{"not an indicator"}; !reflect.DeepEqual(invalid, want) {
+		t.Errorf("invalid = %v, want %v", invalid, want)
+	}
+	if want := []string{"10.0.0.1 (private address)", "printer.local (reserved domain)"}; !reflect.DeepEqual(reserved, want) {
+		t.Errorf("reserved = %v, want %v", reserved, want)
+	}
+}
+
+// The watchlist only reaches exported documents when include_watchlist is set
+func TestExportProvidersWatchlist(t *testing.T) {
+	watch := &watchlist{entries: make(map[string][]*WatchEntry)}
+	watch.insert(&WatchEntry{Type: indicatorIP, Value: "1.1.1.1", Verdict: verdictMalicious, Tags: []string{"internal-blocklist"}, Source: watchSourceManual})
+	providers := []Provider{&countingProvider{name: "chaitin"}}
+	limits := &batchLimits{concurrency: 1, limiter: rate.NewLimiter(rate.Inf, 1), maxItems: 10}
+	items := []ioc{{indicatorIP, "1.1.1.1"}}
+	opts := exportOptions{Format: formatSTIX, Organization: defaultExportOrganization}
+
+	tests := []struct {
+		name    string
+		config  *ExportConfig
+		leaked  bool
+		verdict string
+	}{
+		{"no config", nil, false, verdictClean},
+		{"default", &ExportConfig{}, false, verdictClean},
+		{"included", &ExportConfig{IncludeWatchlist: true}, true, verdictMalicious},
+	}
+	for _, tt := range tests {
+		results := exportLookup(context.Background(), exportProviders(providers, watch, tt.config), limits, items)
+		if results[0].Verdict != tt.verdict {
+			t.Errorf("%s: verdict = %s, want %s", tt.name, results[0].Verdict, tt.verdict)
+		}
+		document, err := buildExport(results, opts, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, internal := range []string{"internal-blocklist", "watchlist"} {
+			if got := strings.Contains(string(document), internal); got != tt.leaked {
+				t.Errorf("%s: document contains %q = %v, want %v", tt.name, internal, got, tt.leaked)
+			}
+		}
+	}
+	if len(providers) != 1 {
+		t.Errorf("exportProviders changed the caller's providers: %v", providers)
+	}
+}
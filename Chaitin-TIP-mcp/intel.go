@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IPIntel is the normalized threat intelligence for an IP address. Chaitin
+// does not publish a schema for the response data, so each field is read
+// from one fixed name and everything else is passed through in Extra.
+type IPIntel struct {
+	IP         string         `json:"ip"`
+	Verdict    string         `json:"verdict"`              // malicious, suspicious, clean or unknown
+	Reputation string         `json:"reputation,omitempty"` // Raw reputation label from the API
+	Confidence *int           `json:"confidence,omitempty"` // 0-100
+	Categories []string       `json:"categories,omitempty"` // Threat categories, e.g. scanner, botnet
+	Tags       []string       `json:"tags,omitempty"`
+	Geo        *GeoLocation   `json:"geo,omitempty"`
+	ASN        *ASNInfo       `json:"asn,omitempty"`
+	FirstSeen  *time.Time     `json:"first_seen,omitempty"`
+	LastSeen   *time.Time     `json:"last_seen,omitempty"`
+	Extra      map[string]any `json:"extra,omitempty"`     // Fields not covered above
+	Watchlist  []WatchEntry   `json:"watchlist,omitempty"` // Matching local watchlist entries
+}
+
+// GeoLocation is where an IP address is located
+type GeoLocation struct {
+	Country     string   `json:"country,omitempty"`
+	CountryCode string   `json:"country_code,omitempty"`
+	Region      string   `json:"region,omitempty"`
+	City        string   `json:"city,omitempty"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+}
+
+// ASNInfo is the autonomous system an IP address belongs to
+type ASNInfo struct {
+	Number       int    `json:"number,omitempty"`
+	Organization string `json:"organization,omitempty"`
+}
+
+// Fields of the response data that are normalized
+const (
+	reputationKey = "reputation"
+	confidenceKey = "confidence"
+	categoriesKey = "categories"
+	tagsKey       = "tags"
+	locationKey   = "location"
+	asnKey        = "asn"
+	asOrgKey      = "as_org"
+	firstSeenKey  = "first_seen"
+	lastSeenKey   = "last_seen"
+)
+
+// Keys that repeat what the caller already knows and are left out of Extra
+var ignoredKeys = []string{"ip"}
+
+// Time layouts accepted in first/last seen fields
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// parseIPIntel normalizes an IP lookup response
+func parseIPIntel(ip string, resp *ChaitinIPResponse) *IPIntel {
+	intel := &IPIntel{IP: ip, Verdict: responseVerdict(resp)}
+	if resp == nil || resp.Data == nil {
+		return intel
+	}
+	data := resp.Data
+	used := make(map[string]bool)
+	for _, key := range ignoredKeys {
+		used[key] = true
+	}
+
+	intel.Reputation = stringField(data, used, reputationKey)
+	if value, ok := numberField(data, used, confidenceKey); ok {
+		confidence := int(math.Round(math.Min(math.Max(value, 0), 100)))
+		intel.Confidence = &confidence
+	}
+	intel.Categories = listField(data, used, categoriesKey)
+	intel.Tags = listField(data, used, tagsKey)
+
+	// Location fields may be nested in a location object or sit at the top
+	// level. Keys consumed from the nested object are tracked separately so
+	// that a top-level key of the same name still ends up in Extra.
+	location, locationUsed := data, used
+	if nested, ok := data[locationKey].(map[string]any); ok {
+		location, locationUsed = nested, make(map[string]bool)
+		used[locationKey] = true
+	}
+	geo := &GeoLocation{
+		Country:     stringField(location, locationUsed, "country"),
+		CountryCode: strings.ToUpper(stringField(location, locationUsed, "country_code")),
+		Region:      stringField(location, locationUsed, "region"),
+		City:        stringField(location, locationUsed, "city"),
+	}
+	if value, ok := numberField(location, locationUsed, "latitude"); ok {
+		geo.Latitude = &value
+	}
+	if value, ok := numberField(location, locationUsed, "longitude"); ok {
+		geo.Longitude = &value
+	}
+	if *geo != (GeoLocation{}) {
+		intel.Geo = geo
+	}
+
+	asn := &ASNInfo{Organization: stringField(data, used, asOrgKey)}
+	if nested, ok := data[asnKey].(map[string]any); ok {
+		used[asnKey] = true
+		nestedUsed := make(map[string]bool)
+		if value, ok := numberField(nested, nestedUsed, "number"); ok {
+			asn.Number = int(value)
+		} else {
+			asn.Number = asNumber(stringField(nested, nestedUsed, "number"))
+		}
+		if asn.Organization == "" {
+			asn.Organization = stringField(nested, nestedUsed, "organization")
+		}
+	} else if value, ok := numberField(data, used, asnKey); ok {
+		asn.Number = int(value)
+	} else if raw := stringField(data, used, asnKey); raw != "" {
+		// The number may be followed by the organization, e.g. "AS15169 Google LLC"
+		fields := strings.Fields(raw)
+		asn.Number = asNumber(fields[0])
+		if asn.Organization == "" && len(fields) > 1 {
+			asn.Organization = strings.Join(fields[1:], " ")
+		}
+	}
+	if *asn != (ASNInfo{}) {
+		intel.ASN = asn
+	}
+
+	intel.FirstSeen = timeField(data, used, firstSeenKey)
+	intel.LastSeen = timeField(data, used, lastSeenKey)
+
+	for key, value := range data {
+		if !used[key] {
+			if intel.Extra == nil {
+				intel.Extra = make(map[string]any)
+			}
+			intel.Extra[key] = value
+		}
+	}
+	return intel
+}
+
+// Summary describes the intelligence in a few lines of plain text
+func (i *IPIntel) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", i.IP, i.Verdict)
+	if i.Reputation != "" && !strings.EqualFold(i.Reputation, i.Verdict) {
+		fmt.Fprintf(&b, " (reputation %s)", i.Reputation)
+	}
+	if i.Confidence != nil {
+		fmt.Fprintf(&b, ", confidence %d%%", *i.Confidence)
+	}
+	b.WriteString("\n")
+
+	if len(i.Categories) > 0 {
+		fmt.Fprintf(&b, "Categories: %s\n", strings.Join(i.Categories, ", "))
+	}
+	if len(i.Tags) > 0 {
+		fmt.Fprintf(&b, "Tags: %s\n", strings.Join(i.Tags, ", "))
+	}
+	if i.Geo != nil {
+		var parts []string
+		for _, part := range []string{i.Geo.City, i.Geo.Region, i.Geo.Country} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == 0 {
+			parts = append(parts, i.Geo.CountryCode)
+		}
+		fmt.Fprintf(&b, "Location: %s\n", strings.Join(parts, ", "))
+	}
+	if i.ASN != nil {
+		var parts []string
+		if i.ASN.Number != 0 {
+			parts = append(parts, fmt.Sprintf("AS%d", i.ASN.Number))
+		}
+		if i.ASN.Organization != "" {
+			parts = append(parts, i.ASN.Organization)
+		}
+		fmt.Fprintf(&b, "Network: %s\n", strings.Join(parts, " "))
+	}
+	if i.FirstSeen != nil {
+		fmt.Fprintf(&b, "First seen: %s\n", i.FirstSeen.Format(time.DateOnly))
+	}
+	if i.LastSeen != nil {
+		fmt.Fprintf(&b, "Last seen: %s\n", i.LastSeen.Format(time.DateOnly))
+	}
+	if len(i.Watchlist) > 0 {
+		fmt.Fprintf(&b, "%s\n", watchSummary(i.Watchlist))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// stringField returns a non-empty string or number field as a string
+func stringField(data map[string]any, used map[string]bool, key string) string {
+	switch value := data[key].(type) {
+	case string:
+		if value = strings.TrimSpace(value); value != "" {
+			used[key] = true
+			return value
+		}
+	case float64:
+		used[key] = true
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return ""
+}
+
+// numberField returns a number field, accepting numeric strings
+func numberField(data map[string]any, used map[string]bool, key string) (float64, bool) {
+	switch value := data[key].(type) {
+	case float64:
+		used[key] = true
+		return value, true
+	case string:
+		if number, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64); err == nil {
+			used[key] = true
+			return number, true
+		}
+	}
+	return 0, false
+}
+
+// listField returns a deduplicated, sorted string list. The list may hold
+// strings or objects with a name, and a single string may be comma separated.
+func listField(data map[string]any, used map[string]bool, key string) []string {
+	seen := make(map[string]bool)
+	var list []string
+	add := func(value string) {
+		value = strings.TrimSpace(value)
+		if value != "" && !seen[strings.ToLower(value)] {
+			seen[strings.ToLower(value)] = true
+			list = append(list, value)
+		}
+	}
+	switch value := data[key].(type) {
+	case string:
+		used[key] = true
+		for _, item := range strings.Split(value, ",") {
+			add(item)
+		}
+	case []any:
+		used[key] = true
+		for _, item := range value {
+			switch item := item.(type) {
+			case string:
+				add(item)
+			case map[string]any:
+				if name, ok := item["name"].(string); ok {
+					add(name)
+				}
+			}
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// timeField returns a time field in one of the common layouts or as a Unix
+// timestamp in seconds or milliseconds
+func timeField(data map[string]any, used map[string]bool, key string) *time.Time {
+	var t time.Time
+	switch value := data[key].(type) {
+	case float64:
+		if value <= 0 {
+			return nil
+		}
+		if value > 1e12 {
+			t = time.UnixMilli(int64(value))
+		} else {
+			t = time.Unix(int64(value), 0)
+		}
+	case string:
+		for _, layout := range timeLayouts {
+			if parsed, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+				t = parsed
+				break
+			}
+		}
+	}
+	if t.IsZero() {
+		return nil
+	}
+	used[key] = true
+	t = t.UTC()
+	return &t
+}
+
+// asNumber parses an AS number such as "AS13335" or "13335"
+func asNumber(value string) int {
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "AS")
+	if number, err := strconv.Atoi(value); err == nil {
+		return number
+	}
+	return 0
+}
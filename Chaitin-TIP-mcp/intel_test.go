@@ -0,0 +1,129 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseIPIntel(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+	timePtr := func(v time.Time) *time.Time { return &v }
+
+	tests := []struct {
+		name string
+		data map[string]any
+		want *IPIntel
+	}{
+		{
+			name: "top level fields",
+			data: map[string]any{
+				"ip":           "1.2.3.4",
+				"reputation":   "black",
+				"confidence":   "85%",
+				"categories":   "scanner, brute force",
+				"tags":         []any{"botnet", map[string]any{"name": "Botnet"}, "c2"},
+				"country":      "China",
+				"country_code": "cn",
+				"city":         "Beijing",
+				"asn":          "AS4134 Chinanet",
+				"first_seen":   "2023-01-02 03:04:05",
+				"last_seen":    float64(1700000000000),
+			},
+			want: &IPIntel{
+				IP:         "1.2.3.4",
+				Verdict:    verdictMalicious,
+				Reputation: "black",
+				Confidence: intPtr(85),
+				Categories: []string{"brute force", "scanner"},
+				Tags:       []string{"botnet", "c2"},
+				Geo:        &GeoLocation{Country: "China", CountryCode: "CN", City: "Beijing"},
+				ASN:        &ASNInfo{Number: 4134, Organization: "Chinanet"},
+				FirstSeen:  timePtr(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)),
+				LastSeen:   timePtr(time.UnixMilli(1700000000000).UTC()),
+			},
+		},
+		{
+			name: "nested location and asn",
+			data: map[string]any{
+				"location": map[string]any{"country": "Japan", "latitude": 35.6, "longitude": "139.7"},
+				"country":  "ignored",
+				"asn":      map[string]any{"number": "AS2497", "organization": "IIJ"},
+				"as_org":   "Internet Initiative Japan",
+			},
+			want: &IPIntel{
+				IP:      "1.2.3.4",
+				Verdict: verdictUnknown,
+				Geo:     &GeoLocation{Country: "Japan", Latitude: floatPtr(35.6), Longitude: floatPtr(139.7)},
+				ASN:     &ASNInfo{Number: 2497, Organization: "Internet Initiative Japan"},
+				Extra:   map[string]any{"country": "ignored"},
+			},
+		},
+		{
+			// Names other than the documented ones are not treated as aliases
+			name: "unknown names stay in extra",
+			data: map[string]any{
+				"confidence":  float64(140),
+				"score":       float64(7),
+				"credibility": float64(60),
+				"labels":      []any{"proxy"},
+				"update_time": "2024-01-01",
+			},
+			want: &IPIntel{
+				IP:         "1.2.3.4",
+				Verdict:    verdictUnknown,
+				Confidence: intPtr(100),
+				Extra: map[string]any{
+					"score":       float64(7),
+					"credibility": float64(60),
+					"labels":      []any{"proxy"},
+					"update_time": "2024-01-01",
+				},
+			},
+		},
+		{
+			// Only reputation decides the verdict, other level fields are passed through
+			name: "verdict from reputation only",
+			data: map[string]any{
+				"reputation":   "white",
+				"threat_level": "high",
+				"severity":     "critical",
+				"is_malicious": true,
+			},
+			want: &IPIntel{
+				IP:         "1.2.3.4",
+				Verdict:    verdictClean,
+				Reputation: "white",
+				Extra: map[string]any{
+					"threat_level": "high",
+					"severity":     "critical",
+					"is_malicious": true,
+				},
+			},
+		},
+		{
+			// A low risk label is not evidence that the address is clean
+			name: "risk level is not a verdict",
+			data: map[string]any{"reputation": "low", "risk_level": "low"},
+			want: &IPIntel{
+				IP:         "1.2.3.4",
+				Verdict:    verdictUnknown,
+				Reputation: "low",
+				Extra:      map[string]any{"risk_level": "low"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseIPIntel("1.2.3.4", &ChaitinIPResponse{Data: tt.data})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIPIntel() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := parseIPIntel("1.2.3.4", nil); got.Verdict != verdictUnknown || got.Geo != nil {
+		t.Errorf("parseIPIntel(nil) = %+v", got)
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/net/publicsuffix"
+)
+
+// indicatorCIDR is extracted from text but not looked up, the API only accepts single addresses
+const indicatorCIDR = "cidr"
+
+var (
+	// Defanged forms seen in threat reports and alert emails
+	defangReplacer = strings.NewReplacer(
+		"[.]", ".", "(.)", ".", "{.}", ".",
+		"[dot]", ".", "(dot)", ".", "{dot}", ".", "[DOT]", ".", "(DOT)", ".",
+		"[:]", ":", "[://]", "://", "[/]", "/", "[@]", "@",
+	)
+	defangSchemeRe = regexp.MustCompile(`(?i)\b(?:hxxp|hxtp|htxp|h\[tt\]p|meow)(s?)://`)
+	defangFTPRe    = regexp.MustCompile(`(?i)\bfxp://`)
+
+	urlRe    = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s"'<>\[\]{}|\\^` + "`" + `]+`)
+	hashRe   = regexp.MustCompile(`\b(?:[a-fA-F0-9]{64}|[a-fA-F0-9]{40}|[a-fA-F0-9]{32})\b`)
+	cidr4Re  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}/\d{1,2}\b`)
+	cidr6Re  = regexp.MustCompile(`[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}/\d{1,3}\b`)
+	domainRe = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{1,62}\b`)
+
+	// jsonKeyEndRe matches the closing quote and colon after a JSON object key,
+	// also when the JSON is embedded in a string with escaped quotes
+	jsonKeyEndRe = regexp.MustCompile(`^\\?"\s*:`)
+)
+
+// File extensions that look like top-level domains in logs and emails
+var fileExtensions = map[string]bool{
+	"exe": true, "dll": true, "sys": true, "bat": true, "cmd": true, "ps1": true, "vbs": true, "js": true,
+	"jar": true, "py": true, "sh": true, "go": true, "php": true, "asp": true, "aspx": true, "jsp": true,
+	"html": true, "htm": true, "xml": true, "json": true, "yaml": true, "yml": true, "txt": true, "log": true,
+	"csv": true, "pdf": true, "doc": true, "docx": true, "xls": true, "xlsx": true, "ppt": true, "pptx": true,
+	"zip": true, "rar": true, "gz": true, "tar": true, "7z": true, "iso": true, "img": true, "tmp": true,
+	"png": true, "jpg": true, "jpeg": true, "gif": true, "bmp": true, "svg": true, "lnk": true, "msi": true,
+	"conf": true, "ini": true, "cfg": true, "db": true, "dat": true, "bin": true, "eml": true, "msg": true,
+}
+
+// Reserved names that never resolve on the public internet
+var reservedDomainSuffixes = []string{
+	"localhost", "local", "localdomain", "internal", "intranet", "corp", "lan", "home.arpa",
+	"test", "example", "invalid", "arpa", "example.com", "example.net", "example.org",
+}
+
+// Address ranges that are reserved but not covered by the netip helpers
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, including broadcast
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // Local-use NAT64
+}
+
+// ioc is an indicator of compromise found in text
+type ioc struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// enrichedIOC is an extracted indicator with its lookup result
+type enrichedIOC struct {
+	ioc
+	Verdict   string           `json:"verdict,omitempty"`
+	Result    *ChaitinResponse `json:"result,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Note      string           `json:"note,omitempty"`
+	Watchlist []WatchEntry     `json:"watchlist,omitempty"` // Matching local watchlist entries
+}
+
+// filteredIOC is an indicator left out because it is private or reserved
+type filteredIOC struct {
+	ioc
+	Reason string `json:"reason"`
+}
+
+// extractSummary counts indicators by type and verdict
+type extractSummary struct {
+	batchSummary
+	ByType   map[string]int `json:"by_type"`
+	Filtered int            `json:"filtered"`
+}
+
+// extractResponse is returned by the extract_and_enrich tool
+type extractResponse struct {
+	Summary    extractSummary `json:"summary"`
+	Indicators []enrichedIOC  `json:"indicators"`
+	Filtered   []filteredIOC  `json:"filtered,omitempty"`
+	Skipped    int            `json:"skipped,omitempty"`  // Indicators beyond max_ips that were not looked up
+	Refanged   bool           `json:"refanged,omitempty"` // The text contained defanged indicators
+}
+
+// registerExtractTool adds the extract_and_enrich tool
+func registerExtractTool(s *server.MCPServer, client *Client, limits *batchLimits, watch *watchlist) {
+	extractTool := mcp.NewTool("extract_and_enrich",
+		mcp.WithDescription("Extract IOCs (IPv4/IPv6 addresses, CIDRs, domains, URLs and MD5/SHA-1/SHA-256 hashes) "+
+			"from arbitrary text such as log lines, emails or alert JSON, and enrich each one with Chaitin Threat Intelligence. "+
+			"Defanged forms like 1.2.3[.]4 and hxxp:// are recognised; private and reserved addresses and domains are filtered out."),
+		mcp.WithString("text",
+			mcp.Required(),
+			mcp.Description("The text to extract indicators from"),
+		),
+		mcp.WithArray("types",
+			mcp.Description("Only extract these indicator types (ip, cidr, domain, url, hash); defaults to all"),
+			mcp.Items(map[string]interface{}{"type": "string", "enum": []string{indicatorIP, indicatorCIDR, indicatorDomain, indicatorURL, indicatorHash}}),
+		),
+		mcp.WithBoolean("include_private",
+			mcp.Description("Keep private and reserved addresses and domains instead of filtering them out (they are still not looked up)"),
+		),
+		mcp.WithBoolean("enrich",
+			mcp.Description("Look up each indicator (default true); set to false to only extract"),
+		),
+		mcp.WithBoolean("force_refresh",
+			mcp.Description("Bypass the lookup cache and query the API for every indicator"),
+		),
+	)
+
+	s.AddTool(extractTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		text, _ := request.Params.Arguments["text"].(string)
+		if strings.TrimSpace(text) == "" {
+			return toolError("text is required"), nil
+		}
+		includePrivate, _ := request.Params.Arguments["include_private"].(bool)
+		enrich := true
+		if value, ok := request.Params.Arguments["enrich"].(bool); ok {
+			enrich = value
+		}
+		types := make(map[string]bool)
+		if items, ok := request.Params.Arguments["types"].([]interface{}); ok {
+			for _, item := range items {
+				if str, ok := item.(string); ok {
+					types[str] = true
+				}
+			}
+		}
+
+		refanged := refang(text)
+		response := extractResponse{Refanged: refanged != text}
+
+		for _, found := range extractIOCs(refanged) {
+			if len(types) > 0 && !types[found.Type] {
+				continue
+			}
+			// Internal lists may name private addresses, so watchlisted ones are never filtered
+			matches := watch.match(found.Type, found.Value)
+			if reason := reservedReason(found); reason != "" {
+				if !includePrivate && len(matches) == 0 {
+					response.Filtered = append(response.Filtered, filteredIOC{ioc: found, Reason: reason})
+					continue
+				}
+				response.Indicators = append(response.Indicators, enrichedIOC{ioc: found, Note: reason + ", not looked up", Watchlist: matches})
+				continue
+			}
+			item := enrichedIOC{ioc: found, Watchlist: matches}
+			if found.Type == indicatorCIDR {
+				item.Note = "network ranges are not looked up"
+			}
+			response.Indicators = append(response.Indicators, item)
+		}
+
+		if enrich {
+			forceRefresh, _ := request.Params.Arguments["force_refresh"].(bool)
+			response.Skipped = enrichIOCs(withForceRefresh(ctx, forceRefresh), client, limits, response.Indicators)
+		}
+
+		response.Summary = extractSummary{
+			ByType:   make(map[string]int),
+			Filtered: len(response.Filtered),
+		}
+		response.Summary.Total = len(response.Indicators)
+		for _, item := range response.Indicators {
+			response.Summary.ByType[item.Type]++
+			if item.Verdict != "" || item.Error != "" {
+				response.Summary.add(item.Verdict, item.Error != "")
+			}
+			if len(item.Watchlist) > 0 {
+				response.Summary.Watchlist++
+			}
+		}
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format result: %v", err)
+		}
+		return mcp.NewToolResultText(string(jsonResult)), nil
+	}))
+}
+
+// enrichIOCs looks up every indicator that has no note yet, within the
+// batch limits, and returns how many were skipped because of max_ips
+func enrichIOCs(ctx context.Context, client *Client, limits *batchLimits, items []enrichedIOC) int {
+	var pending []int
+	for i, item := range items {
+		switch {
+		case item.Note != "":
+		case !client.Supports(item.Type):
+			items[i].Note = fmt.Sprintf("not queried, no Chaitin endpoint is configured for %s lookups", item.Type)
+		default:
+			pending = append(pending, i)
+		}
+	}
+	skipped := 0
+	if len(pending) > limits.maxItems {
+		skipped = len(pending) - limits.maxItems
+		for _, i := range pending[limits.maxItems:] {
+			items[i].Note = "skipped, too many indicators in one call"
+		}
+		pending = pending[:limits.maxItems]
+	}
+
+	// Cached results are filled in first so they do not wait for the rate limiter
+	var uncached []int
+	for _, i := range pending {
+		if resp, err, ok := client.cached(ctx, items[i].Type, items[i].Value); ok {
+			items[i].apply(resp, err)
+		} else {
+			uncached = append(uncached, i)
+		}
+	}
+
+	limits.run(ctx, len(uncached), func(n int) error {
+		item := &items[uncached[n]]
+		return item.apply(client.lookup(ctx, item.Type, item.Value))
+	}, func(n int, err error) {
+		items[uncached[n]].Error = err.Error()
+	})
+	return skipped
+}
+
+// apply records a lookup result. "Not found" is an unknown verdict rather
+// than a failure; other errors are returned.
+func (item *enrichedIOC) apply(resp *ChaitinResponse, err error) error {
+	if errors.Is(err, errNotFound) {
+		item.Verdict = verdictUnknown
+		item.Note = "no intelligence found"
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	item.Result = resp
+	item.Verdict = responseVerdict(resp)
+	return nil
+}
+
+// refang turns defanged indicators such as 1.2.3[.]4 and hxxp:// back into their normal form
+func refang(text string) string {
+	text = defangReplacer.Replace(text)
+	text = defangSchemeRe.ReplaceAllString(text, "http$1://")
+	return defangFTPRe.ReplaceAllString(text, "ftp://")
+}
+
+// extractIOCs finds indicators in refanged text, deduplicated per type in first-seen order
+func extractIOCs(text string) []ioc {
+	var found []ioc
+	seen := make(map[ioc]bool)
+	add := func(kind, value string) {
+		item := ioc{Type: kind, Value: value}
+		if !seen[item] {
+			seen[item] = true
+			found = append(found, item)
+		}
+	}
+
+	for _, match := range urlRe.FindAllString(text, -1) {
+		// Sentence punctuation and closing brackets are rarely part of the URL
+		match = strings.TrimRight(match, `.,;:!?)'"`)
+		if u, err := url.Parse(match); err == nil && u.Host != "" {
+			add(indicatorURL, match)
+		}
+	}
+
+	for _, match := range hashRe.FindAllString(text, -1) {
+		add(indicatorHash, strings.ToLower(match))
+	}
+
+	// CIDRs are removed before extracting addresses so 10.0.0.0/8 is not also reported as 10.0.0.0
+	stripped := text
+	for _, re := range []*regexp.Regexp{cidr4Re, cidr6Re} {
+		stripped = re.ReplaceAllStringFunc(stripped, func(match string) string {
+			if prefix, err := netip.ParsePrefix(match); err == nil {
+				add(indicatorCIDR, prefix.Masked().String())
+				return strings.Repeat(" ", len(match))
+			}
+			return match
+		})
+	}
+
+	for _, addr := range extractIPs(stripped) {
+		add(indicatorIP, addr.Unmap().String())
+	}
+
+	for _, loc := range domainRe.FindAllStringIndex(text, -1) {
+		// Dotted field names in alert JSON such as "source.ip" or "host.name" are keys, not domains
+		if loc[0] > 0 && text[loc[0]-1] == '"' && jsonKeyEndRe.MatchString(text[loc[1]:]) {
+			continue
+		}
+		domain := strings.ToLower(text[loc[0]:loc[1]])
+		tld := domain[strings.LastIndex(domain, ".")+1:]
+		if fileExtensions[tld] || !knownSuffix(domain) {
+			continue
+		}
+		add(indicatorDomain, domain)
+	}
+	return found
+}
+
+// knownSuffix reports whether a domain ends in a public suffix from the
+// Public Suffix List or in a reserved name, which is kept so it can be
+// reported as filtered. Field names like event.action end in neither.
+func knownSuffix(domain string) bool {
+	if reservedDomainReason(domain) != "" {
+		return true
+	}
+	suffix, icann := publicsuffix.PublicSuffix(domain)
+	// Unlisted TLDs fall back to the last label with icann false; private
+	// suffixes such as github.io are also not ICANN but span several labels
+	return icann || strings.Contains(suffix, ".")
+}
+
+// reservedReason explains why an indicator is private or reserved, or returns "" for public indicators
+func reservedReason(item ioc) string {
+	switch item.Type {
+	case indicatorIP:
+		if addr, err := netip.ParseAddr(item.Value); err == nil {
+			return reservedAddrReason(addr)
+		}
+	case indicatorCIDR:
+		if prefix, err := netip.ParsePrefix(item.Value); err == nil {
+			return reservedAddrReason(prefix.Addr())
+		}
+	case indicatorDomain:
+		return reservedDomainReason(item.Value)
+	case indicatorURL:
+		u, err := url.Parse(item.Value)
+		if err != nil {
+			return ""
+		}
+		if addr, err := netip.ParseAddr(strings.Trim(u.Hostname(), "[]")); err == nil {
+			return reservedAddrReason(addr)
+		}
+		return reservedDomainReason(strings.ToLower(u.Hostname()))
+	}
+	return ""
+}
+
+func reservedAddrReason(addr netip.Addr) string {
+	addr = addr.Unmap()
+	switch {
+	case addr.IsLoopback():
+		return "loopback address"
+	case addr.IsPrivate():
+		return "private address"
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		return "link-local address"
+	case addr.IsMulticast():
+		return "multicast address"
+	case addr.IsUnspecified():
+		return "unspecified address"
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return "reserved address"
+		}
+	}
+	return ""
+}
+
+func reservedDomainReason(domain string) string {
+	for _, suffix := range reservedDomainSuffixes {
+		if domain == suffix || strings.HasSuffix(domain, "."+suffix) {
+			return "reserved domain"
+		}
+	}
+	return ""
+}
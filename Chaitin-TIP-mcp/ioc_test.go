@@ -0,0 +1,122 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRefang(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"1.2.3[.]4", "1.2.3.4"},
+		{"evil(dot)com", "evil.com"},
+		{"hxxps://evil[.]com/a", "https://evil.com/a"},
+		{"hXXp[://]evil{.}com", "http://evil.com"},
+		{"fxp://files[.]evil[.]com", "ftp://files.evil.com"},
+		{"admin[@]evil[.]com", "admin@evil.com"},
+		{"2001:db8[:]:1", "2001:db8::1"},
+		{"plain text", "plain text"},
+	}
+	for _, tt := range tests {
+		if got := refang(tt.text); got != tt.want {
+			t.Errorf("refang(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// alertJSON is a trimmed SIEM alert in ECS field naming
+const alertJSON = `{
+  "@timestamp": "2026-03-01T08:15:00Z",
+  "event.action": "network-connection",
+  "host.name": "web-01",
+  "user.name": "svc_backup",
+  "source.ip": "10.1.2.3",
+  "destination.ip": "45.77.10.20",
+  "dns.question.name": "update.evil-cdn.com",
+  "url.full": "http://update.evil-cdn.com/payload.exe",
+  "process.name": "rundll32.exe",
+  "file.hash.sha256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+  "message": "{\"rule.name\": \"C2 beacon\", \"threat.indicator\": \"beacon.evil-cdn.net\"}"
+}`
+
+func TestExtractIOCs(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []ioc
+	}{
+		{
+			name: "alert json",
+			text: alertJSON,
+			want: []ioc{
+				{indicatorURL, "http://update.evil-cdn.com/payload.exe"},
+				{indicatorHash, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+				{indicatorIP, "10.1.2.3"},
+				{indicatorIP, "45.77.10.20"},
+				{indicatorDomain, "update.evil-cdn.com"},
+				{indicatorDomain, "beacon.evil-cdn.net"},
+			},
+		},
+		{
+			name: "defanged report",
+			text: refang("C2 at hxxp://bad[.]example[.]org/x and 8.8.8[.]8, also seen in evil[.]ru."),
+			want: []ioc{
+				{indicatorURL, "http://bad.example.org/x"},
+				{indicatorIP, "8.8.8.8"},
+				{indicatorDomain, "bad.example.org"},
+				{indicatorDomain, "evil.ru"},
+			},
+		},
+		{
+			name: "cidr and ipv6",
+			text: "block 192.168.0.0/16 and 2001:db8::/32, peer 2606:4700::1111 and ::1",
+			want: []ioc{
+				{indicatorCIDR, "192.168.0.0/16"},
+				{indicatorCIDR, "2001:db8::/32"},
+				{indicatorIP, "2606:4700::1111"},
+				{indicatorIP, "::1"},
+			},
+		},
+		{
+			name: "unknown tlds and file names",
+			text: "config.yaml loaded by svc.worker from report.pdf, mirror on user.github.io",
+			want: []ioc{
+				{indicatorDomain, "user.github.io"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractIOCs(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("extractIOCs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReservedReason(t *testing.T) {
+	tests := []struct {
+		item ioc
+		want string
+	}{
+		{ioc{indicatorIP, "10.1.2.3"}, "private address"},
+		{ioc{indicatorIP, "127.0.0.1"}, "loopback address"},
+		{ioc{indicatorIP, "fe80::1"}, "link-local address"},
+		{ioc{indicatorIP, "::ffff:192.168.1.1"}, "private address"},
+		{ioc{indicatorIP, "100.64.1.1"}, "reserved address"},
+		{ioc{indicatorIP, "45.77.10.20"}, ""},
+		{ioc{indicatorCIDR, "172.16.0.0/12"}, "private address"},
+		{ioc{indicatorDomain, "dc01.corp"}, "reserved domain"},
+		{ioc{indicatorDomain, "evil.com"}, ""},
+		{ioc{indicatorURL, "http://[::1]:8080/x"}, "loopback address"},
+		{ioc{indicatorURL, "https://intranet.example.com/"}, "reserved domain"},
+		{ioc{indicatorURL, "https://evil.com/"}, ""},
+	}
+	for _, tt := range tests {
+		if got := reservedReason(tt.item); got != tt.want {
+			t.Errorf("reservedReason(%v) = %q, want %q", tt.item, got, tt.want)
+		}
+	}
+}
@@ -3,87 +3,217 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"net/http"
+	"log"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-// ChaitinIPResponse represents the response structure from Chaitin API
-type ChaitinIPResponse struct {
-	Code int             `json:"code"`
-	Msg  string         `json:"msg"`
-	Data map[string]any `json:"data"`
-}
+func main() {
+	configPath := flag.String("config", "", "Path to a JSON or YAML config file (optional)")
+	flag.Parse()
 
-// ChaitinIPLookup implements the IP lookup functionality
-func ChaitinIPLookup(ip string) (*ChaitinIPResponse, error) {
-	sk := os.Getenv("CHAITIN_SK")
-	if sk == "" {
-		return nil, fmt.Errorf("CHAITIN_SK environment variable not set")
+	// Load config from the file and environment
+	config, err := LoadConfig(*configPath)
+	if err == nil {
+		err = config.Validate()
 	}
 
-	url := fmt.Sprintf("https://ip-0.rivers.chaitin.cn/api/share/s?sk=%s&ip=%s", sk, ip)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query Chaitin API: %v", err)
+	// "config validate" and "audit verify" check something and exit,
+	// "export" looks up indicators and writes a STIX or MISP document
+	if args := flag.Args(); len(args) > 0 {
+		switch {
+		case len(args) == 2 && args[0] == "config" && args[1] == "validate":
+			if err != nil {
+				fmt.Printf("Invalid config:\n%v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("Config is valid")
+		case len(args) >= 2 && args[0] == "audit" && args[1] == "verify":
+			if err := runAuditVerify(config, args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		case args[0] == "export":
+			if err != nil {
+				fmt.Printf("Invalid config:\n%v\n", err)
+				os.Exit(1)
+			}
+			if err := runExport(config, args[1:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Println("Usage: chaitin-mcp [-config path] [config validate | audit verify [-file path] | export [-format stix|misp] [-o path] [-tlp level] [-input path] [indicator ...]]")
+			os.Exit(1)
+		}
+		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %v", err)
+		fmt.Printf("Invalid config:\n%v\n", err)
+		os.Exit(1)
 	}
 
-	var result ChaitinIPResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
+	// Trace tool calls and Chaitin API requests when tracing is configured
+	if config.Tracing != nil {
+		shutdownTracing, err := setupTracing(context.Background(), config.Tracing)
+		if err != nil {
+			fmt.Printf("Failed to set up tracing: %v\n", err)
+			os.Exit(1)
+		}
+		// Flush pending spans before exiting
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdownTracing(ctx)
+		}()
 	}
 
-	return &result, nil
-}
+	// Record tool call metrics only when the metrics endpoint is enabled
+	hooks := &server.Hooks{}
+	if config.MetricsListen != "" {
+		instrumentHooks(hooks)
+		go serveMetrics(config.MetricsListen)
+	}
+
+	// Append every tool call to the audit log when configured
+	if config.Audit != nil {
+		auditLog, err = openAuditLog(config.Audit)
+		if err != nil {
+			fmt.Printf("Failed to open audit log: %v\n", err)
+			os.Exit(1)
+		}
+		defer auditLog.Close()
+		auditLog.trackClient(hooks)
+	}
 
-func main() {
 	// Create a new MCP server
 	s := server.NewMCPServer(
 		"Chaitin IP Lookup",
 		"1.0.0",
 		server.WithLogging(),
+		server.WithHooks(hooks),
 	)
 
+	// All lookup tools share one Chaitin API client. The single-source tools
+	// below call it directly, the multi-source tools through chaitinProvider
+	client, err := NewClient(config)
+	if err != nil {
+		fmt.Printf("Failed to create Chaitin API client: %v\n", err)
+		os.Exit(1)
+	}
+	// Local IOC lists are matched alongside every lookup
+	watch, err := loadWatchlist(config.Watchlist)
+	if err != nil {
+		fmt.Printf("Failed to load watchlist: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Add IP lookup tool
 	ipLookupTool := mcp.NewTool("ip_lookup",
-		mcp.WithDescription("Look up IP information using Chaitin Threat Intelligence"),
+		mcp.WithDescription("Look up IP information using Chaitin Threat Intelligence. Returns a short summary and normalized fields: verdict, reputation, confidence, categories, tags, geolocation, ASN and first/last seen"),
 		mcp.WithString("ip",
 			mcp.Required(),
 			mcp.Description("The IP address to look up"),
 		),
+		mcp.WithBoolean("force_refresh",
+			mcp.Description("Bypass the lookup cache and query the API"),
+		),
 	)
 
 	// Add the IP lookup handler
-	s.AddTool(ipLookupTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		ip := request.Params.Arguments["ip"].(string)
-		
-		result, err := ChaitinIPLookup(ip)
+	s.AddTool(ipLookupTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ip, _ := request.Params.Arguments["ip"].(string)
+		ip, err := normalizeIP(ip, config.RejectPrivateIPs)
+		if err != nil {
+			return toolError(fmt.Sprintf("invalid ip: %v", err)), nil
+		}
+
+		forceRefresh, _ := request.Params.Arguments["force_refresh"].(bool)
+		result, err := client.LookupIP(withForceRefresh(ctx, forceRefresh), ip)
+		if errors.Is(err, errNotFound) {
+			// Not an error: the API simply has nothing on this address
+			intel := parseIPIntel(ip, nil)
+			intel.Watchlist = watch.match(indicatorIP, ip)
+			return mcp.NewToolResultText(intel.Summary() + "\nNo intelligence found for this IP"), nil
+		}
 		if err != nil {
-			return nil, err
+			return lookupError(err), nil
 		}
 
-		// Convert result to JSON string for output
-		jsonResult, err := json.MarshalIndent(result, "", "  ")
+		// Return a short summary followed by the normalized fields as JSON
+		intel := parseIPIntel(ip, result)
+		intel.Watchlist = watch.match(indicatorIP, ip)
+		jsonResult, err := json.MarshalIndent(intel, "", "  ")
 		if err != nil {
 			return nil, fmt.Errorf("failed to format result: %v", err)
 		}
 
-		return mcp.NewToolResultText(string(jsonResult)), nil
-	})
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.NewTextContent(intel.Summary()),
+				mcp.NewTextContent(string(jsonResult)),
+			},
+		}, nil
+	}))
+
+	// Add domain, URL and file hash lookup tools
+	registerLookupTools(s, client, watch)
+
+	// Add batch IP lookup and IOC extraction tools, sharing one request budget
+	limits := newBatchLimits(config.Batch)
+	registerBatchTool(s, client, limits, watch)
+	registerExtractTool(s, client, limits, watch)
+
+	// Add watchlist management tools
+	registerWatchlistTools(s, watch)
+
+	// Add the cache statistics tool
+	registerCacheTool(s, client)
 
-	// Start the server
-	if err := server.ServeStdio(s); err != nil {
+	// Add the multi-provider lookup tool, querying Chaitin, every configured
+	// provider and the local watchlist
+	providers, err := newProviders(client, config.Providers)
+	if err != nil {
+		fmt.Printf("Failed to set up providers: %v\n", err)
+		os.Exit(1)
+	}
+	registerMultiTool(s, append(slices.Clip(providers), watch))
+
+	// Add the STIX/MISP export tool, which queries the same providers but
+	// leaves out the watchlist unless export.include_watchlist is set
+	registerExportTool(s, exportProviders(providers, watch, config.Export), limits, config.Export)
+
+	// Start the server, stopping on SIGINT or SIGTERM. Returning from main
+	// lets the deferred calls flush pending spans and close the audit log
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := serveStdio(ctx, s, client, os.Stdin, os.Stdout); err != nil {
 		fmt.Printf("Server error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// serveStdio serves MCP requests on stdin and stdout until ctx is done or
+// stdin is closed, then saves the lookup cache. A canceled ctx is a normal
+// shutdown, not an error.
+func serveStdio(ctx context.Context, s *server.MCPServer, client *Client, stdin io.Reader, stdout io.Writer) error {
+	err := server.NewStdioServer(s).Listen(ctx, stdin, stdout)
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	if closeErr := client.Close(); closeErr != nil {
+		log.Printf("Failed to save cache: %v", closeErr)
+	}
+	return err
+}
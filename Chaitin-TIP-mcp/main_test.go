@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// SIGINT and SIGTERM cancel the serve context: that is a clean shutdown
+// and the persisted cache is still saved
+func TestServeStdioShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	client, err := NewClient(&Config{SK: "test-sk", Cache: &CacheConfig{Path: path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.cache.put(indicatorIP, "1.2.3.4", &ChaitinResponse{Data: map[string]any{"reputation": "black"}}, nil)
+
+	// stdin stays open, as it does while the MCP client is connected
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveStdio(ctx, server.NewMCPServer("test", "1.0.0"), client, stdin, io.Discard)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serveStdio() = %v, want nil on a canceled context", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveStdio did not return after the context was canceled")
+	}
+
+	loaded, err := newLookupCache(&CacheConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, _, ok := loaded.get(indicatorIP, "1.2.3.4"); !ok || resp.Data["reputation"] != "black" {
+		t.Errorf("cache after shutdown = %v, %v, want the entry saved", resp, ok)
+	}
+}
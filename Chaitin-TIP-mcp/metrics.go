@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "chaitin_mcp"
+
+// Tool call outcomes
+const (
+	statusOK    = "ok"
+	statusError = "error"
+)
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "tool_calls_total",
+		Help:      "Number of MCP tool calls.",
+	}, []string{"tool", "status"})
+	toolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "tool_call_duration_seconds",
+		Help:      "Duration of MCP tool calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"tool"})
+	upstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "upstream_requests_total",
+		Help:      "Number of requests to the Chaitin API and other providers by status code.",
+	}, []string{"endpoint", "code"})
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Duration of requests to the Chaitin API and other providers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_requests_total",
+		Help:      "Number of lookup cache requests by indicator type and result.",
+	}, []string{"indicator", "result"})
+	cacheEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_entries",
+		Help:      "Number of cached lookup results by indicator type.",
+	}, []string{"indicator"})
+	cacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_evictions_total",
+		Help:      "Number of cached lookup results evicted to stay within max_entries.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		toolCalls,
+		toolDuration,
+		upstreamRequests,
+		upstreamDuration,
+		cacheRequests,
+		cacheEntries,
+		cacheEvictions,
+	)
+}
+
+// observeUpstream records one upstream API request. A zero statusCode means no response was received.
+func observeUpstream(endpoint string, statusCode int, duration time.Duration) {
+	code := statusError
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	upstreamRequests.WithLabelValues(endpoint, code).Inc()
+	upstreamDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+}
+
+// observeCache records one lookup cache request
+func observeCache(indicator, result string) {
+	cacheRequests.WithLabelValues(indicator, result).Inc()
+}
+
+// instrumentHooks records tool call counts, durations and outcomes through the server hooks
+func instrumentHooks(hooks *server.Hooks) {
+	var starts sync.Map // *mcp.CallToolRequest -> time.Time
+
+	finish := func(request *mcp.CallToolRequest, status string) {
+		value, ok := starts.LoadAndDelete(request)
+		if !ok {
+			return
+		}
+		tool := request.Params.Name
+		toolCalls.WithLabelValues(tool, status).Inc()
+		toolDuration.WithLabelValues(tool).Observe(time.Since(value.(time.Time)).Seconds())
+	}
+
+	hooks.AddBeforeCallTool(func(id any, request *mcp.CallToolRequest) {
+		starts.Store(request, time.Now())
+	})
+	hooks.AddAfterCallTool(func(id any, request *mcp.CallToolRequest, result *mcp.CallToolResult) {
+		status := statusOK
+		if result != nil && result.IsError {
+			status = statusError
+		}
+		finish(request, status)
+	})
+	hooks.AddOnError(func(id any, method mcp.MCPMethod, message any, err error) {
+		if request, ok := message.(*mcp.CallToolRequest); ok {
+			finish(request, statusError)
+		}
+	})
+}
+
+// serveMetrics serves /metrics on addr. Log output goes to stderr so it
+// does not interfere with the stdio transport.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+
+	log.Printf("Serving metrics on %s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Metrics server error: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Verdicts ordered from least to most severe, for merging
+var verdictSeverity = map[string]int{
+	verdictUnknown:    0,
+	verdictClean:      1,
+	verdictSuspicious: 2,
+	verdictMalicious:  3,
+}
+
+// sourceResult is one provider's outcome in a multi_lookup
+type sourceResult struct {
+	Provider string `json:"provider"`
+	*ProviderResult
+	Error string `json:"error,omitempty"`
+	Note  string `json:"note,omitempty"`
+}
+
+// multiResponse is returned by the multi_lookup tool
+type multiResponse struct {
+	Indicator string              `json:"indicator"`
+	Type      string              `json:"type"`
+	Verdict   string              `json:"verdict"` // Most severe verdict reported by any provider
+	Summary   string              `json:"summary"`
+	Votes     map[string][]string `json:"votes"` // Verdict -> providers reporting it
+	Sources   []sourceResult      `json:"sources"`
+}
+
+// registerMultiTool adds the multi_lookup tool
+func registerMultiTool(s *server.MCPServer, providers []Provider) {
+	names := make([]string, len(providers))
+	for i, provider := range providers {
+		names[i] = provider.Name()
+	}
+
+	multiTool := mcp.NewTool("multi_lookup",
+		mcp.WithDescription("Look up an IP address, domain or file hash in every enabled threat intelligence provider "+
+			"("+strings.Join(names, ", ")+") in parallel and merge their verdicts, showing which source reported what"),
+		mcp.WithString("indicator",
+			mcp.Required(),
+			mcp.Description("The IP address, domain or MD5/SHA-1/SHA-256 hash to look up"),
+		),
+		mcp.WithString("type",
+			mcp.Description("The indicator type; detected automatically when omitted"),
+			mcp.Enum(indicatorIP, indicatorDomain, indicatorHash),
+		),
+		mcp.WithArray("providers",
+			mcp.Description("Only query these providers; defaults to all enabled providers"),
+			mcp.Items(map[string]interface{}{"type": "string", "enum": names}),
+		),
+		mcp.WithBoolean("force_refresh",
+			mcp.Description("Bypass the lookup cache and query the API"),
+		),
+	)
+
+	s.AddTool(multiTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		value, _ := request.Params.Arguments["indicator"].(string)
+		kind, _ := request.Params.Arguments["type"].(string)
+		value, kind, err := normalizeIndicator(value, kind)
+		if err != nil {
+			return toolError(fmt.Sprintf("invalid indicator: %v", err)), nil
+		}
+
+		selected := providers
+		if items, ok := request.Params.Arguments["providers"].([]interface{}); ok && len(items) > 0 {
+			wanted := make(map[string]bool)
+			for _, item := range items {
+				if str, ok := item.(string); ok {
+					wanted[str] = true
+				}
+			}
+			selected = nil
+			for _, provider := range providers {
+				if wanted[provider.Name()] {
+					selected = append(selected, provider)
+				}
+			}
+			if len(selected) == 0 {
+				return toolError(fmt.Sprintf("none of the requested providers is enabled, enabled providers: %s", strings.Join(names, ", "))), nil
+			}
+		}
+
+		forceRefresh, _ := request.Params.Arguments["force_refresh"].(bool)
+		response := multiLookup(withForceRefresh(ctx, forceRefresh), selected, kind, value)
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format result: %v", err)
+		}
+		return mcp.NewToolResultText(string(jsonResult)), nil
+	}))
+}
+
+// normalizeIndicator validates the indicator, detecting its type when kind is empty
+func normalizeIndicator(value, kind string) (string, string, error) {
+	value = strings.TrimSpace(value)
+	if kind == "" {
+		if _, err := netip.ParseAddr(value); err == nil {
+			kind = indicatorIP
+		} else if _, err := normalizeHash(value); err == nil {
+			kind = indicatorHash
+		} else {
+			kind = indicatorDomain
+		}
+	}
+	var err error
+	switch kind {
+	case indicatorIP:
+		value, err = normalizeIP(value, false)
+	case indicatorDomain:
+		value, err = normalizeDomain(value)
+	case indicatorHash:
+		value, err = normalizeHash(value)
+	default:
+		err = fmt.Errorf("unsupported type %q, must be one of ip, domain, hash", kind)
+	}
+	return value, kind, err
+}
+
+// multiLookup queries the providers in parallel and merges their verdicts.
+// Sources are reported in provider order.
+func multiLookup(ctx context.Context, providers []Provider, kind, value string) *multiResponse {
+	response := &multiResponse{
+		Indicator: value,
+		Type:      kind,
+		Verdict:   verdictUnknown,
+		Votes:     make(map[string][]string),
+		Sources:   make([]sourceResult, len(providers)),
+	}
+
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider Provider) {
+			defer wg.Done()
+			var result *ProviderResult
+			var err error
+			switch kind {
+			case indicatorIP:
+				result, err = provider.LookupIP(ctx, value)
+			case indicatorDomain:
+				result, err = provider.LookupDomain(ctx, value)
+			case indicatorHash:
+				result, err = provider.LookupHash(ctx, value)
+			}
+
+			source := sourceResult{ProviderResult: result, Provider: provider.Name()}
+			switch {
+			case errors.Is(err, errUnsupported):
+				source.Note = fmt.Sprintf("%s lookups are not supported", kind)
+			case errors.Is(err, errNotFound):
+				source.ProviderResult = &ProviderResult{Verdict: verdictUnknown}
+				source.Note = "no intelligence found"
+			case err != nil:
+				source.Error = err.Error()
+			}
+			response.Sources[i] = source
+		}(i, provider)
+	}
+	wg.Wait()
+
+	var failed, unsupported []string
+	for _, source := range response.Sources {
+		switch {
+		case source.Error != "":
+			failed = append(failed, source.Provider)
+		case source.ProviderResult == nil:
+			unsupported = append(unsupported, source.Provider)
+		default:
+			verdict := source.Verdict
+			response.Votes[verdict] = append(response.Votes[verdict], source.Provider)
+			if verdictSeverity[verdict] > verdictSeverity[response.Verdict] {
+				response.Verdict = verdict
+			}
+		}
+	}
+	response.Summary = multiSummary(response.Votes, failed, unsupported)
+	return response
+}
+
+// multiSummary describes the votes, most severe verdict first, e.g.
+// "malicious according to abuseipdb, threatbook; clean according to chaitin"
+func multiSummary(votes map[string][]string, failed, unsupported []string) string {
+	verdicts := make([]string, 0, len(votes))
+	for verdict := range votes {
+		verdicts = append(verdicts, verdict)
+	}
+	sort.Slice(verdicts, func(i, j int) bool {
+		return verdictSeverity[verdicts[i]] > verdictSeverity[verdicts[j]]
+	})
+
+	var parts []string
+	for _, verdict := range verdicts {
+		parts = append(parts, fmt.Sprintf("%s according to %s", verdict, strings.Join(votes[verdict], ", ")))
+	}
+	if len(failed) > 0 {
+		parts = append(parts, "failed: "+strings.Join(failed, ", "))
+	}
+	if len(unsupported) > 0 {
+		parts = append(parts, "not supported: "+strings.Join(unsupported, ", "))
+	}
+	if len(parts) == 0 {
+		return "no provider returned a result"
+	}
+	return strings.Join(parts, "; ")
+}
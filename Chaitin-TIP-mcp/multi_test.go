@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fixedProvider answers every lookup with the same verdict or error
+type fixedProvider struct {
+	name    string
+	verdict string
+	err     error
+}
+
+func (p *fixedProvider) Name() string { return p.name }
+
+func (p *fixedProvider) LookupIP(ctx context.Context, ip string) (*ProviderResult, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &ProviderResult{Provider: p.name, Verdict: p.verdict}, nil
+}
+
+func (p *fixedProvider) LookupDomain(ctx context.Context, domain string) (*ProviderResult, error) {
+	return p.LookupIP(ctx, domain)
+}
+
+func (p *fixedProvider) LookupHash(ctx context.Context, hash string) (*ProviderResult, error) {
+	return p.LookupIP(ctx, hash)
+}
+
+// The most severe verdict wins, each provider votes for what it reported,
+// not found is an unknown vote, and failed or unsupported providers do not vote
+func TestMultiLookupVerdicts(t *testing.T) {
+	malicious := &fixedProvider{name: "malicious", verdict: verdictMalicious}
+	suspicious := &fixedProvider{name: "suspicious", verdict: verdictSuspicious}
+	clean := &fixedProvider{name: "clean", verdict: verdictClean}
+	clean2 := &fixedProvider{name: "clean2", verdict: verdictClean}
+	notFound := &fixedProvider{name: "notfound", err: errNotFound}
+	unsupported := &fixedProvider{name: "unsupported", err: errUnsupported}
+	failing := &fixedProvider{name: "failing", err: errors.New("connection refused")}
+
+	tests := []struct {
+		name      string
+		providers []Provider
+		verdict   string
+		votes     map[string][]string
+		summary   string
+	}{
+		{
+			name:      "malicious outvotes clean",
+			providers: []Provider{clean, malicious, clean2},
+			verdict:   verdictMalicious,
+			votes:     map[string][]string{verdictMalicious: {"malicious"}, verdictClean: {"clean", "clean2"}},
+			summary:   "malicious according to malicious; clean according to clean, clean2",
+		},
+		{
+			name:      "all verdicts",
+			providers: []Provider{notFound, clean, suspicious, malicious},
+			verdict:   verdictMalicious,
+			votes: map[string][]string{
+				verdictMalicious:  {"malicious"},
+				verdictSuspicious: {"suspicious"},
+				verdictClean:      {"clean"},
+				verdictUnknown:    {"notfound"},
+			},
+			summary: "malicious according to malicious; suspicious according to suspicious; " +
+				"clean according to clean; unknown according to notfound",
+		},
+		{
+			name:      "not found is unknown",
+			providers: []Provider{notFound},
+			verdict:   verdictUnknown,
+			votes:     map[string][]string{verdictUnknown: {"notfound"}},
+			summary:   "unknown according to notfound",
+		},
+		{
+			name:      "failures and unsupported do not vote",
+			providers: []Provider{failing, clean, unsupported},
+			verdict:   verdictClean,
+			votes:     map[string][]string{verdictClean: {"clean"}},
+			summary:   "clean according to clean; failed: failing; not supported: unsupported",
+		},
+		{
+			name:      "no results",
+			providers: []Provider{failing, unsupported},
+			verdict:   verdictUnknown,
+			votes:     map[string][]string{},
+			summary:   "failed: failing; not supported: unsupported",
+		},
+		{
+			name:      "no providers",
+			providers: nil,
+			verdict:   verdictUnknown,
+			votes:     map[string][]string{},
+			summary:   "no provider returned a result",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := multiLookup(context.Background(), tt.providers, indicatorIP, "1.2.3.4")
+			if response.Verdict != tt.verdict {
+				t.Errorf("Verdict = %s, want %s", response.Verdict, tt.verdict)
+			}
+			if !reflect.DeepEqual(response.Votes, tt.votes) {
+				t.Errorf("Votes = %v, want %v", response.Votes, tt.votes)
+			}
+			if response.Summary != tt.summary {
+				t.Errorf("Summary = %q, want %q", response.Summary, tt.summary)
+			}
+			if len(response.Sources) != len(tt.providers) {
+				t.Fatalf("got %d sources, want %d", len(response.Sources), len(tt.providers))
+			}
+			for i, source := range response.Sources {
+				if source.Provider != tt.providers[i].Name() {
+					t.Errorf("source %d = %s, want %s (provider order)", i, source.Provider, tt.providers[i].Name())
+				}
+			}
+		})
+	}
+}
+
+// Sources carry the note or error explaining a missing verdict
+func TestMultiLookupSourceNotes(t *testing.T) {
+	providers := []Provider{
+		&fixedProvider{name: "notfound", err: errNotFound},
+		&fixedProvider{name: "unsupported", err: errUnsupported},
+		&fixedProvider{name: "failing", err: errors.New("connection refused")},
+	}
+	response := multiLookup(context.Background(), providers, indicatorHash, "d41d8cd98f00b204e9800998ecf8427e")
+
+	want := []struct{ note, err string }{
+		{"no intelligence found", ""},
+		{"hash lookups are not supported", ""},
+		{"", "connection refused"},
+	}
+	for i, source := range response.Sources {
+		if source.Note != want[i].note || source.Error != want[i].err {
+			t.Errorf("source %s: note %q, error %q, want %q, %q", source.Provider, source.Note, source.Error, want[i].note, want[i].err)
+		}
+	}
+}
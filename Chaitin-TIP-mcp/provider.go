@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// errUnsupported is returned by providers for indicator types they have no data on
+var errUnsupported = errors.New("indicator type not supported by this provider")
+
+// Provider is a source of threat intelligence for the tools that combine
+// several sources, multi_lookup and export_intel. The single-source tools
+// (ip_lookup, domain_lookup, url_lookup, hash_lookup, batch_ip_lookup and
+// extract_and_enrich) use the Chaitin client directly, since they return
+// Chaitin-specific fields, share its request budget and cover URLs.
+// Providers return errNotFound when they have nothing on an indicator and
+// errUnsupported for indicator types they do not cover.
+type Provider interface {
+	Name() string
+	LookupIP(ctx context.Context, ip string) (*ProviderResult, error)
+	LookupDomain(ctx context.Context, domain string) (*ProviderResult, error)
+	LookupHash(ctx context.Context, hash string) (*ProviderResult, error)
+}
+
+// ProviderResult is one provider's view of an indicator
+type ProviderResult struct {
+	Provider string         `json:"provider"`
+	Verdict  string         `json:"verdict"`         // malicious, suspicious, clean or unknown
+	Score    *int           `json:"score,omitempty"` // Provider's 0-100 maliciousness score, when it has one
+	Tags     []string       `json:"tags,omitempty"`
+	Link     string         `json:"link,omitempty"` // Report page on the provider's site
+	Data     map[string]any `json:"data,omitempty"` // Provider-specific details
+}
+
+// ProviderConfig enables an additional threat intelligence provider
+type ProviderConfig struct {
+	APIKey     string   `json:"api_key,omitempty"`
+	APIKeyFile string   `json:"api_key_file,omitempty"` // Read the API key from this file when api_key is empty
+	BaseURL    string   `json:"base_url,omitempty"`     // Override the API address, e.g. to point at a local stub
+	Timeout    Duration `json:"timeout,omitempty"`      // Request timeout, defaults to 30s
+}
+
+// ProvidersConfig lists the providers queried by multi_lookup in addition to Chaitin.
+// A provider is enabled when its section is present.
+type ProvidersConfig struct {
+	AbuseIPDB  *ProviderConfig `json:"abuseipdb,omitempty"`
+	ThreatBook *ProviderConfig `json:"threatbook,omitempty"`
+	VirusTotal *ProviderConfig `json:"virustotal,omitempty"`
+}
+
+// Provider names, also used in config, metrics and results
+const (
+	providerChaitin    = "chaitin"
+	providerAbuseIPDB  = "abuseipdb"
+	providerThreatBook = "threatbook"
+	providerVirusTotal = "virustotal"
+)
+
+// configured returns the provider sections by name, skipping absent ones
+func (c *ProvidersConfig) configured() map[string]*ProviderConfig {
+	configs := make(map[string]*ProviderConfig)
+	if c == nil {
+		return configs
+	}
+	for name, config := range map[string]*ProviderConfig{
+		providerAbuseIPDB:  c.AbuseIPDB,
+		providerThreatBook: c.ThreatBook,
+		providerVirusTotal: c.VirusTotal,
+	} {
+		if config != nil {
+			configs[name] = config
+		}
+	}
+	return configs
+}
+
+// newProviders creates Chaitin and every configured provider, in a fixed order
+func newProviders(client *Client, config *ProvidersConfig) ([]Provider, error) {
+	providers := []Provider{&chaitinProvider{client: client}}
+	configs := config.configured()
+	constructors := []struct {
+		name string
+		new  func(*ProviderConfig) (Provider, error)
+	}{
+		{providerAbuseIPDB, newAbuseIPDBProvider},
+		{providerThreatBook, newThreatBookProvider},
+		{providerVirusTotal, newVirusTotalProvider},
+	}
+	for _, c := range constructors {
+		if providerConfig, ok := configs[c.name]; ok {
+			provider, err := c.new(providerConfig)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", c.name, err)
+			}
+			providers = append(providers, provider)
+		}
+	}
+	return providers, nil
+}
+
+// chaitinProvider adapts the Chaitin client, including its cache, to the Provider interface
+type chaitinProvider struct {
+	client  *Client
+	limiter *rate.Limiter // Waited on before each API request that misses the cache, when set
+}
+
+func (p *chaitinProvider) Name() string {
+	return providerChaitin
+}
+
+func (p *chaitinProvider) LookupIP(ctx context.Context, ip string) (*ProviderResult, error) {
+	resp, err := p.client.limitedLookup(ctx, p.limiter, indicatorIP, ip)
+	if err != nil {
+		return nil, err
+	}
+	intel := parseIPIntel(ip, resp)
+	// Copy into a new slice so appending never writes into intel.Categories
+	tags := make([]string, 0, len(intel.Categories)+len(intel.Tags))
+	tags = append(append(tags, intel.Categories...), intel.Tags...)
+	return &ProviderResult{
+		Provider: providerChaitin,
+		Verdict:  intel.Verdict,
+		Tags:     tags,
+		Data:     resp.Data,
+	}, nil
+}
+
+func (p *chaitinProvider) LookupDomain(ctx context.Context, domain string) (*ProviderResult, error) {
+	return p.result(p.client.limitedLookup(ctx, p.limiter, indicatorDomain, domain))
+}
+
+func (p *chaitinProvider) LookupHash(ctx context.Context, hash string) (*ProviderResult, error) {
+	return p.result(p.client.limitedLookup(ctx, p.limiter, indicatorHash, hash))
+}
+
+func (p *chaitinProvider) result(resp *ChaitinResponse, err error) (*ProviderResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &ProviderResult{Provider: providerChaitin, Verdict: responseVerdict(resp), Data: resp.Data}, nil
+}
+
+// apiProvider holds what the HTTP-based providers share: the API address,
+// the key and a client that never forwards the key to another host
+type apiProvider struct {
+	name    string
+	key     string
+	baseURL *url.URL
+	httpCli *http.Client
+}
+
+func newAPIProvider(name, defaultBaseURL string, config *ProviderConfig) (*apiProvider, error) {
+	rawBase := config.BaseURL
+	if rawBase == "" {
+		rawBase = defaultBaseURL
+	}
+	baseURL, err := url.Parse(rawBase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base url: %v", err)
+	}
+	timeout := time.Duration(config.Timeout)
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &apiProvider{
+		name:    name,
+		key:     config.APIKey,
+		baseURL: baseURL,
+		httpCli: &http.Client{
+			Timeout:       timeout,
+			CheckRedirect: sameHostRedirects(baseURL),
+		},
+	}, nil
+}
+
+// get requests path and decodes the JSON body into out. A 404 is
+// errNotFound; other error statuses become an APIError with the body as
+// the message. The key is redacted from every error.
+func (p *apiProvider) get(ctx context.Context, indicator, path string, query url.Values, header http.Header, out any) error {
+	endpoint := p.name + "_" + indicator
+	ctx, span := tracer().Start(ctx, "GET "+p.name+" "+indicator,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("server.address", p.baseURL.Hostname()),
+			attribute.String("provider", p.name),
+		),
+	)
+	defer span.End()
+
+	reqURL := p.baseURL.JoinPath(path)
+	reqURL.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", redactSecret(err.Error(), p.key))
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	resp, err := p.httpCli.Do(req)
+	if err != nil {
+		observeUpstream(endpoint, 0, time.Since(start))
+		span.SetStatus(codes.Error, "request failed")
+		return newRedactedError(fmt.Sprintf("failed to query %s: %s", p.name, redactSecret(err.Error(), p.key)), err)
+	}
+	defer resp.Body.Close()
+	observeUpstream(endpoint, resp.StatusCode, time.Since(start))
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Msg:        redactSecret(msg, p.key),
+			Kind:       classifyAPIError(resp.StatusCode, msg),
+		}
+		if !errors.Is(apiErr, errNotFound) {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+		return apiErr
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse %s response: %v", p.name, err)
+	}
+	return nil
+}
+
+// scoreVerdict maps a 0-100 maliciousness score to a verdict
+func scoreVerdict(score int) string {
+	switch {
+	case score >= 75:
+		return verdictMalicious
+	case score >= 25:
+		return verdictSuspicious
+	default:
+		return verdictClean
+	}
+}
+
+// severityVerdict maps a severity or threat level label to a verdict
+func severityVerdict(level string) string {
+	switch strings.ToLower(level) {
+	case "critical", "high", "malicious":
+		return verdictMalicious
+	case "medium", "suspicious":
+		return verdictSuspicious
+	case "low", "info", "clean", "safe", "harmless":
+		return verdictClean
+	default:
+		return verdictUnknown
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testProviderKey = "test-provider-key"
+
+// stubProvider starts a local stand-in for a provider API and returns a
+// config pointing at it
+func stubProvider(t *testing.T, handler http.HandlerFunc) *ProviderConfig {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &ProviderConfig{APIKey: testProviderKey, BaseURL: server.URL}
+}
+
+// writeJSON writes v as the response body
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Errorf("failed to encode stub response: %v", err)
+	}
+}
+
+func TestAbuseIPDBVerdicts(t *testing.T) {
+	tests := []struct {
+		score   int
+		verdict string
+	}{
+		{100, verdictMalicious},
+		{75, verdictMalicious},
+		{40, verdictSuspicious},
+		{0, verdictClean},
+	}
+	for _, tt := range tests {
+		config := stubProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v2/check" || r.URL.Query().Get("ipAddress") != "1.2.3.4" {
+				t.Errorf("unexpected request %s", r.URL)
+			}
+			if r.Header.Get("Key") != testProviderKey {
+				t.Errorf("Key header = %q, want the API key", r.Header.Get("Key"))
+			}
+			writeJSON(t, w, map[string]any{"data": map[string]any{
+				"ipAddress":            "1.2.3.4",
+				"abuseConfidenceScore": tt.score,
+				"usageType":            "Data Center/Web Hosting/Transit",
+				"isTor":                true,
+			}})
+		})
+		provider, err := newAbuseIPDBProvider(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := provider.LookupIP(context.Background(), "1.2.3.4")
+		if err != nil {
+			t.Fatalf("score %d: %v", tt.score, err)
+		}
+		if result.Verdict != tt.verdict {
+			t.Errorf("score %d: verdict = %s, want %s", tt.score, result.Verdict, tt.verdict)
+		}
+		if result.Score == nil || *result.Score != tt.score {
+			t.Errorf("score %d: score = %v", tt.score, result.Score)
+		}
+		if strings.Join(result.Tags, ",") != "Data Center/Web Hosting/Transit,tor" {
+			t.Errorf("score %d: tags = %v", tt.score, result.Tags)
+		}
+	}
+}
+
+func TestThreatBookVerdicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  map[string]any
+		verdict string
+	}{
+		{"severity", map[string]any{"severity": "high", "judgments": []any{"Scanner"}}, verdictMalicious},
+		{"judgments only", map[string]any{"judgments": []any{"Proxy"}}, verdictSuspicious},
+		{"not malicious", map[string]any{"is_malicious": false}, verdictClean},
+		{"nothing", map[string]any{}, verdictUnknown},
+	}
+	for _, tt := range tests {
+		config := stubProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v3/scene/ip_reputation" || r.URL.Query().Get("resource") != "1.2.3.4" {
+				t.Errorf("unexpected request %s", r.URL)
+			}
+			if r.URL.Query().Get("apikey") != testProviderKey {
+				t.Errorf("apikey = %q, want the API key", r.URL.Query().Get("apikey"))
+			}
+			writeJSON(t, w, map[string]any{
+				"response_code": 0,
+				"data":          map[string]any{"1.2.3.4": tt.report},
+			})
+		})
+		provider, err := newThreatBookProvider(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := provider.LookupIP(context.Background(), "1.2.3.4")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result.Verdict != tt.verdict {
+			t.Errorf("%s: verdict = %s, want %s", tt.name, result.Verdict, tt.verdict)
+		}
+	}
+}
+
+func TestThreatBookResponseCodeErrors(t *testing.T) {
+	tests := []struct {
+		msg  string
+		kind error
+	}{
+		{"Invalid API key " + testProviderKey, errUnauthorized},
+		{"Beyond daily quota", errQuotaExhausted},
+		{"Too many requests", errRateLimited},
+		{"Unexpected failure", errUpstream},
+	}
+	for _, tt := range tests {
+		config := stubProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(t, w, map[string]any{"response_code": -1, "verbose_msg": tt.msg})
+		})
+		provider, err := newThreatBookProvider(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = provider.LookupIP(context.Background(), "1.2.3.4")
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("%q: error = %v, want %v", tt.msg, err, tt.kind)
+		}
+		if err != nil && strings.Contains(err.Error(), testProviderKey) {
+			t.Errorf("%q: error leaks the API key: %v", tt.msg, err)
+		}
+	}
+}
+
+func TestThreatBookMissingReportIsNotFound(t *testing.T) {
+	config := stubProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, map[string]any{"response_code": 0, "data": map[string]any{}})
+	})
+	provider, err := newThreatBookProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.LookupIP(context.Background(), "1.2.3.4"); !errors.Is(err, errNotFound) {
+		t.Errorf("error = %v, want errNotFound", err)
+	}
+}
+
+func TestVirusTotalVerdicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		stats   map[string]any
+		verdict string
+		score   int
+	}{
+		{"malicious", map[string]any{"malicious": 5, "suspicious": 0, "harmless": 45, "undetected": 50}, verdictMalicious, 5},
+		{"suspicious", map[string]any{"malicious": 1, "harmless": 60, "undetected": 39}, verdictSuspicious, 1},
+		{"clean", map[string]any{"harmless": 70, "undetected": 30}, verdictClean, 0},
+	}
+	for _, tt := range tests {
+		config := stubProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v3/domains/example.com" {
+				t.Errorf("unexpected request %s", r.URL)
+			}
+			if r.Header.Get("x-apikey") != testProviderKey {
+				t.Errorf("x-apikey header = %q, want the API key", r.Header.Get("x-apikey"))
+			}
+			writeJSON(t, w, map[string]any{"data": map[string]any{"attributes": map[string]any{
+				"last_analysis_stats":   tt.stats,
+				"last_analysis_results": map[string]any{"engine": map[string]any{"category": "harmless"}},
+				"tags":                  []any{"phishing"},
+				"reputation":            -10,
+				"whois":                 "Registrant Email: owner@example.com",
+			}}})
+		})
+		provider, err := newVirusTotalProvider(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := provider.LookupDomain(context.Background(), "example.com")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result.Verdict != tt.verdict {
+			t.Errorf("%s: verdict = %s, want %s", tt.name, result.Verdict, tt.verdict)
+		}
+		if result.Score == nil || *result.Score != tt.score {
+			t.Errorf("%s: score = %v, want %d", tt.name, result.Score, tt.score)
+		}
+		if len(result.Tags) != 1 || result.Tags[0] != "phishing" {
+			t.Errorf("%s: tags = %v", tt.name, result.Tags)
+		}
+		if _, ok := result.Data["last_analysis_results"]; ok {
+			t.Errorf("%s: per-engine results should be dropped from data", tt.name)
+		}
+		if _, ok := result.Data["whois"]; ok {
+			t.Errorf("%s: whois should be dropped from data", tt.name)
+		}
+		if result.Data["reputation"] != float64(-10) {
+			t.Errorf("%s: data reputation = %v, want -10", tt.name, result.Data["reputation"])
+		}
+	}
+}
+
+func TestProviderHTTPErrors(t *testing.T) {
+	constructors := map[string]func(*ProviderConfig) (Provider, error){
+		providerAbuseIPDB:  newAbuseIPDBProvider,
+		providerThreatBook: newThreatBookProvider,
+		providerVirusTotal: newVirusTotalProvider,
+	}
+	tests := []struct {
+		status int
+		body   string
+		kind   error
+	}{
+		{http.StatusUnauthorized, "bad key " + testProviderKey, errUnauthorized},
+		{http.StatusForbidden, "forbidden", errUnauthorized},
+		{http.StatusNotFound, "not found", errNotFound},
+		{http.StatusTooManyRequests, "slow down", errRateLimited},
+		{http.StatusTooManyRequests, "daily quota exceeded", errQuotaExhausted},
+		{http.StatusInternalServerError, "internal error", errUpstream},
+	}
+	for name, newProvider := range constructors {
+		for _, tt := range tests {
+			config := stubProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, tt.body, tt.status)
+			})
+			provider, err := newProvider(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = provider.LookupIP(context.Background(), "1.2.3.4")
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("%s HTTP %d %q: error = %v, want %v", name, tt.status, tt.body, err, tt.kind)
+			}
+			if err != nil && strings.Contains(err.Error(), testProviderKey) {
+				t.Errorf("%s HTTP %d: error leaks the API key: %v", name, tt.status, err)
+			}
+		}
+	}
+}
+
+func TestUnsupportedIndicators(t *testing.T) {
+	provider, err := newAbuseIPDBProvider(&ProviderConfig{APIKey: testProviderKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.LookupDomain(context.Background(), "example.com"); !errors.Is(err, errUnsupported) {
+		t.Errorf("domain lookup error = %v, want errUnsupported", err)
+	}
+	if _, err := provider.LookupHash(context.Background(), strings.Repeat("a", 64)); !errors.Is(err, errUnsupported) {
+		t.Errorf("hash lookup error = %v, want errUnsupported", err)
+	}
+}
+
+// Chaitin domain and hash lookups are only enabled once their endpoint is configured
+func TestChaitinUnconfiguredEndpoints(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		writeJSON(t, w, ChaitinResponse{Data: map[string]any{}})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&Config{SK: "test-sk", BaseURL: server.URL, Endpoints: map[string]string{indicatorHash: "/custom/hash"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.Supports(indicatorIP) || client.Supports(indicatorDomain) || client.Supports(indicatorURL) || !client.Supports(indicatorHash) {
+		t.Fatalf("unexpected supported indicator types")
+	}
+
+	provider := &chaitinProvider{client: client}
+	if _, err := provider.LookupDomain(context.Background(), "example.com"); !errors.Is(err, errUnsupported) {
+		t.Errorf("domain lookup error = %v, want errUnsupported", err)
+	}
+	if _, err := provider.LookupHash(context.Background(), strings.Repeat("a", 64)); err != nil {
+		t.Errorf("hash lookup: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/custom/hash" {
+		t.Errorf("requested paths = %v, want only /custom/hash", paths)
+	}
+}
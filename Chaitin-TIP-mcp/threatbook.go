@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const threatBookBaseURL = "https://api.threatbook.cn"
+
+// threatBookProvider queries the ThreatBook v3 API
+type threatBookProvider struct {
+	api *apiProvider
+}
+
+func newThreatBookProvider(config *ProviderConfig) (Provider, error) {
+	api, err := newAPIProvider(providerThreatBook, threatBookBaseURL, config)
+	if err != nil {
+		return nil, err
+	}
+	return &threatBookProvider{api: api}, nil
+}
+
+func (p *threatBookProvider) Name() string {
+	return providerThreatBook
+}
+
+// threatBookResponse wraps every ThreatBook response. A non-zero
+// response_code is an error described by verbose_msg.
+type threatBookResponse struct {
+	ResponseCode int            `json:"response_code"`
+	VerboseMsg   string         `json:"verbose_msg"`
+	Data         map[string]any `json:"data"`
+}
+
+func (p *threatBookProvider) LookupIP(ctx context.Context, ip string) (*ProviderResult, error) {
+	data, err := p.query(ctx, indicatorIP, "/v3/scene/ip_reputation", ip)
+	if err != nil {
+		return nil, err
+	}
+	// Results are keyed by the queried IP
+	report, _ := data[ip].(map[string]any)
+	if report == nil {
+		return nil, errNotFound
+	}
+	return p.result(report, "https://x.threatbook.com/v5/ip/"+ip), nil
+}
+
+func (p *threatBookProvider) LookupDomain(ctx context.Context, domain string) (*ProviderResult, error) {
+	data, err := p.query(ctx, indicatorDomain, "/v3/domain/query", domain)
+	if err != nil {
+		return nil, err
+	}
+	// Results are keyed by the queried domain, either directly or under "domains"
+	report, _ := data[domain].(map[string]any)
+	if domains, ok := data["domains"].(map[string]any); ok && report == nil {
+		report, _ = domains[domain].(map[string]any)
+	}
+	if report == nil {
+		return nil, errNotFound
+	}
+	return p.result(report, "https://x.threatbook.com/v5/domain/"+domain), nil
+}
+
+func (p *threatBookProvider) LookupHash(ctx context.Context, hash string) (*ProviderResult, error) {
+	data, err := p.query(ctx, indicatorHash, "/v3/file/report", hash)
+	if err != nil {
+		return nil, err
+	}
+	summary, _ := data["summary"].(map[string]any)
+	if summary == nil {
+		return nil, errNotFound
+	}
+	result := p.result(summary, "https://s.threatbook.com/report/file/"+hash)
+	if score, ok := summary["threat_score"].(float64); ok {
+		value := int(score)
+		result.Score = &value
+	}
+	for _, key := range []string{"malware_type", "malware_family"} {
+		if value, ok := summary[key].(string); ok && value != "" {
+			result.Tags = append(result.Tags, value)
+		}
+	}
+	return result, nil
+}
+
+// query requests a ThreatBook endpoint and returns its data
+func (p *threatBookProvider) query(ctx context.Context, indicator, path, resource string) (map[string]any, error) {
+	query := url.Values{}
+	query.Set("apikey", p.api.key)
+	query.Set("resource", resource)
+
+	var resp threatBookResponse
+	if err := p.api.get(ctx, indicator, path, query, http.Header{}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.ResponseCode != 0 {
+		return nil, &APIError{
+			StatusCode: http.StatusOK,
+			Code:       resp.ResponseCode,
+			Msg:        redactSecret(resp.VerboseMsg, p.api.key),
+			Kind:       classifyAPIError(http.StatusOK, resp.VerboseMsg),
+		}
+	}
+	return resp.Data, nil
+}
+
+// result derives the verdict from severity, threat_level or is_malicious,
+// falling back to suspicious when ThreatBook has any judgments
+func (p *threatBookProvider) result(report map[string]any, link string) *ProviderResult {
+	result := &ProviderResult{
+		Provider: providerThreatBook,
+		Verdict:  verdictUnknown,
+		Link:     link,
+		Data:     report,
+	}
+	for _, key := range []string{"severity", "threat_level"} {
+		if level, ok := report[key].(string); ok {
+			if verdict := severityVerdict(level); verdict != verdictUnknown {
+				result.Verdict = verdict
+				break
+			}
+		}
+	}
+	if malicious, ok := report["is_malicious"].(bool); ok && result.Verdict == verdictUnknown {
+		result.Verdict = verdictClean
+		if malicious {
+			result.Verdict = verdictMalicious
+		}
+	}
+
+	if judgments, ok := report["judgments"].([]any); ok {
+		for _, judgment := range judgments {
+			result.Tags = append(result.Tags, fmt.Sprint(judgment))
+		}
+		if len(judgments) > 0 && result.Verdict == verdictUnknown {
+			result.Verdict = verdictSuspicious
+		}
+	}
+	if classes, ok := report["tags_classes"].([]any); ok {
+		for _, class := range classes {
+			class, _ := class.(map[string]any)
+			tags, _ := class["tags"].([]any)
+			for _, tag := range tags {
+				if str, ok := tag.(string); ok && strings.TrimSpace(str) != "" {
+					result.Tags = append(result.Tags, str)
+				}
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registerLookupTools adds the domain, URL and hash lookup tools. They are
+// registered even when their Chaitin endpoint is not configured, so the
+// watchlist is still checked and calls explain what to configure.
+func registerLookupTools(s *server.MCPServer, client *Client, watch *watchlist) {
+	domainLookupTool := mcp.NewTool("domain_lookup",
+		mcp.WithDescription("Look up domain information using Chaitin Threat Intelligence, e.g. to check a phishing or C2 domain"+
+			endpointNote(client, indicatorDomain)),
+		mcp.WithString("domain",
+			mcp.Required(),
+			mcp.Description("The domain to look up, e.g. example.com"),
+		),
+		mcp.WithBoolean("force_refresh",
+			mcp.Description("Bypass the lookup cache and query the API"),
+		),
+	)
+	s.AddTool(domainLookupTool, wrapTool(lookupHandler("domain", normalizeDomain, client.LookupDomain, watch)))
+
+	urlLookupTool := mcp.NewTool("url_lookup",
+		mcp.WithDescription("Look up URL information using Chaitin Threat Intelligence"+
+			endpointNote(client, indicatorURL)),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("The full URL to look up, including the scheme"),
+		),
+		mcp.WithBoolean("force_refresh",
+			mcp.Description("Bypass the lookup cache and query the API"),
+		),
+	)
+	s.AddTool(urlLookupTool, wrapTool(lookupHandler("url", normalizeURL, client.LookupURL, watch)))
+
+	hashLookupTool := mcp.NewTool("hash_lookup",
+		mcp.WithDescription("Look up a file hash using Chaitin Threat Intelligence, e.g. to identify a malware sample"+
+			endpointNote(client, indicatorHash)),
+		mcp.WithString("hash",
+			mcp.Required(),
+			mcp.Description("The MD5, SHA-1 or SHA-256 hash of the file"),
+		),
+		mcp.WithBoolean("force_refresh",
+			mcp.Description("Bypass the lookup cache and query the API"),
+		),
+	)
+	s.AddTool(hashLookupTool, wrapTool(lookupHandler("hash", normalizeHash, client.LookupHash, watch)))
+}
+
+// endpointNote tells the model, and logs, that lookups of an indicator type
+// fail until its Chaitin endpoint is configured
+func endpointNote(client *Client, indicator string) string {
+	if client.Supports(indicator) {
+		return ""
+	}
+	log.Printf("%s_lookup only checks the watchlist, set endpoints.%s to query Chaitin", indicator, indicator)
+	return fmt.Sprintf(". The Chaitin endpoint for %s lookups is not configured yet, so only the local watchlist is checked "+
+		"and the call reports an error until endpoints.%s is set in the config", indicator, indicator)
+}
+
+// lookupHandler builds a tool handler that reads one string argument,
+// normalizes it and returns the lookup result as JSON, preceded by any
+// watchlist matches. The argument name is also the indicator type.
+func lookupHandler(arg string, normalize func(string) (string, error),
+	lookup func(context.Context, string) (*ChaitinResponse, error), watch *watchlist) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		value, _ := request.Params.Arguments[arg].(string)
+		value, err := normalize(value)
+		if err != nil {
+			return toolError(fmt.Sprintf("invalid %s: %v", arg, err)), nil
+		}
+
+		var content []mcp.Content
+		if matches := watch.match(arg, value); len(matches) > 0 {
+			content = append(content, mcp.NewTextContent(watchSummary(matches)))
+		}
+
+		forceRefresh, _ := request.Params.Arguments["force_refresh"].(bool)
+		result, err := lookup(withForceRefresh(ctx, forceRefresh), value)
+		if errors.Is(err, errNotFound) {
+			content = append(content, mcp.NewTextContent(fmt.Sprintf("No intelligence found for %s", value)))
+			return &mcp.CallToolResult{Content: content}, nil
+		}
+		if err != nil {
+			// Watchlist matches still hold when the lookup fails
+			failed := lookupError(err)
+			failed.Content = append(content, failed.Content...)
+			return failed, nil
+		}
+
+		jsonResult, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format result: %v", err)
+		}
+		content = append(content, mcp.NewTextContent(string(jsonResult)))
+		return &mcp.CallToolResult{Content: content}, nil
+	}
+}
+
+// normalizeIP checks that the value is an IPv4 or IPv6 address and returns
+// its canonical form. With rejectPrivate, private and reserved addresses are
+// refused since the API has no intelligence on them.
+func normalizeIP(ip string, rejectPrivate bool) (string, error) {
+	ip = strings.TrimSpace(ip)
+	if ip == "" {
+		return "", fmt.Errorf("ip is required")
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]"))
+	if err != nil {
+		return "", fmt.Errorf("%q is not an IPv4 or IPv6 address", ip)
+	}
+	if addr.Zone() != "" {
+		return "", fmt.Errorf("%q has an IPv6 zone, which is only meaningful on the local host", ip)
+	}
+	addr = addr.Unmap()
+	if rejectPrivate {
+		if reason := reservedAddrReason(addr); reason != "" {
+			return "", fmt.Errorf("%s is a %s", addr, reason)
+		}
+	}
+	return addr.String(), nil
+}
+
+// normalizeDomain lowercases a domain and strips a trailing dot
+func normalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" {
+		return "", fmt.Errorf("domain is required")
+	}
+	if strings.ContainsAny(domain, "/:@ ") || !strings.Contains(domain, ".") {
+		return "", fmt.Errorf("%q is not a domain name", domain)
+	}
+	return domain, nil
+}
+
+// normalizeURL checks that the URL is absolute
+func normalizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("url is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("%q is not an absolute url", raw)
+	}
+	return raw, nil
+}
+
+// normalizeHash lowercases a hash and checks it is MD5, SHA-1 or SHA-256
+func normalizeHash(hash string) (string, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if hash == "" {
+		return "", fmt.Errorf("hash is required")
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", fmt.Errorf("%q is not a hex string", hash)
+	}
+	switch len(hash) {
+	case 32, 40, 64:
+		return hash, nil
+	default:
+		return "", fmt.Errorf("expected an MD5, SHA-1 or SHA-256 hash, got %d hex characters", len(hash))
+	}
+}
+
+// lookupError turns a failed lookup into a tool error, explaining what the
+// model or user can do about it
+func lookupError(err error) *mcp.CallToolResult {
+	switch {
+	case errors.Is(err, errQuotaExhausted):
+		return toolError(fmt.Sprintf("The Chaitin API quota is exhausted, further lookups will fail until it resets: %v", err))
+	case errors.Is(err, errRateLimited):
+		return toolError(fmt.Sprintf("The Chaitin API is rate limiting requests, retry in a moment: %v", err))
+	case errors.Is(err, errUnauthorized):
+		return toolError(fmt.Sprintf("The Chaitin API rejected the secret key, check the sk setting: %v", err))
+	case errors.Is(err, errUnsupported):
+		return toolError(fmt.Sprintf("The Chaitin endpoint for this lookup is not configured: %v", err))
+	default:
+		return toolError(err.Error())
+	}
+}
+
+// toolError builds a tool result reporting an error the model can act on
+func toolError(msg string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: msg}},
+		IsError: true,
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct {
+		ip            string
+		rejectPrivate bool
+		want          string
+		err           string
+	}{
+		{" 8.8.8.8 ", false, "8.8.8.8", ""},
+		{"2001:DB8::1", false, "2001:db8::1", ""},
+		{"[2606:4700::1111]", false, "2606:4700::1111", ""},
+		{"::ffff:1.2.3.4", false, "1.2.3.4", ""},
+		{"", false, "", "ip is required"},
+		{"example.com", false, "", "not an IPv4 or IPv6 address"},
+		{"1.2.3.4/24", false, "", "not an IPv4 or IPv6 address"},
+		{"fe80::1%eth0", false, "", "IPv6 zone"},
+		{"10.0.0.1", false, "10.0.0.1", ""},
+		{"10.0.0.1", true, "", "private address"},
+		{"127.0.0.1", true, "", "loopback address"},
+		{"::ffff:192.168.1.1", true, "", "private address"},
+		{"8.8.8.8", true, "8.8.8.8", ""},
+	}
+	for _, tt := range tests {
+		got, err := normalizeIP(tt.ip, tt.rejectPrivate)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("normalizeIP(%q, %v) error = %v, want %q", tt.ip, tt.rejectPrivate, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeIP(%q, %v) = %q, %v, want %q", tt.ip, tt.rejectPrivate, got, err, tt.want)
+		}
+	}
+}
+
+func TestLookupError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&APIError{StatusCode: 200, Code: 1001, Msg: "quota exhausted", Kind: errQuotaExhausted}, "quota is exhausted"},
+		{&APIError{StatusCode: 429, Kind: errRateLimited}, "rate limiting"},
+		{&APIError{StatusCode: 401, Kind: errUnauthorized}, "rejected the secret key"},
+		{fmt.Errorf("%w: set endpoints.hash", errUnsupported), "endpoint for this lookup is not configured: indicator type not supported by this provider: set endpoints.hash"},
+		{fmt.Errorf("failed to query Chaitin API: %w", errors.New("timeout")), "failed to query Chaitin API: timeout"},
+	}
+	for _, tt := range tests {
+		result := lookupError(tt.err)
+		if !result.IsError {
+			t.Errorf("lookupError(%v) is not an error result", tt.err)
+		}
+		text := result.Content[0].(mcp.TextContent).Text
+		if !strings.Contains(text, tt.want) {
+			t.Errorf("lookupError(%v) = %q, want it to contain %q", tt.err, text, tt.want)
+		}
+	}
+}
+
+// Lookup tools are registered without their Chaitin endpoint: the tool list
+// says what to configure, and calls report watchlist matches and a clear error
+func TestLookupToolsWithoutEndpoints(t *testing.T) {
+	client, err := NewClient(&Config{SK: "test-sk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch := &watchlist{entries: make(map[string][]*WatchEntry)}
+	watch.insert(&WatchEntry{Type: indicatorDomain, Value: "evil.com", Verdict: verdictMalicious, Source: watchSourceManual})
+	s := server.NewMCPServer("test", "1.0.0")
+	registerLookupTools(s, client, watch)
+
+	response := s.HandleMessage(context.Background(), []byte(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`))
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Result mcp.ListToolsResult `json:"result"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	descriptions := make(map[string]string)
+	for _, tool := range list.Result.Tools {
+		descriptions[tool.Name] = tool.Description
+	}
+	for _, name := range []string{"domain_lookup", "url_lookup", "hash_lookup"} {
+		indicator := strings.TrimSuffix(name, "_lookup")
+		if !strings.Contains(descriptions[name], "endpoints."+indicator) {
+			t.Errorf("%s description = %q, want it to mention endpoints.%s", name, descriptions[name], indicator)
+		}
+	}
+
+	response = s.HandleMessage(context.Background(), []byte(`{"jsonrpc": "2.0", "id": 2, "method": "tools/call",
+		"params": {"name": "domain_lookup", "arguments": {"domain": "EVIL.com"}}}`))
+	result, ok := response.(mcp.JSONRPCResponse).Result.(mcp.CallToolResult)
+	if !ok {
+		t.Fatalf("tools/call response = %#v", response)
+	}
+	if !result.IsError || len(result.Content) != 2 {
+		t.Fatalf("result = %+v, want an error after the watchlist match", result)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "Watchlist: domain evil.com") {
+		t.Errorf("first content = %q, want the watchlist match", text)
+	}
+	if text := result.Content[1].(mcp.TextContent).Text; !strings.Contains(text, "not configured") || !strings.Contains(text, "endpoints.domain") {
+		t.Errorf("error = %q, want it to name endpoints.domain", text)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace exporters
+const (
+	exporterOTLP   = "otlp"   // OTLP over HTTP to a collector, Jaeger, Tempo, etc.
+	exporterStdout = "stdout" // Written to stderr so it does not corrupt the stdio transport
+)
+
+const (
+	defaultServiceName  = "chaitin-mcp"
+	instrumentationName = "chaitin-tip"
+)
+
+// TracingConfig configures OpenTelemetry tracing
+type TracingConfig struct {
+	Exporter    string            `json:"exporter"`               // otlp or stdout
+	Endpoint    string            `json:"endpoint,omitempty"`     // OTLP/HTTP endpoint such as http://localhost:4318; falls back to OTEL_EXPORTER_OTLP_ENDPOINT
+	Headers     map[string]string `json:"headers,omitempty"`      // Extra headers sent to the OTLP endpoint
+	ServiceName string            `json:"service_name,omitempty"` // Defaults to chaitin-mcp
+	SampleRatio float64           `json:"sample_ratio,omitempty"` // Fraction of traces to sample (0-1), defaults to 1
+}
+
+// setupTracing installs the global tracer provider. The returned function
+// flushes pending spans and should be called before exiting.
+func setupTracing(ctx context.Context, config *TracingConfig) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case exporterOTLP:
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(config.Endpoint))
+		}
+		if len(config.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(config.Headers))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case exporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter: %s", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %v", err)
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %v", err)
+	}
+
+	ratio := config.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// tracer returns the tracer used by this server. It is a no-op until setupTracing is called.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// traceTool wraps a tool handler in a span so upstream requests made by the
+// handler are recorded as its children
+func traceTool(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tool := request.Params.Name
+		ctx, span := tracer().Start(ctx, "tool "+tool,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("mcp.tool", tool)),
+		)
+		defer span.End()
+
+		result, err := handler(ctx, request)
+		switch {
+		case err != nil:
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		case result != nil && result.IsError:
+			span.SetStatus(codes.Error, "tool returned an error result")
+		}
+		return result, err
+	}
+}
+
+// wrapTool adds tracing and audit logging to a tool handler
+func wrapTool(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return traceTool(auditTool(handler))
+}
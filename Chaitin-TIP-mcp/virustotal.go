@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const virusTotalBaseURL = "https://www.virustotal.com"
+
+// virusTotalProvider queries the VirusTotal v3 API. Verdicts come from the
+// number of engines flagging the indicator in the last analysis.
+type virusTotalProvider struct {
+	api *apiProvider
+}
+
+func newVirusTotalProvider(config *ProviderConfig) (Provider, error) {
+	api, err := newAPIProvider(providerVirusTotal, virusTotalBaseURL, config)
+	if err != nil {
+		return nil, err
+	}
+	return &virusTotalProvider{api: api}, nil
+}
+
+func (p *virusTotalProvider) Name() string {
+	return providerVirusTotal
+}
+
+// virusTotalFields are the attributes copied into the result data. Other
+// attributes, such as per-engine results, WHOIS records, DNS records and
+// certificates, are large or may hold third-party personal data.
+var virusTotalFields = []string{
+	"last_analysis_stats", "last_analysis_date", "reputation", "total_votes",
+	// IP addresses
+	"country", "asn", "as_owner", "network",
+	// Domains
+	"registrar", "creation_date", "categories",
+	// Files
+	"meaningful_name", "type_description", "size", "md5", "sha1", "sha256",
+}
+
+// virusTotalObject is the response for an IP address, domain or file
+type virusTotalObject struct {
+	Data struct {
+		Attributes map[string]any `json:"attributes"`
+	} `json:"data"`
+}
+
+func (p *virusTotalProvider) LookupIP(ctx context.Context, ip string) (*ProviderResult, error) {
+	return p.lookup(ctx, indicatorIP, "/api/v3/ip_addresses/"+ip, "https://www.virustotal.com/gui/ip-address/"+ip)
+}
+
+func (p *virusTotalProvider) LookupDomain(ctx context.Context, domain string) (*ProviderResult, error) {
+	return p.lookup(ctx, indicatorDomain, "/api/v3/domains/"+domain, "https://www.virustotal.com/gui/domain/"+domain)
+}
+
+func (p *virusTotalProvider) LookupHash(ctx context.Context, hash string) (*ProviderResult, error) {
+	return p.lookup(ctx, indicatorHash, "/api/v3/files/"+hash, "https://www.virustotal.com/gui/file/"+hash)
+}
+
+func (p *virusTotalProvider) lookup(ctx context.Context, indicator, path, link string) (*ProviderResult, error) {
+	header := http.Header{}
+	header.Set("x-apikey", p.api.key)
+
+	var object virusTotalObject
+	if err := p.api.get(ctx, indicator, path, url.Values{}, header, &object); err != nil {
+		return nil, err
+	}
+	attributes := object.Data.Attributes
+
+	result := &ProviderResult{
+		Provider: providerVirusTotal,
+		Verdict:  verdictUnknown,
+		Link:     link,
+		Data:     make(map[string]any),
+	}
+	if stats, ok := attributes["last_analysis_stats"].(map[string]any); ok {
+		count := func(key string) int {
+			value, _ := stats[key].(float64)
+			return int(value)
+		}
+		malicious, suspicious, harmless := count("malicious"), count("suspicious"), count("harmless")
+		if total := malicious + suspicious + harmless + count("undetected"); total > 0 {
+			score := malicious * 100 / total
+			result.Score = &score
+		}
+		switch {
+		case malicious >= 3:
+			result.Verdict = verdictMalicious
+		case malicious > 0 || suspicious > 0:
+			result.Verdict = verdictSuspicious
+		case harmless > 0:
+			result.Verdict = verdictClean
+		}
+	}
+	if tags, ok := attributes["tags"].([]any); ok {
+		for _, tag := range tags {
+			if str, ok := tag.(string); ok {
+				result.Tags = append(result.Tags, str)
+			}
+		}
+	}
+
+	for _, key := range virusTotalFields {
+		if value, ok := attributes[key]; ok {
+			result.Data[key] = value
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,878 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// watchlistProvider is the watchlist's name as a multi_lookup source
+const watchlistProvider = "watchlist"
+
+// watchSourceManual marks entries added with the watchlist_add tool
+const watchSourceManual = "manual"
+
+// WatchlistConfig loads local IOC lists. Entries added with the
+// watchlist_add tool are kept in memory unless path is set.
+type WatchlistConfig struct {
+	Files []string `json:"files,omitempty"` // Plain text (one indicator per line), CSV or STIX 2.1 bundle (.json)
+	Path  string   `json:"path,omitempty"`  // Persist manually added entries to this file
+}
+
+// WatchEntry is one indicator on a local list
+type WatchEntry struct {
+	Type    string     `json:"type"` // ip, cidr, domain, url or hash
+	Value   string     `json:"value"`
+	Verdict string     `json:"verdict"` // What a match means, malicious unless the list says otherwise
+	Tags    []string   `json:"tags,omitempty"`
+	Comment string     `json:"comment,omitempty"`
+	Source  string     `json:"source"` // Path of the file the entry was loaded from, or "manual"
+	Added   time.Time  `json:"added"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func (e *WatchEntry) expired(now time.Time) bool {
+	return e.Expires != nil && !now.Before(*e.Expires)
+}
+
+// watchlist holds local IOC lists and matches indicators against them.
+// Domains match their subdomains, and IPs match CIDR entries containing them.
+type watchlist struct {
+	mu      sync.RWMutex
+	entries map[string][]*WatchEntry // type + value -> entries from each source
+	cidrs   []*WatchEntry
+	path    string
+}
+
+// loadWatchlist loads the configured files and persisted manual entries
+func loadWatchlist(config *WatchlistConfig) (*watchlist, error) {
+	w := &watchlist{entries: make(map[string][]*WatchEntry)}
+	if config == nil {
+		return w, nil
+	}
+	w.path = config.Path
+
+	for _, file := range config.Files {
+		// Invalid lines are skipped so one typo does not drop the whole list,
+		// but a file without a single valid entry is an error
+		entries, err := readWatchFile(file)
+		if err != nil && len(entries) == 0 {
+			return nil, fmt.Errorf("watchlist %s: %v", file, err)
+		}
+		if err != nil {
+			log.Printf("Watchlist %s: skipped invalid entries:\n%v", file, err)
+		}
+		for _, entry := range entries {
+			w.insert(entry)
+		}
+	}
+
+	if w.path != "" {
+		data, err := os.ReadFile(w.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read watchlist: %v", err)
+		}
+		if err == nil {
+			var entries []*WatchEntry
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return nil, fmt.Errorf("failed to parse watchlist: %v", err)
+			}
+			for _, entry := range entries {
+				w.insert(entry)
+			}
+		}
+	}
+	w.purgeExpired(time.Now())
+	return w, nil
+}
+
+func watchKey(kind, value string) string {
+	return kind + "\x00" + value
+}
+
+// insert adds an entry, replacing one with the same value from the same source
+func (w *watchlist) insert(entry *WatchEntry) {
+	key := watchKey(entry.Type, entry.Value)
+	for i, existing := range w.entries[key] {
+		if existing.Source == entry.Source {
+			w.entries[key][i] = entry
+			w.reindexCIDRs()
+			return
+		}
+	}
+	w.entries[key] = append(w.entries[key], entry)
+	if entry.Type == indicatorCIDR {
+		w.cidrs = append(w.cidrs, entry)
+	}
+}
+
+func (w *watchlist) reindexCIDRs() {
+	w.cidrs = w.cidrs[:0]
+	for _, entries := range w.entries {
+		for _, entry := range entries {
+			if entry.Type == indicatorCIDR {
+				w.cidrs = append(w.cidrs, entry)
+			}
+		}
+	}
+}
+
+// purgeExpired drops expired entries so they do not accumulate. Matching
+// already ignores them; this only frees the memory. The caller holds the lock.
+func (w *watchlist) purgeExpired(now time.Time) {
+	purged := false
+	for key, entries := range w.entries {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.expired(now) {
+				purged = true
+			} else {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == 0 {
+			delete(w.entries, key)
+		} else {
+			w.entries[key] = kept
+		}
+	}
+	if purged {
+		w.reindexCIDRs()
+	}
+}
+
+// add adds or replaces a manual entry and persists the manual entries. If
+// they cannot be saved the entry is not added.
+func (w *watchlist) add(entry *WatchEntry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.purgeExpired(time.Now())
+	key := watchKey(entry.Type, entry.Value)
+	previous := slices.Clone(w.entries[key])
+	w.insert(entry)
+	if err := w.save(); err != nil {
+		w.restore(key, previous)
+		return err
+	}
+	return nil
+}
+
+// restore puts back the entries for key as they were before a change that
+// could not be saved. The caller holds the lock.
+func (w *watchlist) restore(key string, entries []*WatchEntry) {
+	if len(entries) == 0 {
+		delete(w.entries, key)
+	} else {
+		w.entries[key] = entries
+	}
+	w.reindexCIDRs()
+}
+
+// remove deletes a manual entry, keeping it if the manual entries cannot be
+// saved. Entries loaded from files can only be
+// removed by editing the file, since they would return on restart.
+func (w *watchlist) remove(kind, value string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.purgeExpired(time.Now())
+
+	key := watchKey(kind, value)
+	entries := w.entries[key]
+	var kept []*WatchEntry
+	removed := false
+	for _, entry := range entries {
+		if entry.Source == watchSourceManual {
+			removed = true
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	if !removed {
+		if len(entries) > 0 {
+			return fmt.Errorf("%s is listed in %s, remove it from the file instead", value, entries[0].Source)
+		}
+		return fmt.Errorf("%s is not on the watchlist", value)
+	}
+	w.restore(key, kept)
+	if err := w.save(); err != nil {
+		w.restore(key, entries)
+		return err
+	}
+	return nil
+}
+
+// save writes the manual entries to the watchlist path, if set. The caller holds the lock.
+func (w *watchlist) save() error {
+	if w.path == "" {
+		return nil
+	}
+	now := time.Now()
+	manual := []*WatchEntry{}
+	for _, entries := range w.entries {
+		for _, entry := range entries {
+			if entry.Source == watchSourceManual && !entry.expired(now) {
+				manual = append(manual, entry)
+			}
+		}
+	}
+	sortWatchEntries(manual)
+	data, err := json.MarshalIndent(manual, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal watchlist: %v", err)
+	}
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write watchlist: %v", err)
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return fmt.Errorf("failed to write watchlist: %v", err)
+	}
+	return nil
+}
+
+// match returns the unexpired entries matching an indicator
+func (w *watchlist) match(kind, value string) []WatchEntry {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.matchLocked(kind, value, time.Now())
+}
+
+func (w *watchlist) matchLocked(kind, value string, now time.Time) []WatchEntry {
+	var matches []WatchEntry
+	collect := func(kind, value string) {
+		for _, entry := range w.entries[watchKey(kind, value)] {
+			if !entry.expired(now) {
+				matches = append(matches, *entry)
+			}
+		}
+	}
+	// a.b.example.com matches entries for itself, b.example.com and example.com
+	collectDomain := func(domain string) {
+		for name := domain; strings.Contains(name, "."); name = name[strings.Index(name, ".")+1:] {
+			collect(indicatorDomain, name)
+		}
+	}
+
+	switch kind {
+	case indicatorIP:
+		collect(indicatorIP, value)
+		if addr, err := netip.ParseAddr(value); err == nil {
+			for _, entry := range w.cidrs {
+				if prefix, err := netip.ParsePrefix(entry.Value); err == nil && prefix.Contains(addr.Unmap()) && !entry.expired(now) {
+					matches = append(matches, *entry)
+				}
+			}
+		}
+	case indicatorDomain:
+		collectDomain(value)
+	case indicatorURL:
+		// URLs also match entries for their host
+		collect(indicatorURL, value)
+		if u, err := url.Parse(value); err == nil && u.Hostname() != "" {
+			host := strings.ToLower(u.Hostname())
+			if _, err := netip.ParseAddr(host); err == nil {
+				matches = append(matches, w.matchLocked(indicatorIP, host, now)...)
+			} else {
+				collectDomain(host)
+			}
+		}
+	default:
+		collect(kind, value)
+	}
+	return matches
+}
+
+// list returns unexpired entries filtered by type, tag and source, sorted by
+// type and value. Expired entries are purged on the way.
+func (w *watchlist) list(kind, tag, source string) []WatchEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.purgeExpired(time.Now())
+
+	var listed []*WatchEntry
+	for _, entries := range w.entries {
+		for _, entry := range entries {
+			switch {
+			case kind != "" && entry.Type != kind:
+			case source != "" && entry.Source != source:
+			case tag != "" && !containsFold(entry.Tags, tag):
+			default:
+				listed = append(listed, entry)
+			}
+		}
+	}
+	sortWatchEntries(listed)
+	result := make([]WatchEntry, len(listed))
+	for i, entry := range listed {
+		result[i] = *entry
+	}
+	return result
+}
+
+func sortWatchEntries(entries []*WatchEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type < entries[j].Type
+		}
+		if entries[i].Value != entries[j].Value {
+			return entries[i].Value < entries[j].Value
+		}
+		return entries[i].Source < entries[j].Source
+	})
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchSummary describes watchlist matches in one line each
+func watchSummary(matches []WatchEntry) string {
+	lines := make([]string, len(matches))
+	for i, match := range matches {
+		line := fmt.Sprintf("Watchlist: %s %s (%s in %s)", match.Type, match.Value, match.Verdict, match.Source)
+		if len(match.Tags) > 0 {
+			line += ", tags: " + strings.Join(match.Tags, ", ")
+		}
+		if match.Comment != "" {
+			line += ", " + match.Comment
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Name makes the watchlist a Provider, so multi_lookup reports local list matches as a source
+func (w *watchlist) Name() string {
+	return watchlistProvider
+}
+
+func (w *watchlist) LookupIP(ctx context.Context, ip string) (*ProviderResult, error) {
+	return w.result(w.match(indicatorIP, ip))
+}
+
+func (w *watchlist) LookupDomain(ctx context.Context, domain string) (*ProviderResult, error) {
+	return w.result(w.match(indicatorDomain, domain))
+}
+
+func (w *watchlist) LookupHash(ctx context.Context, hash string) (*ProviderResult, error) {
+	return w.result(w.match(indicatorHash, hash))
+}
+
+// result merges matches into one result with the most severe verdict
+func (w *watchlist) result(matches []WatchEntry) (*ProviderResult, error) {
+	if len(matches) == 0 {
+		return nil, errNotFound
+	}
+	result := &ProviderResult{
+		Provider: w.Name(),
+		Verdict:  verdictUnknown,
+		Data:     map[string]any{"entries": matches},
+	}
+	for _, match := range matches {
+		if verdictSeverity[match.Verdict] > verdictSeverity[result.Verdict] {
+			result.Verdict = match.Verdict
+		}
+		for _, tag := range match.Tags {
+			if !containsFold(result.Tags, tag) {
+				result.Tags = append(result.Tags, tag)
+			}
+		}
+	}
+	return result, nil
+}
+
+// parseWatchIndicator refangs and normalizes an indicator, detecting its
+// type when kind is empty
+func parseWatchIndicator(raw, kind string) (string, string, error) {
+	value := strings.TrimSpace(refang(raw))
+	if kind == "" {
+		switch {
+		case strings.Contains(value, "://"):
+			kind = indicatorURL
+		case strings.Contains(value, "/"):
+			kind = indicatorCIDR
+		default:
+			var err error
+			value, kind, err = normalizeIndicator(value, "")
+			return value, kind, err
+		}
+	}
+	var err error
+	switch kind {
+	case indicatorCIDR:
+		var prefix netip.Prefix
+		if prefix, err = netip.ParsePrefix(value); err == nil {
+			value = prefix.Masked().String()
+		}
+	case indicatorURL:
+		value, err = normalizeURL(value)
+	default:
+		value, kind, err = normalizeIndicator(value, kind)
+	}
+	return value, kind, err
+}
+
+// parseVerdict checks a verdict, defaulting to malicious
+func parseVerdict(verdict string) (string, error) {
+	verdict = strings.ToLower(strings.TrimSpace(verdict))
+	if verdict == "" {
+		return verdictMalicious, nil
+	}
+	if _, ok := verdictSeverity[verdict]; !ok || verdict == verdictUnknown {
+		return "", fmt.Errorf("unknown verdict %q, must be one of malicious, suspicious, clean", verdict)
+	}
+	return verdict, nil
+}
+
+// parseExpiry accepts a duration from now such as "72h" or an RFC 3339 time
+func parseExpiry(value string, now time.Time) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("expiry must be in the future")
+		}
+		expires := now.Add(d).UTC()
+		return &expires, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid expiry %q, use a duration such as 72h or a time such as 2025-01-31T00:00:00Z", value)
+}
+
+// splitTags splits a tag field on commas, semicolons or pipes
+func splitTags(field string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(field, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// readWatchFile loads entries from a file, choosing the format by extension
+func readWatchFile(path string) ([]*WatchEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	// The full path keeps feeds with the same file name in different directories apart
+	source := filepath.Clean(path)
+	added := info.ModTime().UTC()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return readWatchCSV(file, source, added)
+	case ".json":
+		return readWatchSTIX(file, source, added)
+	default:
+		return readWatchText(file, source, added)
+	}
+}
+
+// readWatchText reads one indicator per line. Blank lines and lines
+// starting with # are skipped, and text after the indicator is a comment.
+func readWatchText(r io.Reader, source string, added time.Time) ([]*WatchEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	var entries []*WatchEntry
+	var errs []error
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// The indicator is separated from its comment by spaces or tabs
+		raw, comment := line, ""
+		if i := strings.IndexFunc(line, unicode.IsSpace); i >= 0 {
+			raw, comment = line[:i], line[i:]
+		}
+		value, kind, err := parseWatchIndicator(raw, "")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", n+1, err))
+			continue
+		}
+		entries = append(entries, &WatchEntry{
+			Type:    kind,
+			Value:   value,
+			Verdict: verdictMalicious,
+			Comment: strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "#")),
+			Source:  source,
+			Added:   added,
+		})
+	}
+	return entries, errors.Join(errs...)
+}
+
+// readWatchCSV reads a CSV file with a header row. The indicator column is
+// named value, indicator or ioc; type, tags, verdict, comment (or
+// description) and expires columns are optional.
+func readWatchCSV(r io.Reader, source string, added time.Time) ([]*WatchEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(names ...string) int {
+		for _, name := range names {
+			if i, ok := columns[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	valueCol := column("value", "indicator", "ioc")
+	if valueCol < 0 {
+		return nil, fmt.Errorf("csv header must have a value, indicator or ioc column")
+	}
+	typeCol, tagsCol, verdictCol := column("type"), column("tags", "tag", "labels"), column("verdict")
+	commentCol, expiresCol := column("comment", "description"), column("expires", "expiry", "valid_until")
+
+	var entries []*WatchEntry
+	var errs []error
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		// A malformed record only loses that record; the reader carries on
+		// with the next line
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			errs = append(errs, fmt.Errorf("line %d: %v", parseErr.Line, parseErr.Err))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read csv: %v", err))
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if field(valueCol) == "" {
+			continue
+		}
+
+		value, kind, err := parseWatchIndicator(field(valueCol), field(typeCol))
+		if err == nil {
+			var verdict string
+			var expires *time.Time
+			if verdict, err = parseVerdict(field(verdictCol)); err == nil {
+				if expires, err = parseExpiry(field(expiresCol), added); err == nil {
+					entries = append(entries, &WatchEntry{
+						Type:    kind,
+						Value:   value,
+						Verdict: verdict,
+						Tags:    splitTags(field(tagsCol)),
+						Comment: field(commentCol),
+						Source:  source,
+						Added:   added,
+						Expires: expires,
+					})
+					continue
+				}
+			}
+		}
+		errs = append(errs, fmt.Errorf("line %d: %v", line, err))
+	}
+	return entries, errors.Join(errs...)
+}
+
+// STIX patterns for the observable types the watchlist understands, e.g.
+// [ipv4-addr:value = '1.2.3.4'] or [file:hashes.'SHA-256' = '...']
+var (
+	stixValueRe = regexp.MustCompile(`(ipv4-addr|ipv6-addr|domain-name|url):value\s*=\s*'((?:[^'\\]|\\.)*)'`)
+	stixHashRe  = regexp.MustCompile(`file:hashes\.(?:'[^']+'|[A-Za-z0-9-]+)\s*=\s*'([0-9a-fA-F]+)'`)
+)
+
+// stixTypes maps STIX observable types to indicator types
+var stixTypes = map[string]string{
+	"ipv4-addr":   indicatorIP,
+	"ipv6-addr":   indicatorIP,
+	"domain-name": indicatorDomain,
+	"url":         indicatorURL,
+}
+
+// stixObject holds the fields read from indicators and observables in a bundle
+type stixObject struct {
+	Type           string            `json:"type"`
+	Pattern        string            `json:"pattern"`
+	PatternType    string            `json:"pattern_type"`
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	Labels         []string          `json:"labels"`
+	IndicatorTypes []string          `json:"indicator_types"`
+	ValidUntil     string            `json:"valid_until"`
+	Revoked        bool              `json:"revoked"`
+	Value          string            `json:"value"`
+	Hashes         map[string]string `json:"hashes"`
+}
+
+// readWatchSTIX reads indicators and IP, domain, URL and file observables from a STIX 2.1 bundle
+func readWatchSTIX(r io.Reader, source string, added time.Time) ([]*WatchEntry, error) {
+	var bundle struct {
+		Type    string       `json:"type"`
+		Objects []stixObject `json:"objects"`
+	}
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse stix bundle: %v", err)
+	}
+	if bundle.Type != "bundle" {
+		return nil, fmt.Errorf("not a stix bundle")
+	}
+
+	var entries []*WatchEntry
+	var errs []error
+	add := func(raw, kind string, object *stixObject, verdict string, expires *time.Time) {
+		// ipv4-addr and ipv6-addr values may be CIDR blocks, e.g. '10.0.0.0/8'
+		if kind == indicatorIP && strings.Contains(raw, "/") {
+			kind = indicatorCIDR
+		}
+		value, kind, err := parseWatchIndicator(raw, kind)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", object.Type, err))
+			return
+		}
+		entries = append(entries, &WatchEntry{
+			Type:    kind,
+			Value:   value,
+			Verdict: verdict,
+			Tags:    append(append([]string{}, object.IndicatorTypes...), object.Labels...),
+			Comment: strings.TrimSpace(object.Name + " " + object.Description),
+			Source:  source,
+			Added:   added,
+			Expires: expires,
+		})
+	}
+
+	for i := range bundle.Objects {
+		object := &bundle.Objects[i]
+		switch object.Type {
+		case "indicator":
+			if object.Revoked || (object.PatternType != "" && object.PatternType != "stix") {
+				continue
+			}
+			verdict := verdictMalicious
+			switch {
+			case containsFold(object.IndicatorTypes, "benign"):
+				verdict = verdictClean
+			case containsFold(object.IndicatorTypes, "anomalous-activity"):
+				verdict = verdictSuspicious
+			}
+			var expires *time.Time
+			if t, err := time.Parse(time.RFC3339, object.ValidUntil); err == nil {
+				expires = &t
+			}
+			for _, match := range stixValueRe.FindAllStringSubmatch(object.Pattern, -1) {
+				add(stixUnescaper.Replace(match[2]), stixTypes[match[1]], object, verdict, expires)
+			}
+			for _, match := range stixHashRe.FindAllStringSubmatch(object.Pattern, -1) {
+				add(match[1], indicatorHash, object, verdict, expires)
+			}
+		case "ipv4-addr", "ipv6-addr", "domain-name", "url":
+			add(object.Value, stixTypes[object.Type], object, verdictMalicious, nil)
+		case "file":
+			for _, hash := range object.Hashes {
+				add(hash, indicatorHash, object, verdictMalicious, nil)
+			}
+		}
+	}
+	return entries, errors.Join(errs...)
+}
+
+// registerWatchlistTools adds the watchlist_add, watchlist_remove and watchlist_list tools
+func registerWatchlistTools(s *server.MCPServer, watch *watchlist) {
+	addTool := mcp.NewTool("watchlist_add",
+		mcp.WithDescription("Add an indicator (IP, CIDR, domain, URL or file hash) to the local watchlist. "+
+			"Lookups report watchlist matches alongside threat intelligence results."),
+		mcp.WithString("value",
+			mcp.Required(),
+			mcp.Description("The indicator; defanged forms such as 1.2.3[.]4 are accepted"),
+		),
+		mcp.WithString("type",
+			mcp.Description("The indicator type; detected automatically when omitted"),
+			mcp.Enum(indicatorIP, indicatorCIDR, indicatorDomain, indicatorURL, indicatorHash),
+		),
+		mcp.WithString("verdict",
+			mcp.Description("What a match means; defaults to malicious"),
+			mcp.Enum(verdictMalicious, verdictSuspicious, verdictClean),
+		),
+		mcp.WithArray("tags",
+			mcp.Description("Tags such as the campaign or incident"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithString("comment",
+			mcp.Description("Why the indicator is listed"),
+		),
+		mcp.WithString("expires",
+			mcp.Description("When the entry expires, as a duration such as 72h or a time such as 2025-01-31T00:00:00Z; never by default"),
+		),
+	)
+	s.AddTool(addTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		raw, _ := request.Params.Arguments["value"].(string)
+		kind, _ := request.Params.Arguments["type"].(string)
+		value, kind, err := parseWatchIndicator(raw, kind)
+		if err != nil {
+			return toolError(fmt.Sprintf("invalid indicator: %v", err)), nil
+		}
+		verdictArg, _ := request.Params.Arguments["verdict"].(string)
+		verdict, err := parseVerdict(verdictArg)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		now := time.Now().UTC()
+		expiresArg, _ := request.Params.Arguments["expires"].(string)
+		expires, err := parseExpiry(expiresArg, now)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if expires != nil && !expires.After(now) {
+			return toolError("expires must be in the future"), nil
+		}
+		var tags []string
+		if items, ok := request.Params.Arguments["tags"].([]interface{}); ok {
+			for _, item := range items {
+				if str, ok := item.(string); ok && strings.TrimSpace(str) != "" {
+					tags = append(tags, strings.TrimSpace(str))
+				}
+			}
+		}
+		comment, _ := request.Params.Arguments["comment"].(string)
+
+		entry := &WatchEntry{
+			Type:    kind,
+			Value:   value,
+			Verdict: verdict,
+			Tags:    tags,
+			Comment: strings.TrimSpace(comment),
+			Source:  watchSourceManual,
+			Added:   now,
+			Expires: expires,
+		}
+		if err := watch.add(entry); err != nil {
+			return toolError(err.Error()), nil
+		}
+		return watchResult(entry)
+	}))
+
+	removeTool := mcp.NewTool("watchlist_remove",
+		mcp.WithDescription("Remove an indicator added with watchlist_add from the local watchlist"),
+		mcp.WithString("value",
+			mcp.Required(),
+			mcp.Description("The indicator to remove"),
+		),
+		mcp.WithString("type",
+			mcp.Description("The indicator type; detected automatically when omitted"),
+			mcp.Enum(indicatorIP, indicatorCIDR, indicatorDomain, indicatorURL, indicatorHash),
+		),
+	)
+	s.AddTool(removeTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		raw, _ := request.Params.Arguments["value"].(string)
+		kind, _ := request.Params.Arguments["type"].(string)
+		value, kind, err := parseWatchIndicator(raw, kind)
+		if err != nil {
+			return toolError(fmt.Sprintf("invalid indicator: %v", err)), nil
+		}
+		if err := watch.remove(kind, value); err != nil {
+			return toolError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Removed %s %s from the watchlist", kind, value)), nil
+	}))
+
+	listTool := mcp.NewTool("watchlist_list",
+		mcp.WithDescription("List unexpired watchlist entries, optionally filtered by type, tag or source"),
+		mcp.WithString("type",
+			mcp.Description("Only list entries of this type"),
+			mcp.Enum(indicatorIP, indicatorCIDR, indicatorDomain, indicatorURL, indicatorHash),
+		),
+		mcp.WithString("tag",
+			mcp.Description("Only list entries with this tag"),
+		),
+		mcp.WithString("source",
+			mcp.Description("Only list entries from this file path as given in the config, or \"manual\" for entries added with watchlist_add"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of entries to return, defaults to 100"),
+		),
+	)
+	s.AddTool(listTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		kind, _ := request.Params.Arguments["type"].(string)
+		tag, _ := request.Params.Arguments["tag"].(string)
+		source, _ := request.Params.Arguments["source"].(string)
+		if source != "" && source != watchSourceManual {
+			source = filepath.Clean(source)
+		}
+		limit := 100
+		if value, ok := request.Params.Arguments["limit"].(float64); ok && value > 0 {
+			limit = int(value)
+		}
+
+		entries := watch.list(kind, tag, source)
+		response := struct {
+			Total   int          `json:"total"`
+			Entries []WatchEntry `json:"entries"`
+		}{Total: len(entries), Entries: entries}
+		if len(response.Entries) > limit {
+			response.Entries = response.Entries[:limit]
+		}
+		return watchResult(response)
+	}))
+}
+
+func watchResult(value any) (*mcp.CallToolResult, error) {
+	jsonResult, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format result: %v", err)
+	}
+	return mcp.NewToolResultText(string(jsonResult)), nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadWatchText(t *testing.T) {
+	text := "# exported blocklist\n" +
+		"1.2.3.4\tssh brute force\n" +
+		"evil[.]com  # phishing kit\n" +
+		"10.0.0.0/8\n" +
+		"\n" +
+		"not an indicator\n" +
+		"hxxp://evil.com/a \t dropper\n"
+	entries, err := readWatchText(strings.NewReader(text), "feed.txt", time.Now())
+	if err == nil || !strings.Contains(err.Error(), "line 6") {
+		t.Errorf("err = %v, want an error for line 6", err)
+	}
+
+	want := []WatchEntry{
+		{Type: indicatorIP, Value: "1.2.3.4", Comment: "ssh brute force"},
+		{Type: indicatorDomain, Value: "evil.com", Comment: "phishing kit"},
+		{Type: indicatorCIDR, Value: "10.0.0.0/8"},
+		{Type: indicatorURL, Value: "http://evil.com/a", Comment: "dropper"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.Type != want[i].Type || entry.Value != want[i].Value || entry.Comment != want[i].Comment {
+			t.Errorf("entry %d = %s %q %q, want %s %q %q", i, entry.Type, entry.Value, entry.Comment, want[i].Type, want[i].Value, want[i].Comment)
+		}
+	}
+}
+
+// The indicator column may be named value, indicator or ioc. A malformed
+// row is reported by line and the rows around it are still loaded.
+func TestReadWatchCSV(t *testing.T) {
+	added := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"value", "value,type,tags,verdict,comment,expires"},
+		{"indicator", "Indicator,Type,Tag,Verdict,Description,Expiry"},
+		{"ioc", "ioc,type,labels,verdict,comment,valid_until"},
+	}
+	rows := "\n" +
+		"1.2.3.4,,ssh;brute-force,,scanner,\n" +
+		"evil.com,domain,,suspicious,,72h\n" +
+		"bad\"quote,,,,,\n" +
+		"5.6.7.8,,,harmless,,\n" +
+		"d41d8cd98f00b204e9800998ecf8427e,hash,,clean,,2025-06-30\n"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := readWatchCSV(strings.NewReader(tt.header+rows), "feed.csv", added)
+			if err == nil || !strings.Contains(err.Error(), "line 4") || !strings.Contains(err.Error(), "line 5") {
+				t.Errorf("err = %v, want errors for lines 4 and 5", err)
+			}
+
+			var got []string
+			for _, entry := range entries {
+				got = append(got, fmt.Sprintf("%s %s %s %v %q", entry.Type, entry.Value, entry.Verdict, entry.Tags, entry.Comment))
+			}
+			want := []string{
+				`ip 1.2.3.4 malicious [ssh brute-force] "scanner"`,
+				`domain evil.com suspicious [] ""`,
+				`hash d41d8cd98f00b204e9800998ecf8427e clean [] ""`,
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("entries =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+			if entries[0].Expires != nil {
+				t.Errorf("expires = %v, want none", entries[0].Expires)
+			}
+			if want := added.Add(72 * time.Hour); entries[1].Expires == nil || !entries[1].Expires.Equal(want) {
+				t.Errorf("expires = %v, want %v", entries[1].Expires, want)
+			}
+			if want := time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC); entries[2].Expires == nil || !entries[2].Expires.Equal(want) {
+				t.Errorf("expires = %v, want %v", entries[2].Expires, want)
+			}
+		})
+	}
+
+	if _, err := readWatchCSV(strings.NewReader("address,type\n1.2.3.4,ip\n"), "feed.csv", added); err == nil {
+		t.Error("readWatchCSV accepted a header without an indicator column")
+	}
+}
+
+const watchBundle = `{
+  "type": "bundle",
+  "id": "bundle--1",
+  "objects": [
+    {"type": "indicator", "name": "C2", "indicator_types": ["malicious-activity"], "pattern_type": "stix",
+     "pattern": "[ipv4-addr:value = '198.51.100.0/24'] OR [domain-name:value = 'c2.evil.com']"},
+    {"type": "indicator", "indicator_types": ["anomalous-activity"], "pattern_type": "stix",
+     "pattern": "[file:hashes.'SHA-256' = 'E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855']",
+     "valid_until": "2000-01-01T00:00:00Z"},
+    {"type": "indicator", "revoked": true, "pattern_type": "stix", "pattern": "[ipv4-addr:value = '9.9.9.9']"},
+    {"type": "indicator", "pattern_type": "sigma", "pattern": "title: x"},
+    {"type": "url", "value": "https://evil.com/login"},
+    {"type": "file", "hashes": {"MD5": "d41d8cd98f00b204e9800998ecf8427e"}}
+  ]
+}`
+
+func TestReadWatchSTIX(t *testing.T) {
+	entries, err := readWatchSTIX(strings.NewReader(watchBundle), "feed.json", time.Now())
+	if err != nil {
+		t.Fatalf("readWatchSTIX: %v", err)
+	}
+
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Type+" "+entry.Value+" "+entry.Verdict)
+	}
+	want := []string{
+		"cidr 198.51.100.0/24 malicious",
+		"domain c2.evil.com malicious",
+		"hash e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 suspicious",
+		"url https://evil.com/login malicious",
+		"hash d41d8cd98f00b204e9800998ecf8427e malicious",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("entries =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if entries[2].Expires == nil {
+		t.Error("valid_until was not read as the expiry")
+	}
+
+	if _, err := readWatchSTIX(strings.NewReader(`{"type": "indicator"}`), "x.json", time.Now()); err == nil {
+		t.Error("readWatchSTIX accepted a non-bundle")
+	}
+}
+
+func TestWatchlistMatch(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "feed.txt")
+	text := "10.0.0.0/8 internal scanner range\n2001:db8::/32\nevil.com\n1.2.3.4\n"
+	if err := os.WriteFile(file, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := loadWatchlist(&WatchlistConfig{Files: []string{file}})
+	if err != nil {
+		t.Fatalf("loadWatchlist: %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := w.add(&WatchEntry{Type: indicatorDomain, Value: "old.net", Verdict: verdictMalicious, Source: watchSourceManual, Expires: &past}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	tests := []struct {
+		kind  string
+		value string
+		want  []string // Matching entry values
+	}{
+		{indicatorIP, "10.1.2.3", []string{"10.0.0.0/8"}},
+		{indicatorIP, "::ffff:10.1.2.3", []string{"10.0.0.0/8"}},
+		{indicatorIP, "11.0.0.1", nil},
+		{indicatorIP, "2001:db8::1", []string{"2001:db8::/32"}},
+		{indicatorIP, "1.2.3.4", []string{"1.2.3.4"}},
+		{indicatorDomain, "evil.com", []string{"evil.com"}},
+		{indicatorDomain, "a.b.evil.com", []string{"evil.com"}},
+		{indicatorDomain, "notevil.com", nil},
+		{indicatorDomain, "old.net", nil},
+		{indicatorURL, "https://cdn.evil.com/x", []string{"evil.com"}},
+		{indicatorURL, "http://10.9.9.9:8080/", []string{"10.0.0.0/8"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, entry := range w.match(tt.kind, tt.value) {
+			got = append(got, entry.Value)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("match(%s, %s) = %v, want %v", tt.kind, tt.value, got, tt.want)
+		}
+	}
+}
+
+// A change that cannot be saved is not applied
+func TestWatchlistSaveFailure(t *testing.T) {
+	dir := t.TempDir()
+	w, err := loadWatchlist(&WatchlistConfig{Path: filepath.Join(dir, "watchlist.json")})
+	if err != nil {
+		t.Fatalf("loadWatchlist: %v", err)
+	}
+	if err := w.add(&WatchEntry{Type: indicatorCIDR, Value: "10.0.0.0/8", Verdict: verdictMalicious, Source: watchSourceManual}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	// Saving fails once the directory is gone
+	w.path = filepath.Join(dir, "missing", "watchlist.json")
+	if err := w.add(&WatchEntry{Type: indicatorDomain, Value: "evil.com", Verdict: verdictMalicious, Source: watchSourceManual}); err == nil {
+		t.Fatal("add succeeded without saving")
+	}
+	if matches := w.match(indicatorDomain, "evil.com"); len(matches) != 0 {
+		t.Errorf("unsaved entry is active: %v", matches)
+	}
+	if err := w.add(&WatchEntry{Type: indicatorCIDR, Value: "10.0.0.0/8", Verdict: verdictSuspicious, Source: watchSourceManual}); err == nil {
+		t.Fatal("add succeeded without saving")
+	}
+	if matches := w.match(indicatorIP, "10.1.2.3"); len(matches) != 1 || matches[0].Verdict != verdictMalicious {
+		t.Errorf("replaced entry = %v, want the saved malicious entry", matches)
+	}
+	if err := w.remove(indicatorCIDR, "10.0.0.0/8"); err == nil {
+		t.Fatal("remove succeeded without saving")
+	}
+	if matches := w.match(indicatorIP, "10.1.2.3"); len(matches) != 1 {
+		t.Errorf("unsaved removal took effect: %v", matches)
+	}
+}
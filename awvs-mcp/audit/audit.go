@@ -0,0 +1,461 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// 调用结果
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// 脱敏后的参数值
+const redacted = "[REDACTED]"
+
+// 参数名包含这些关键字时不记录原值
+var sensitiveKeys = []string{"cookie", "header", "password", "secret", "token", "key", "authorization"}
+
+// 结果和参数中表示AWVS资源ID的字段及对应的资源类型
+var idFields = map[string]string{
+	"target_id":  "target",
+	"target_ids": "target",
+	"scan_id":    "scan",
+	"scan_ids":   "scan",
+	"vuln_id":    "vuln",
+	"vuln_ids":   "vuln",
+	"report_id":  "report",
+}
+
+// Entry 表示一条审计记录
+type Entry struct {
+	Seq        uint64                 `json:"seq"`
+	Time       time.Time              `json:"time"`
+	User       string                 `json:"user,omitempty"`    // 运行服务的系统用户
+	Session    string                 `json:"session,omitempty"` // MCP会话ID
+	Client     string                 `json:"client,omitempty"`  // 客户端名称和版本，来自initialize请求
+	Tool       string                 `json:"tool"`
+	Arguments  map[string]interface{} `json:"arguments,omitempty"` // 脱敏后的参数
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+	Affected   []string               `json:"affected,omitempty"`  // 涉及的AWVS资源，如 target:<id>
+	PrevHash   string                 `json:"prev_hash,omitempty"` // 开启哈希链时为上一条记录的哈希
+	ChainEnd   bool                   `json:"chain_end,omitempty"` // 关闭哈希链时写入的最后一条带哈希的记录，之后的记录不再带哈希
+}
+
+// Logger 以JSON Lines格式追加写入审计日志
+type Logger struct {
+	mu        sync.Mutex
+	file      *os.File
+	hashChain bool
+	seq       uint64
+	lastHash  string
+	user      string
+	clients   sync.Map // 会话ID -> 客户端名称
+}
+
+// 全局审计日志，未调用Setup时不记录
+var defaultLogger *Logger
+
+// Setup 打开审计日志并启用全局记录，返回的函数用于关闭日志文件
+func Setup(path string, hashChain bool) (func() error, error) {
+	logger, err := Open(path, hashChain)
+	if err != nil {
+		return nil, err
+	}
+	defaultLogger = logger
+	return logger.Close, nil
+}
+
+// Open 以追加方式打开审计日志，开启哈希链时从最后一条记录继续链接
+// 已有日志带哈希链而本次关闭了哈希链时，先写入一条带哈希的结束记录，校验时据此区分关闭哈希链和删除了hash字段
+func Open(path string, hashChain bool) (*Logger, error) {
+	seq, lastHash, err := tail(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log failed: %w", err)
+	}
+
+	logger := &Logger{
+		file:      file,
+		hashChain: hashChain,
+		seq:       seq,
+		lastHash:  lastHash,
+	}
+	if u, err := user.Current(); err == nil {
+		logger.user = u.Username
+	}
+
+	if !hashChain && lastHash != "" {
+		logger.hashChain = true
+		err := logger.Write(&Entry{
+			Time:      time.Now().UTC(),
+			Tool:      "audit",
+			Arguments: map[string]interface{}{"hash_chain": false},
+			Status:    StatusOK,
+			ChainEnd:  true,
+		})
+		logger.hashChain = false
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return logger, nil
+}
+
+// Close 关闭审计日志
+func (l *Logger) Close() error {
+	return l.file.Close()
+}
+
+// Write 追加一条审计记录，自动填充序号和哈希链
+func (l *Logger) Write(entry *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Seq = l.seq + 1
+	if entry.User == "" {
+		entry.User = l.user
+	}
+	if l.hashChain {
+		entry.PrevHash = l.lastHash
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry failed: %w", err)
+	}
+
+	// 哈希覆盖不含hash字段的完整记录，追加在记录末尾便于逐行校验
+	var hash string
+	if l.hashChain {
+		hash = hashEntry(data)
+		data = append(data[:len(data)-1], fmt.Sprintf(`,"hash":%q}`, hash)...)
+	}
+	data = append(data, '\n')
+
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("write audit log failed: %w", err)
+	}
+	l.seq = entry.Seq
+	l.lastHash = hash
+	return nil
+}
+
+// TrackClients 通过initialize钩子记录每个会话的客户端名称和版本
+func (l *Logger) TrackClients(hooks *server.Hooks) {
+	hooks.AddAfterInitialize(func(ctx context.Context, id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+		info := message.Params.ClientInfo
+		l.clients.Store(sessionID(ctx), strings.TrimSpace(info.Name+" "+info.Version))
+	})
+}
+
+// TrackClients 在全局审计日志中记录客户端信息，未启用审计时不做任何事
+func TrackClients(hooks *server.Hooks) {
+	if defaultLogger != nil {
+		defaultLogger.TrackClients(hooks)
+	}
+}
+
+// WrapTool 使用全局审计日志记录工具调用，未启用审计时直接返回原处理函数
+func WrapTool(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if defaultLogger == nil {
+		return handler
+	}
+	return defaultLogger.WrapTool(handler)
+}
+
+// WrapTool 记录每次工具调用的参数、结果和涉及的资源
+func (l *Logger) WrapTool(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		affected := &affectedSet{}
+		ctx = context.WithValue(ctx, affectedKey{}, affected)
+
+		start := time.Now()
+		result, err := handler(ctx, request)
+
+		session := sessionID(ctx)
+		entry := &Entry{
+			Time:       start.UTC(),
+			Session:    session,
+			Tool:       request.Params.Name,
+			Arguments:  sanitize(request.Params.Arguments),
+			Status:     StatusOK,
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		if client, ok := l.clients.Load(session); ok {
+			entry.Client = client.(string)
+		}
+
+		collectIDs(request.Params.Arguments, affected)
+		switch {
+		case err != nil:
+			entry.Status = StatusError
+			entry.Error = err.Error()
+		case result != nil:
+			if result.IsError {
+				entry.Status = StatusError
+			}
+			for _, content := range result.Content {
+				text, ok := content.(mcp.TextContent)
+				if !ok {
+					continue
+				}
+				if result.IsError && entry.Error == "" {
+					entry.Error = text.Text
+				}
+				var parsed interface{}
+				if json.Unmarshal([]byte(text.Text), &parsed) == nil {
+					collectIDs(parsed, affected)
+				}
+			}
+		}
+		entry.Affected = affected.list()
+
+		if writeErr := l.Write(entry); writeErr != nil {
+			log.Printf("写入审计日志失败: %v", writeErr)
+		}
+		return result, err
+	}
+}
+
+// Affect 在当前工具调用的审计记录中登记涉及的资源，用于结果中不包含ID的操作（如批量删除）
+func Affect(ctx context.Context, kind string, ids ...string) {
+	affected, ok := ctx.Value(affectedKey{}).(*affectedSet)
+	if !ok {
+		return
+	}
+	for _, id := range ids {
+		affected.add(kind + ":" + id)
+	}
+}
+
+type affectedKey struct{}
+
+// affectedSet 按登记顺序去重保存涉及的资源
+type affectedSet struct {
+	mu    sync.Mutex
+	seen  map[string]bool
+	items []string
+}
+
+func (s *affectedSet) add(item string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	if !s.seen[item] {
+		s.seen[item] = true
+		s.items = append(s.items, item)
+	}
+}
+
+func (s *affectedSet) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.items
+}
+
+// sessionID 返回当前请求所属的MCP会话ID
+func sessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// sanitize 复制参数并替换敏感字段的值
+func sanitize(args map[string]interface{}) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	clean := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if isSensitive(k) {
+			clean[k] = redacted
+			continue
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			clean[k] = sanitize(nested)
+			continue
+		}
+		clean[k] = v
+	}
+	return clean
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectIDs 查找参数或结果顶层的资源ID字段
+// 只看顶层字段，列表类结果中的ID属于读取而非操作对象，不计入涉及的资源
+func collectIDs(v interface{}, affected *affectedSet) {
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if _, ok := idFields[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kind := idFields[k]
+		switch id := fields[k].(type) {
+		case string:
+			if id != "" {
+				affected.add(kind + ":" + id)
+			}
+		case []interface{}:
+			for _, each := range id {
+				if s, ok := each.(string); ok && s != "" {
+					affected.add(kind + ":" + s)
+				}
+			}
+		}
+	}
+}
+
+// hashEntry 计算记录的SHA-256哈希
+func hashEntry(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// chainedEntry 用于校验时读取链接字段
+type chainedEntry struct {
+	Seq      uint64 `json:"seq"`
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+	ChainEnd bool   `json:"chain_end"`
+}
+
+// splitHash 拆出记录末尾的hash字段，返回参与哈希计算的原始内容
+func splitHash(line []byte) ([]byte, string, bool) {
+	const suffixLen = len(`,"hash":""}`) + sha256.Size*2
+	if len(line) < suffixLen || !bytes.HasPrefix(line[len(line)-suffixLen:], []byte(`,"hash":"`)) {
+		return nil, "", false
+	}
+	hash := string(line[len(line)-suffixLen+len(`,"hash":"`) : len(line)-2])
+	body := append(append([]byte{}, line[:len(line)-suffixLen]...), '}')
+	return body, hash, true
+}
+
+// tail 读取已有日志的最后序号和哈希，文件不存在时从头开始
+func tail(path string) (uint64, string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("open audit log failed: %w", err)
+	}
+	defer file.Close()
+
+	var last chainedEntry
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			last = chainedEntry{}
+			if jsonErr := json.Unmarshal(line, &last); jsonErr != nil {
+				return 0, "", fmt.Errorf("parse audit log failed: %w", jsonErr)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("read audit log failed: %w", err)
+		}
+	}
+	// 上一次关闭了哈希链时，重新开启后从头开始新的哈希链
+	if last.ChainEnd {
+		return last.Seq, "", nil
+	}
+	return last.Seq, last.Hash, nil
+}
+
+// Verify 校验审计日志的哈希链，返回校验通过的记录数
+// 开启哈希链之前写入的记录和关闭哈希链（chain_end记录）之后写入的记录没有hash字段，
+// 哈希链中间出现不带哈希的记录视为被删除了hash字段；重新开启哈希链后从头开始新的链。
+// requireChain为true时每条记录都必须带哈希
+func Verify(r io.Reader, requireChain bool) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	count := 0
+	prevHash := ""
+	chained := false // 是否处于哈希链中，此时下一条记录必须带哈希并链接上一条
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var entry chainedEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return count, fmt.Errorf("line %d: invalid json: %w", line, err)
+		}
+		if entry.Hash == "" {
+			if requireChain || chained {
+				return count, fmt.Errorf("line %d (seq %d): entry is not chained", line, entry.Seq)
+			}
+			count++
+			continue
+		}
+
+		body, hash, ok := splitHash(data)
+		if !ok || hash != entry.Hash {
+			return count, fmt.Errorf("line %d (seq %d): malformed hash field", line, entry.Seq)
+		}
+		if !chained {
+			prevHash = ""
+		}
+		if entry.PrevHash != prevHash {
+			return count, fmt.Errorf("line %d (seq %d): chain broken, prev_hash does not match previous entry", line, entry.Seq)
+		}
+		if hashEntry(body) != hash {
+			return count, fmt.Errorf("line %d (seq %d): hash mismatch, entry was modified", line, entry.Seq)
+		}
+		prevHash = hash
+		chained = !entry.ChainEnd
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("read audit log failed: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeEntries 以指定的哈希链设置打开日志并写入n条记录
+func writeEntries(t *testing.T, path string, hashChain bool, n int) {
+	t.Helper()
+	logger, err := Open(path, hashChain)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer logger.Close()
+	for i := 0; i < n; i++ {
+		if err := logger.Write(&Entry{Time: time.Now().UTC(), Tool: "list_targets", Status: StatusOK}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+}
+
+func verifyFile(t *testing.T, path string, requireChain bool) (int, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return Verify(f, requireChain)
+}
+
+func TestVerifyHashChainToggle(t *testing.T) {
+	tests := []struct {
+		name   string
+		phases []bool // 每次打开日志时的hash_chain设置
+		count  int    // 期望校验通过的记录数（含chain_end记录）
+		strict bool   // -require-chain时是否应通过
+	}{
+		{name: "always chained", phases: []bool{true, true}, count: 4, strict: true},
+		{name: "enabled later", phases: []bool{false, true}, count: 4},
+		{name: "disabled later", phases: []bool{true, false}, count: 5},
+		{name: "disabled then enabled", phases: []bool{true, false, true}, count: 7},
+		{name: "never chained", phases: []bool{false, false}, count: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			for _, hashChain := range tt.phases {
+				writeEntries(t, path, hashChain, 2)
+			}
+
+			count, err := verifyFile(t, path, false)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if count != tt.count {
+				t.Errorf("Verify count = %d, want %d", count, tt.count)
+			}
+
+			_, err = verifyFile(t, path, true)
+			if tt.strict && err != nil {
+				t.Errorf("Verify with requireChain: %v", err)
+			}
+			if !tt.strict && err == nil {
+				t.Errorf("Verify with requireChain succeeded, want unchained entry error")
+			}
+		})
+	}
+}
+
+// 哈希链中间删除hash字段的记录应校验失败
+func TestVerifyStrippedHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeEntries(t, path, true, 3)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	body, _, ok := splitHash(lines[1])
+	if !ok {
+		t.Fatalf("line 2 has no hash field")
+	}
+	lines[1] = bytes.Replace(body, []byte(`"list_targets"`), []byte(`"delete_all"`), 1)
+	if err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = verifyFile(t, path, false)
+	if err == nil || !strings.Contains(err.Error(), "not chained") {
+		t.Fatalf("Verify error = %v, want not chained", err)
+	}
+}
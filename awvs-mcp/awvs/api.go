@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 )
 
 // 扫描类型常量
@@ -54,14 +55,42 @@ type Scan struct {
 	Status    string `json:"status"`
 	Progress  int    `json:"progress"`
 	Severity  Severity `json:"severity"`
+	CurrentSession ScanSession `json:"current_session"`
+	Target    Target   `json:"target"`
+}
+
+// ScanSession 表示扫描任务的当前会话，AWVS列表接口将状态和漏洞统计放在其中
+type ScanSession struct {
+	ScanSessionID  string   `json:"scan_session_id"`
+	Status         string   `json:"status"`
+	Progress       int      `json:"progress"`
+	StartDate      string   `json:"start_date"`
+	SeverityCounts Severity `json:"severity_counts"`
+}
+
+// State 返回扫描任务的当前状态
+func (s Scan) State() string {
+	if s.CurrentSession.Status != "" {
+		return s.CurrentSession.Status
+	}
+	return s.Status
+}
+
+// Counts 返回扫描任务当前的漏洞统计
+func (s Scan) Counts() Severity {
+	if s.CurrentSession.SeverityCounts != (Severity{}) {
+		return s.CurrentSession.SeverityCounts
+	}
+	return s.Severity
 }
 
 // Severity 表示漏洞严重性
 type Severity struct {
-	High    int `json:"high"`
-	Medium  int `json:"medium"`
-	Low     int `json:"low"`
-	Info    int `json:"info"`
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+	Info     int `json:"info"`
 }
 
 // 请求和响应的结构体
@@ -106,6 +135,10 @@ type scansResponse struct {
 
 // AddTarget 添加目标到AWVS
 func (c *Client) AddTarget(url string, cookies string, headers map[string]string) (*Target, error) {
+	if err := c.config.Scope.Check(url); err != nil {
+		return nil, err
+	}
+	
 	// 构建请求体
 	req := addTargetRequest{
 		Address:     url,
@@ -136,12 +169,42 @@ func (c *Client) AddTarget(url string, cookies string, headers map[string]string
 	if err := json.Unmarshal(respBytes, &resp); err != nil {
 		return nil, fmt.Errorf("unmarshal add target response failed: %w", err)
 	}
+	c.observeTargets(resp.Target)
 	
 	return &resp.Target, nil
 }
 
-// StartScan 开始扫描目标
+// StartScan 对已有目标开始扫描，启动前检查扫描范围和许可证并发扫描额度
 func (c *Client) StartScan(targetID, scanType string) (*Scan, error) {
+	if _, ok := scanProfileMap[scanType]; !ok {
+		return nil, fmt.Errorf("invalid scan type: %s", scanType)
+	}
+	
+	// 配置了扫描范围时按目标地址检查，已存在于AWVS中的目标也不能越界扫描
+	if c.config.Scope != nil {
+		target, err := c.GetTarget(targetID)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.config.Scope.Check(target.Address); err != nil {
+			return nil, err
+		}
+	}
+	
+	// 无法获取额度时（如旧版本或权限不足）不做检查
+	quota := c.quotaOrNil()
+	if quota != nil {
+		if err := quota.CheckScan(); err != nil {
+			return nil, err
+		}
+	}
+	
+	scan, err := c.startScan(targetID, scanType)
+	return scan, quotaError(err, quota)
+}
+
+// startScan 发送开始扫描请求，调用方负责范围和额度检查
+func (c *Client) startScan(targetID, scanType string) (*Scan, error) {
 	// 记录传入的targetID
 	log.Printf("StartScan接收到的targetID: %s", targetID)
 	
@@ -179,14 +242,22 @@ func (c *Client) StartScan(targetID, scanType string) (*Scan, error) {
 	if err := json.Unmarshal(respBytes, &resp); err != nil {
 		return nil, fmt.Errorf("unmarshal start scan response failed: %w", err)
 	}
+	c.observeScans(resp.Scan)
 	
 	return &resp.Scan, nil
 }
 
-// AddAndScan 添加目标并开始扫描
+// AddAndScan 开始扫描URL，AWVS中已有相同地址的目标时沿用该目标，否则添加新目标
 func (c *Client) AddAndScan(url string, scanType string, cookies string, headers map[string]string) (*Scan, *Target, error) {
+	if err := c.config.Scope.Check(url); err != nil {
+		return nil, nil, err
+	}
+	
+	// 先检查许可证额度，无法获取额度时（如旧版本或权限不足）不做检查
+	quota := c.quotaOrNil()
+
 	// 首先尝试查找是否已经存在该URL的目标
-	targets, err := c.ListTargets()
+	targets, err := c.ListAllTargets("")
 	if err == nil && len(targets) > 0 {
 		// 查找匹配的目标
 		var existingTarget *Target
@@ -201,29 +272,74 @@ func (c *Client) AddAndScan(url string, scanType string, cookies string, headers
 		// 如果找到匹配的目标，直接使用它
 		if existingTarget != nil {
 			log.Printf("使用已存在的目标: %s 开始扫描", existingTarget.TargetID)
-			scan, err := c.StartScan(existingTarget.TargetID, scanType)
-			return scan, existingTarget, err
+			if quota != nil {
+				if err := quota.CheckScan(); err != nil {
+					return nil, existingTarget, err
+				}
+			}
+			scan, err := c.startScan(existingTarget.TargetID, scanType)
+			return scan, existingTarget, quotaError(err, quota)
 		}
 	}
-	
+
 	// 如果没有找到匹配的目标，添加新目标
+	return c.addTargetAndScan(url, scanType, cookies, headers, quota)
+}
+
+// AddTargetAndScan 总是添加新目标并开始扫描，添加前检查目标数和并发扫描额度，
+// 避免额度不足时留下未扫描的目标
+func (c *Client) AddTargetAndScan(url string, scanType string, cookies string, headers map[string]string) (*Scan, *Target, error) {
+	if err := c.config.Scope.Check(url); err != nil {
+		return nil, nil, err
+	}
+	return c.addTargetAndScan(url, scanType, cookies, headers, c.quotaOrNil())
+}
+
+// addTargetAndScan 检查额度后添加新目标并开始扫描，quota为nil时不做检查
+func (c *Client) addTargetAndScan(url string, scanType string, cookies string, headers map[string]string, quota *Quota) (*Scan, *Target, error) {
+	// 添加新目标前确认目标数和并发扫描数都未达上限，避免添加目标后无法扫描
+	if quota != nil {
+		if err := quota.CheckTarget(); err != nil {
+			return nil, nil, err
+		}
+		if err := quota.CheckScan(); err != nil {
+			return nil, nil, err
+		}
+	}
+	
 	target, err := c.AddTarget(url, cookies, headers)
 	if err != nil {
-		return nil, nil, fmt.Errorf("add target failed: %w", err)
+		return nil, nil, quotaError(fmt.Errorf("add target failed: %w", err), quota)
 	}
 	
 	log.Printf("成功添加新目标: ID=%s, URL=%s", target.TargetID, target.Address)
 	
-	// 开始扫描
-	scan, err := c.StartScan(target.TargetID, scanType)
+	// 开始扫描，范围和额度已在前面检查过
+	scan, err := c.startScan(target.TargetID, scanType)
 	if err != nil {
-		return nil, target, fmt.Errorf("start scan failed: %w", err)
+		return nil, target, quotaError(fmt.Errorf("start scan failed: %w", err), quota)
 	}
 	
 	return scan, target, nil
 }
 
-// ListTargets 获取所有目标
+// GetTarget 获取指定目标
+func (c *Client) GetTarget(targetID string) (*Target, error) {
+	respBytes, err := c.get("/targets/" + url.PathEscape(targetID))
+	if err != nil {
+		return nil, fmt.Errorf("get target failed: %w", err)
+	}
+	
+	var target Target
+	if err := json.Unmarshal(respBytes, &target); err != nil {
+		return nil, fmt.Errorf("unmarshal target response failed: %w", err)
+	}
+	c.observeTargets(target)
+	
+	return &target, nil
+}
+
+// ListTargets 获取第一页目标，需要全部目标时使用ListAllTargets
 func (c *Client) ListTargets() ([]Target, error) {
 	respBytes, err := c.get("/targets")
 	if err != nil {
@@ -234,11 +350,12 @@ func (c *Client) ListTargets() ([]Target, error) {
 	if err := json.Unmarshal(respBytes, &resp); err != nil {
 		return nil, fmt.Errorf("unmarshal targets response failed: %w", err)
 	}
+	c.observeTargets(resp.Targets...)
 	
 	return resp.Targets, nil
 }
 
-// ListScans 获取所有扫描任务
+// ListScans 获取第一页扫描任务，需要全部扫描任务时使用ListAllScans
 func (c *Client) ListScans() ([]Scan, error) {
 	respBytes, err := c.get("/scans")
 	if err != nil {
@@ -249,58 +366,65 @@ func (c *Client) ListScans() ([]Scan, error) {
 	if err := json.Unmarshal(respBytes, &resp); err != nil {
 		return nil, fmt.Errorf("unmarshal scans response failed: %w", err)
 	}
+	c.observeScans(resp.Scans...)
 	
 	return resp.Scans, nil
 }
 
 // DeleteTarget 删除指定目标
 func (c *Client) DeleteTarget(targetID string) error {
-	_, err := c.delete(fmt.Sprintf("/targets/%s", targetID))
+	_, err := c.delete("/targets/" + url.PathEscape(targetID))
 	if err != nil {
 		return fmt.Errorf("delete target failed: %w", err)
 	}
+	c.observeTargetsDeleted(targetID)
 	
 	return nil
 }
 
 // DeleteScan 删除指定扫描任务
 func (c *Client) DeleteScan(scanID string) error {
-	_, err := c.delete(fmt.Sprintf("/scans/%s", scanID))
+	_, err := c.delete("/scans/" + url.PathEscape(scanID))
 	if err != nil {
 		return fmt.Errorf("delete scan failed: %w", err)
 	}
+	c.observeScansDeleted(scanID)
 	
 	return nil
 }
 
-// DeleteAllTargets 删除所有目标
-func (c *Client) DeleteAllTargets() error {
-	targets, err := c.ListTargets()
+// DeleteAllTargets 删除所有目标，返回已删除的目标ID（出错时为出错前已删除的部分）
+func (c *Client) DeleteAllTargets() ([]string, error) {
+	targets, err := c.ListAllTargets("")
 	if err != nil {
-		return fmt.Errorf("list targets failed: %w", err)
+		return nil, err
 	}
 	
+	deleted := make([]string, 0, len(targets))
 	for _, target := range targets {
 		if err := c.DeleteTarget(target.TargetID); err != nil {
-			return fmt.Errorf("delete target %s failed: %w", target.TargetID, err)
+			return deleted, fmt.Errorf("delete target %s failed: %w", target.TargetID, err)
 		}
+		deleted = append(deleted, target.TargetID)
 	}
 	
-	return nil
+	return deleted, nil
 }
 
-// DeleteAllScans 删除所有扫描任务
-func (c *Client) DeleteAllScans() error {
-	scans, err := c.ListScans()
+// DeleteAllScans 删除所有扫描任务，返回已删除的扫描ID（出错时为出错前已删除的部分）
+func (c *Client) DeleteAllScans() ([]string, error) {
+	scans, err := c.ListAllScans("")
 	if err != nil {
-		return fmt.Errorf("list scans failed: %w", err)
+		return nil, err
 	}
 	
+	deleted := make([]string, 0, len(scans))
 	for _, scan := range scans {
 		if err := c.DeleteScan(scan.ScanID); err != nil {
-			return fmt.Errorf("delete scan %s failed: %w", scan.ScanID, err)
+			return deleted, fmt.Errorf("delete scan %s failed: %w", scan.ScanID, err)
 		}
+		deleted = append(deleted, scan.ScanID)
 	}
 	
-	return nil
+	return deleted, nil
 }
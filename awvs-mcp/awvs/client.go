@@ -2,43 +2,80 @@ package awvs
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"time"
+
+	"github.com/taoing/awvs-mcp/metrics"
+	"github.com/taoing/awvs-mcp/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Config AWVS配置
 type Config struct {
+	Name      string // 实例名称，用于指标标签
 	APIURL    string
 	APIKey    string
 	VerifySSL bool
+
+	CAFile          string        // 自定义CA证书文件（PEM）
+	CertFingerprint string        // 固定的服务端证书SHA-256指纹
+	ClientCert      string        // mTLS客户端证书文件
+	ClientKey       string        // mTLS客户端私钥文件
+	Proxy           string        // 出站代理，支持http、https、socks5
+	Timeout         time.Duration // 单次请求超时
+	ConnectTimeout  time.Duration // 建立连接和TLS握手超时
+
+	Scope *Scope // 允许添加和扫描的目标范围，为nil时不限制
 }
 
 // Client AWVS API客户端
 type Client struct {
-	config  *Config
-	httpCli *http.Client
+	config   *Config
+	httpCli  *http.Client
+	observer Observer
+	ctx      context.Context
+
+	activeScans *int // LeastBusy已统计的扫描数量，为nil时按需统计
+}
+
+// APIError 表示AWVS接口返回的非2xx响应
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
 }
 
 // NewClient 创建一个新的AWVS客户端
-func NewClient(config *Config) *Client {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: !config.VerifySSL},
+func NewClient(config *Config) (*Client, error) {
+	tr, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
 	}
 
 	httpCli := &http.Client{
 		Transport: tr,
-		Timeout:   30 * time.Second,
+		Timeout:   timeout,
 	}
 
 	return &Client{
 		config:  config,
 		httpCli: httpCli,
-	}
+	}, nil
 }
 
 // request 执行HTTP请求
@@ -56,7 +93,19 @@ func (c *Client) request(method, path string, body interface{}) ([]byte, error)
 		bodyReader = bytes.NewReader(bodyBytes)
 	}
 
-	req, err := http.NewRequest(method, url, bodyReader)
+	// AWVS请求失败时不重试，span不记录http.request.resend_count
+	endpoint := metrics.Endpoint(path)
+	ctx, span := tracing.Tracer().Start(c.context(), method+" "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("awvs.instance", c.config.Name),
+			attribute.String("http.request.method", method),
+			attribute.String("url.path", endpoint),
+		),
+	)
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("create request failed: %w", err)
 	}
@@ -66,10 +115,16 @@ func (c *Client) request(method, path string, body interface{}) ([]byte, error)
 
 	log.Printf("请求方法：%s，请求地址：%s，请求头：%v，请求体：%v\n", method, url, req.Header, body)
 
+	start := time.Now()
 	resp, err := c.httpCli.Do(req)
 	if err != nil {
+		metrics.ObserveUpstream(c.config.Name, path, 0, time.Since(start))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("execute request failed: %w", err)
 	}
+	metrics.ObserveUpstream(c.config.Name, path, resp.StatusCode, time.Since(start))
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
@@ -80,12 +135,28 @@ func (c *Client) request(method, path string, body interface{}) ([]byte, error)
 	log.Printf("响应状态码：%d，响应头：%v，响应体：%s\n", resp.StatusCode, resp.Header, string(respBody))
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	return respBody, nil
 }
 
+// WithContext 返回使用ctx发起请求的客户端副本，请求会作为ctx中span的子span记录
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// context 返回发起请求使用的上下文
+func (c *Client) context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
 // get 执行GET请求
 func (c *Client) get(path string) ([]byte, error) {
 	return c.request(http.MethodGet, path, nil)
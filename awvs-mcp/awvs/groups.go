@@ -0,0 +1,44 @@
+package awvs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// TargetGroup 表示AWVS目标组
+type TargetGroup struct {
+	GroupID     string `json:"group_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	TargetCount int    `json:"target_count"`
+}
+
+// ListAllTargetGroups 逐页获取全部目标组
+func (c *Client) ListAllTargetGroups() ([]TargetGroup, error) {
+	var groups []TargetGroup
+	err := walkPages(c, "/target_groups", "groups", "", func(page []TargetGroup) error {
+		groups = append(groups, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list target groups failed: %w", err)
+	}
+	return groups, nil
+}
+
+// GroupTargetIDs 获取目标组中全部目标的ID
+func (c *Client) GroupTargetIDs(groupID string) ([]string, error) {
+	respBytes, err := c.get("/target_groups/" + url.PathEscape(groupID) + "/targets")
+	if err != nil {
+		return nil, fmt.Errorf("list group targets failed: %w", err)
+	}
+
+	var resp struct {
+		TargetIDList []string `json:"target_id_list"`
+	}
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal group targets response failed: %w", err)
+	}
+	return resp.TargetIDList, nil
+}
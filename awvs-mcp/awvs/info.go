@@ -0,0 +1,223 @@
+package awvs
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// flexString 兼容AWVS不同版本中以数字或字符串返回的字段
+type flexString string
+
+func (f *flexString) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*f = flexString(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*f = flexString(n.String())
+	return nil
+}
+
+// Info 表示AWVS产品信息
+type Info struct {
+	MajorVersion flexString `json:"major_version"`
+	MinorVersion flexString `json:"minor_version"`
+	BuildNumber  flexString `json:"build_number"`
+	License      License    `json:"license"`
+}
+
+// Version 返回可读的版本号
+func (i Info) Version() string {
+	version := string(i.MajorVersion)
+	if i.MinorVersion != "" {
+		version += "." + string(i.MinorVersion)
+	}
+	if i.BuildNumber != "" {
+		version += " build " + string(i.BuildNumber)
+	}
+	return version
+}
+
+// License 表示AWVS许可证信息，数量限制为0表示不限制
+type License struct {
+	ProductCode        string `json:"product_code"`
+	Expired            bool   `json:"expired"`
+	Expires            string `json:"expires"`
+	MaxTargets         int    `json:"max_targets"`
+	MaxConcurrentScans int    `json:"max_concurrent_scans"`
+	MaxEngines         int    `json:"max_engines"`
+}
+
+// Worker 表示AWVS扫描引擎
+type Worker struct {
+	WorkerID    string `json:"worker_id"`
+	Endpoint    string `json:"endpoint"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+	AppVersion  string `json:"app_version"`
+}
+
+// GetInfo 获取AWVS版本和许可证信息
+func (c *Client) GetInfo() (*Info, error) {
+	respBytes, err := c.get("/info")
+	if err != nil {
+		return nil, err
+	}
+
+	var info Info
+	if err := json.Unmarshal(respBytes, &info); err != nil {
+		return nil, fmt.Errorf("unmarshal info failed: %w", err)
+	}
+	return &info, nil
+}
+
+// ListWorkers 获取已注册的扫描引擎
+func (c *Client) ListWorkers() ([]Worker, error) {
+	respBytes, err := c.get("/workers")
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Workers []Worker `json:"workers"`
+	}
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal workers failed: %w", err)
+	}
+	return resp.Workers, nil
+}
+
+// CountTargets 返回目标总数，只请求一条记录并读取分页信息中的总数
+func (c *Client) CountTargets() (int, error) {
+	respBytes, err := c.get("/targets?l=1")
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Targets    []Target   `json:"targets"`
+		Pagination Pagination `json:"pagination"`
+	}
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		return 0, fmt.Errorf("unmarshal targets failed: %w", err)
+	}
+	if resp.Pagination.Count == 0 && len(resp.Targets) > 0 {
+		// 旧版本没有分页信息，退回到完整列表
+		targets, err := c.ListAllTargets("")
+		if err != nil {
+			return 0, err
+		}
+		return len(targets), nil
+	}
+	return resp.Pagination.Count, nil
+}
+
+// ConnectionState 表示连接探测结果
+type ConnectionState struct {
+	StatusCode int           `json:"status_code"`
+	Latency    time.Duration `json:"latency"`
+	TLS        *TLSState     `json:"tls,omitempty"`
+}
+
+// TLSState 表示服务端证书信息
+type TLSState struct {
+	Version     string    `json:"version"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"fingerprint"`
+	Verified    bool      `json:"verified"`               // 证书链和主机名是否通过校验
+	VerifyError string    `json:"verify_error,omitempty"` // 校验失败原因
+	PinMatched  *bool     `json:"pin_matched,omitempty"`  // 配置了证书指纹时是否一致
+}
+
+// ProbeConnection 探测AWVS是否可达并收集证书信息
+// 探测时不校验证书，以便在证书有问题时仍能给出具体原因
+func (c *Client) ProbeConnection() (*ConnectionState, error) {
+	base, ok := c.httpCli.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unsupported transport")
+	}
+	tr := base.Clone()
+	tlsConfig := tr.TLSClientConfig.Clone()
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyConnection = nil
+	tr.TLSClientConfig = tlsConfig
+	defer tr.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(c.context(), http.MethodGet, c.config.APIURL+"/api/v1/info", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := (&http.Client{Transport: tr, Timeout: c.httpCli.Timeout}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	state := &ConnectionState{
+		StatusCode: resp.StatusCode,
+		Latency:    time.Since(start),
+	}
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		state.TLS = c.inspectTLS(req.URL, resp.TLS, base.TLSClientConfig.RootCAs)
+	}
+	return state, nil
+}
+
+// inspectTLS 校验服务端证书链并与固定指纹比对
+func (c *Client) inspectTLS(u *url.URL, cs *tls.ConnectionState, roots *x509.CertPool) *TLSState {
+	leaf := cs.PeerCertificates[0]
+	sum := sha256.Sum256(leaf.Raw)
+	state := &TLSState{
+		Version:     tls.VersionName(cs.Version),
+		Subject:     leaf.Subject.String(),
+		Issuer:      leaf.Issuer.String(),
+		NotAfter:    leaf.NotAfter,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       u.Hostname(),
+	})
+	if err != nil {
+		state.VerifyError = err.Error()
+	} else {
+		state.Verified = true
+	}
+
+	if c.config.CertFingerprint != "" {
+		pin, err := ParseFingerprint(c.config.CertFingerprint)
+		matched := err == nil && pin == state.Fingerprint
+		state.PinMatched = &matched
+	}
+	return state
+}
+
+// Config 返回客户端配置的副本，API密钥除外
+func (c *Client) Config() Config {
+	config := *c.config
+	config.APIKey = ""
+	return config
+}
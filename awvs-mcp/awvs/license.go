@@ -0,0 +1,153 @@
+package awvs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 额度不足的原因
+const (
+	QuotaLicenseExpired = "license_expired"       // 许可证已过期
+	QuotaTargetLimit    = "target_limit"          // 目标数已达上限
+	QuotaScanLimit      = "concurrent_scan_limit" // 并发扫描数已达上限
+	QuotaRejected       = "rejected"              // AWVS以409拒绝了请求
+)
+
+// Me 表示当前API密钥对应的用户
+type Me struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Role      string `json:"role"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// Quota 表示许可证额度的使用情况，上限为0表示不限制，剩余为-1表示不限制
+type Quota struct {
+	ProductCode        string `json:"product_code"`
+	Expired            bool   `json:"expired"`
+	Expires            string `json:"expires,omitempty"`
+	MaxTargets         int    `json:"max_targets"`
+	Targets            int    `json:"targets"`
+	RemainingTargets   int    `json:"remaining_targets"`
+	MaxConcurrentScans int    `json:"max_concurrent_scans"`
+	ActiveScans        int    `json:"active_scans"`
+	RemainingScans     int    `json:"remaining_scans"`
+}
+
+// QuotaError 表示因许可证额度不足而未执行的操作
+type QuotaError struct {
+	Reason string `json:"reason"`
+	Quota  *Quota `json:"quota,omitempty"`
+	Detail string `json:"detail"`
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded (%s): %s", e.Reason, e.Detail)
+}
+
+// GetMe 获取当前API密钥对应的用户信息
+func (c *Client) GetMe() (*Me, error) {
+	respBytes, err := c.get("/me")
+	if err != nil {
+		return nil, err
+	}
+
+	var me Me
+	if err := json.Unmarshal(respBytes, &me); err != nil {
+		return nil, fmt.Errorf("unmarshal me failed: %w", err)
+	}
+	return &me, nil
+}
+
+// GetQuota 根据许可证信息、目标数和运行中的扫描计算剩余额度
+func (c *Client) GetQuota() (*Quota, error) {
+	info, err := c.GetInfo()
+	if err != nil {
+		return nil, err
+	}
+	return c.QuotaFromInfo(info)
+}
+
+// quotaOrNil 获取额度，无法获取时（如旧版本或权限不足）记录日志并返回nil，调用方跳过额度检查
+func (c *Client) quotaOrNil() *Quota {
+	quota, err := c.GetQuota()
+	if err != nil {
+		log.Printf("获取许可证额度失败，跳过额度检查: %v", err)
+		return nil
+	}
+	return quota
+}
+
+// QuotaFromInfo 根据已获取的许可证信息计算剩余额度，避免重复请求/info
+func (c *Client) QuotaFromInfo(info *Info) (*Quota, error) {
+	targets, err := c.CountTargets()
+	if err != nil {
+		return nil, err
+	}
+	active, err := c.ActiveScans()
+	if err != nil {
+		return nil, err
+	}
+
+	license := info.License
+	quota := &Quota{
+		ProductCode:        license.ProductCode,
+		Expired:            license.Expired,
+		Expires:            license.Expires,
+		MaxTargets:         license.MaxTargets,
+		Targets:            targets,
+		RemainingTargets:   -1,
+		MaxConcurrentScans: license.MaxConcurrentScans,
+		ActiveScans:        active,
+		RemainingScans:     -1,
+	}
+	if license.MaxTargets > 0 {
+		quota.RemainingTargets = max(license.MaxTargets-targets, 0)
+	}
+	if license.MaxConcurrentScans > 0 {
+		quota.RemainingScans = max(license.MaxConcurrentScans-active, 0)
+	}
+	if !quota.Expired && quota.Expires != "" {
+		if expires, err := time.Parse(time.RFC3339, quota.Expires); err == nil && time.Now().After(expires) {
+			quota.Expired = true
+		}
+	}
+	return quota, nil
+}
+
+// CheckTarget 检查是否还能添加新目标
+func (q *Quota) CheckTarget() error {
+	if q.Expired {
+		return &QuotaError{Reason: QuotaLicenseExpired, Quota: q, Detail: "license expired at " + q.Expires}
+	}
+	if q.RemainingTargets == 0 {
+		return &QuotaError{Reason: QuotaTargetLimit, Quota: q, Detail: fmt.Sprintf("target limit %d reached", q.MaxTargets)}
+	}
+	return nil
+}
+
+// CheckScan 检查是否还能启动新扫描
+func (q *Quota) CheckScan() error {
+	if q.Expired {
+		return &QuotaError{Reason: QuotaLicenseExpired, Quota: q, Detail: "license expired at " + q.Expires}
+	}
+	if q.RemainingScans == 0 {
+		return &QuotaError{Reason: QuotaScanLimit, Quota: q, Detail: fmt.Sprintf("%d of %d concurrent scans in use", q.ActiveScans, q.MaxConcurrentScans)}
+	}
+	return nil
+}
+
+// quotaError 将AWVS返回的409转换为额度错误，其他错误原样返回
+func quotaError(err error, quota *Quota) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		return &QuotaError{Reason: QuotaRejected, Quota: quota, Detail: apiErr.Body}
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package awvs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeLicenseAWVS 模拟许可证额度检查用到的接口，记录收到的开始扫描请求数
+type fakeLicenseAWVS struct {
+	mu       sync.Mutex
+	maxScans  int
+	started   int
+	scanLists int // 列出扫描的请求数，即统计负载的次数
+}
+
+func (f *fakeLicenseAWVS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+	switch {
+	case path == "/info":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"license": map[string]interface{}{"max_concurrent_scans": f.maxScans},
+		})
+	case path == "/targets/t1":
+		json.NewEncoder(w).Encode(Target{TargetID: "t1", Address: "https://app.example.com"})
+	case path == "/targets":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"targets":    []Target{{TargetID: "t1", Address: "https://app.example.com"}},
+			"pagination": Pagination{Count: 1},
+		})
+	case path == "/scans" && r.Method == http.MethodGet:
+		f.scanLists++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"scans": []Scan{{ScanID: "s0", TargetID: "t0", Status: "processing"}},
+		})
+	case path == "/scans" && r.Method == http.MethodPost:
+		f.started++
+		json.NewEncoder(w).Encode(Scan{ScanID: "s1", TargetID: "t1"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// 直接对已有目标启动扫描时也要检查并发扫描额度和扫描范围
+func TestStartScanChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxScans int
+		scope    []string
+		reason   string // 期望的额度错误原因，为空表示应成功
+		outside  bool   // 是否期望超出扫描范围
+	}{
+		{name: "within quota", maxScans: 2},
+		{name: "unlimited", maxScans: 0},
+		{name: "concurrent limit", maxScans: 1, reason: QuotaScanLimit},
+		{name: "outside scope", maxScans: 2, scope: []string{"*.corp.example.com"}, outside: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeLicenseAWVS{maxScans: tt.maxScans}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			scope, err := ParseScope(tt.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := NewClient(&Config{APIURL: server.URL, Scope: scope})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.StartScan("t1", ScanTypeFull)
+			var quotaErr *QuotaError
+			var scopeErr *ScopeError
+			switch {
+			case tt.reason != "":
+				if !errors.As(err, &quotaErr) || quotaErr.Reason != tt.reason {
+					t.Fatalf("StartScan error = %v, want quota error %s", err, tt.reason)
+				}
+			case tt.outside:
+				if !errors.As(err, &scopeErr) {
+					t.Fatalf("StartScan error = %v, want scope error", err)
+				}
+			case err != nil:
+				t.Fatalf("StartScan: %v", err)
+			}
+
+			want := 1
+			if tt.reason != "" || tt.outside {
+				want = 0
+			}
+			if fake.started != want {
+				t.Errorf("scans started = %d, want %d", fake.started, want)
+			}
+		})
+	}
+}
+
+// LeastBusy统计过的负载在开始扫描时沿用，每个实例只逐页统计一次扫描
+func TestLeastBusyReusesLoad(t *testing.T) {
+	pool := NewPool()
+	fakes := []*fakeLicenseAWVS{{maxScans: 5}, {maxScans: 5}}
+	for i, fake := range fakes {
+		server := httptest.NewServer(fake)
+		defer server.Close()
+		client, err := NewClient(&Config{APIURL: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pool.Add(fmt.Sprintf("awvs%d", i), client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, client, err := pool.LeastBusy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.AddAndScan("https://app.example.com", ScanTypeFull, "", nil); err != nil {
+		t.Fatalf("AddAndScan: %v", err)
+	}
+	for i, fake := range fakes {
+		if fake.scanLists != 1 {
+			t.Errorf("instance %d listed scans %d times, want 1", i, fake.scanLists)
+		}
+	}
+}
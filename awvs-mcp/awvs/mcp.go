@@ -32,7 +32,10 @@ func NewServer(configPath string) (*Server, error) {
 	}
 
 	// 创建AWVS客户端
-	client := NewClient(&config)
+	client, err := NewClient(&config)
+	if err != nil {
+		return nil, fmt.Errorf("create awvs client failed: %w", err)
+	}
 
 	// 返回服务器实例
 	return &Server{
@@ -229,16 +232,10 @@ func (s *Server) handleScan(req Request) (Response, error) {
 		return Response{}, fmt.Errorf("scan_type must be a non-empty string")
 	}
 
-	// 添加目标
-	target, err := s.client.AddTarget(scanReq.URL, scanReq.Cookies, scanReq.Headers)
+	// 添加新目标并开始扫描，添加前检查目标数和并发扫描额度，避免额度不足时留下未扫描的目标
+	scan, target, err := s.client.AddTargetAndScan(scanReq.URL, scanReq.ScanType, scanReq.Cookies, scanReq.Headers)
 	if err != nil {
-		return Response{}, fmt.Errorf("add target failed: %w", err)
-	}
-
-	// 开始扫描
-	scan, err := s.client.StartScan(target.TargetID, scanReq.ScanType)
-	if err != nil {
-		return Response{}, fmt.Errorf("start scan failed: %w", err)
+		return Response{}, err
 	}
 
 	// 构建响应
@@ -258,9 +255,9 @@ func (s *Server) handleScan(req Request) (Response, error) {
 // 处理list_targets请求
 func (s *Server) handleListTargets(req Request) (Response, error) {
 	// 获取所有目标
-	targets, err := s.client.ListTargets()
+	targets, err := s.client.ListAllTargets("")
 	if err != nil {
-		return Response{}, fmt.Errorf("list targets failed: %w", err)
+		return Response{}, err
 	}
 
 	// 构建响应
@@ -279,9 +276,9 @@ func (s *Server) handleListTargets(req Request) (Response, error) {
 // 处理list_scans请求
 func (s *Server) handleListScans(req Request) (Response, error) {
 	// 获取所有扫描任务
-	scans, err := s.client.ListScans()
+	scans, err := s.client.ListAllScans("")
 	if err != nil {
-		return Response{}, fmt.Errorf("list scans failed: %w", err)
+		return Response{}, err
 	}
 
 	// 构建响应
@@ -300,7 +297,7 @@ func (s *Server) handleListScans(req Request) (Response, error) {
 // 处理delete_all请求
 func (s *Server) handleDeleteAll(req Request) (Response, error) {
 	// 删除所有目标（会级联删除所有扫描任务）
-	if err := s.client.DeleteAllTargets(); err != nil {
+	if _, err := s.client.DeleteAllTargets(); err != nil {
 		return Response{}, fmt.Errorf("delete all targets failed: %w", err)
 	}
 
@@ -319,7 +316,7 @@ func (s *Server) handleDeleteAll(req Request) (Response, error) {
 // 处理delete_scans请求
 func (s *Server) handleDeleteScans(req Request) (Response, error) {
 	// 删除所有扫描任务
-	if err := s.client.DeleteAllScans(); err != nil {
+	if _, err := s.client.DeleteAllScans(); err != nil {
 		return Response{}, fmt.Errorf("delete all scans failed: %w", err)
 	}
 
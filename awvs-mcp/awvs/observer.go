@@ -0,0 +1,52 @@
+package awvs
+
+// Observer 接收客户端从AWVS获取到的数据，用于在本地记录历史
+type Observer interface {
+	ObserveTargets(targets []Target)
+	ObserveScans(scans []Scan)
+	ObserveVulnerabilities(vulns []Vulnerability)
+	ObserveTargetsDeleted(targetIDs []string)
+	ObserveScansDeleted(scanIDs []string)
+}
+
+// SetObserver 设置数据观察者，需在客户端开始使用前调用
+func (c *Client) SetObserver(observer Observer) {
+	c.observer = observer
+}
+
+// WithoutObserver 返回不通知观察者的客户端副本
+func (c *Client) WithoutObserver() *Client {
+	clone := *c
+	clone.observer = nil
+	return &clone
+}
+
+func (c *Client) observeTargets(targets ...Target) {
+	if c.observer != nil && len(targets) > 0 {
+		c.observer.ObserveTargets(targets)
+	}
+}
+
+func (c *Client) observeScans(scans ...Scan) {
+	if c.observer != nil && len(scans) > 0 {
+		c.observer.ObserveScans(scans)
+	}
+}
+
+func (c *Client) observeVulnerabilities(vulns ...Vulnerability) {
+	if c.observer != nil && len(vulns) > 0 {
+		c.observer.ObserveVulnerabilities(vulns)
+	}
+}
+
+func (c *Client) observeTargetsDeleted(targetIDs ...string) {
+	if c.observer != nil && len(targetIDs) > 0 {
+		c.observer.ObserveTargetsDeleted(targetIDs)
+	}
+}
+
+func (c *Client) observeScansDeleted(scanIDs ...string) {
+	if c.observer != nil && len(scanIDs) > 0 {
+		c.observer.ObserveScansDeleted(scanIDs)
+	}
+}
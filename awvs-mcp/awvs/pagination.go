@@ -0,0 +1,131 @@
+package awvs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// 每页拉取的条目数量
+const pageSize = 100
+
+// Pagination 表示AWVS列表接口的分页信息
+type Pagination struct {
+	Count      int      `json:"count"`
+	CursorHash string   `json:"cursor_hash"`
+	Cursors    []string `json:"cursors"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+// Next 返回下一页游标，没有下一页时返回空字符串
+func (p Pagination) Next() string {
+	if p.NextCursor != "" {
+		return p.NextCursor
+	}
+	// 新版本AWVS在cursors中返回[当前游标, 下一页游标]
+	if len(p.Cursors) > 1 {
+		return p.Cursors[1]
+	}
+	return ""
+}
+
+// walkPages 按游标逐页请求列表接口，key为响应中列表字段的名称
+func walkPages[T any](c *Client, path, key, query string, fn func([]T) error) error {
+	cursor := ""
+	for {
+		params := url.Values{}
+		params.Set("l", fmt.Sprintf("%d", pageSize))
+		if cursor != "" {
+			params.Set("c", cursor)
+		}
+		if query != "" {
+			params.Set("q", query)
+		}
+
+		respBytes, err := c.get(path + "?" + params.Encode())
+		if err != nil {
+			return err
+		}
+
+		var resp map[string]json.RawMessage
+		if err := json.Unmarshal(respBytes, &resp); err != nil {
+			return fmt.Errorf("unmarshal %s response failed: %w", key, err)
+		}
+
+		var items []T
+		if raw, ok := resp[key]; ok {
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return fmt.Errorf("unmarshal %s failed: %w", key, err)
+			}
+		}
+
+		var pagination Pagination
+		if raw, ok := resp["pagination"]; ok {
+			if err := json.Unmarshal(raw, &pagination); err != nil {
+				return fmt.Errorf("unmarshal pagination failed: %w", err)
+			}
+		}
+
+		if len(items) > 0 {
+			if err := fn(items); err != nil {
+				return err
+			}
+		}
+
+		next := pagination.Next()
+		if next == "" || next == cursor || len(items) == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// WalkTargets 逐页获取扫描目标，每获取一页调用一次fn
+func (c *Client) WalkTargets(query string, fn func([]Target) error) error {
+	err := walkPages(c, "/targets", "targets", query, func(page []Target) error {
+		c.observeTargets(page...)
+		return fn(page)
+	})
+	if err != nil {
+		return fmt.Errorf("list targets failed: %w", err)
+	}
+	return nil
+}
+
+// WalkScans 逐页获取扫描任务，每获取一页调用一次fn
+func (c *Client) WalkScans(query string, fn func([]Scan) error) error {
+	err := walkPages(c, "/scans", "scans", query, func(page []Scan) error {
+		c.observeScans(page...)
+		return fn(page)
+	})
+	if err != nil {
+		return fmt.Errorf("list scans failed: %w", err)
+	}
+	return nil
+}
+
+// ListAllTargets 逐页获取全部扫描目标，query为AWVS过滤表达式，为空时返回全部
+func (c *Client) ListAllTargets(query string) ([]Target, error) {
+	var targets []Target
+	err := c.WalkTargets(query, func(page []Target) error {
+		targets = append(targets, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// ListAllScans 逐页获取全部扫描任务，query为AWVS过滤表达式，为空时返回全部
+func (c *Client) ListAllScans(query string) ([]Scan, error) {
+	var scans []Scan
+	err := c.WalkScans(query, func(page []Scan) error {
+		scans = append(scans, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scans, nil
+}
@@ -0,0 +1,166 @@
+package awvs
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// DefaultInstance 未配置多实例时使用的实例名称
+const DefaultInstance = "default"
+
+// Pool 管理多个命名的AWVS实例，支持在运行中整体替换实例列表
+type Pool struct {
+	mu      sync.RWMutex
+	names   []string
+	clients map[string]*Client
+}
+
+// NewPool 创建实例池
+func NewPool() *Pool {
+	return &Pool{clients: make(map[string]*Client)}
+}
+
+// Add 添加实例，第一个添加的实例作为默认实例
+func (p *Pool) Add(name string, client *Client) error {
+	if name == "" {
+		return fmt.Errorf("instance name is required")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.clients[name]; ok {
+		return fmt.Errorf("duplicate instance name: %s", name)
+	}
+	p.names = append(p.names, name)
+	p.clients[name] = client
+	return nil
+}
+
+// Names 返回所有实例名称，保持配置中的顺序
+func (p *Pool) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string(nil), p.names...)
+}
+
+// Instance 实例名称及其客户端
+type Instance struct {
+	Name   string
+	Client *Client
+}
+
+// Snapshot 在同一次加锁中返回全部实例的名称和客户端，保持配置中的顺序
+// 热加载替换实例列表后，快照中的客户端仍可继续使用
+func (p *Pool) Snapshot() []Instance {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	instances := make([]Instance, 0, len(p.names))
+	for _, name := range p.names {
+		instances = append(instances, Instance{Name: name, Client: p.clients[name]})
+	}
+	return instances
+}
+
+// Len 返回实例数量
+func (p *Pool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.names)
+}
+
+// Get 按名称获取实例，名称为空时返回默认实例
+func (p *Pool) Get(name string) (*Client, error) {
+	_, client, err := p.Resolve(name)
+	return client, err
+}
+
+// Resolve 按名称获取实例并返回实际的实例名称，名称为空时返回默认实例
+func (p *Pool) Resolve(name string) (string, *Client, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if name == "" {
+		if len(p.names) == 0 {
+			return "", nil, fmt.Errorf("no awvs instance configured")
+		}
+		name = p.names[0]
+	}
+
+	client, ok := p.clients[name]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown instance %q, available instances: %s", name, strings.Join(p.names, ","))
+	}
+	return name, client, nil
+}
+
+// LeastBusy 返回当前运行中扫描最少的实例，查询失败的实例会被跳过
+// 返回的客户端沿用统计出的扫描数量，开始扫描时的额度检查不再重复统计
+func (p *Pool) LeastBusy() (string, *Client, error) {
+	// 查询负载较慢，先取实例快照再查询，避免长时间持有锁
+	var best *Instance
+	bestLoad := -1
+	for _, instance := range p.Snapshot() {
+		load, err := instance.Client.ActiveScans()
+		if err != nil {
+			log.Printf("获取实例 %s 的扫描负载失败: %v", instance.Name, err)
+			continue
+		}
+		if bestLoad < 0 || load < bestLoad {
+			instance := instance
+			best, bestLoad = &instance, load
+		}
+	}
+
+	if best == nil {
+		return "", nil, fmt.Errorf("no awvs instance available")
+	}
+	return best.Name, best.Client.WithActiveScans(bestLoad), nil
+}
+
+// Replace 用另一个实例池的内容替换当前实例
+// 已经取得客户端的调用继续使用旧客户端完成，之后的调用使用新实例
+func (p *Pool) Replace(other *Pool) {
+	other.mu.RLock()
+	names := append([]string(nil), other.names...)
+	clients := make(map[string]*Client, len(other.clients))
+	for name, client := range other.clients {
+		clients[name] = client
+	}
+	other.mu.RUnlock()
+
+	p.mu.Lock()
+	p.names = names
+	p.clients = clients
+	p.mu.Unlock()
+}
+
+// WithActiveScans 返回沿用已知扫描数量的客户端副本，LeastBusy已统计过负载时
+// 之后的额度检查不必再逐页统计一遍扫描
+func (c *Client) WithActiveScans(active int) *Client {
+	clone := *c
+	clone.activeScans = &active
+	return &clone
+}
+
+// ActiveScans 返回当前排队或运行中的扫描数量，会逐页统计全部扫描
+func (c *Client) ActiveScans() (int, error) {
+	if c.activeScans != nil {
+		return *c.activeScans, nil
+	}
+	active := 0
+	err := c.WalkScans("", func(page []Scan) error {
+		for _, scan := range page {
+			switch scan.State() {
+			case "queued", "starting", "processing":
+				active++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return active, nil
+}
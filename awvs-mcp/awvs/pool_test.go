@@ -0,0 +1,32 @@
+package awvs
+
+import "testing"
+
+// 热加载删除实例后，之前取得的快照仍能拿到客户端
+func TestSnapshotSurvivesReplace(t *testing.T) {
+	pool := NewPool()
+	for _, name := range []string{"main", "backup"} {
+		if err := pool.Add(name, &Client{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot := pool.Snapshot()
+
+	reloaded := NewPool()
+	if err := reloaded.Add("renamed", &Client{}); err != nil {
+		t.Fatal(err)
+	}
+	pool.Replace(reloaded)
+
+	if len(snapshot) != 2 || snapshot[0].Name != "main" || snapshot[1].Name != "backup" {
+		t.Fatalf("snapshot = %+v, want main and backup in order", snapshot)
+	}
+	for _, instance := range snapshot {
+		if instance.Client == nil {
+			t.Errorf("instance %s has no client", instance.Name)
+		}
+		if _, err := pool.Get(instance.Name); err == nil {
+			t.Errorf("Get(%s) after reload succeeded, want an unknown instance error", instance.Name)
+		}
+	}
+}
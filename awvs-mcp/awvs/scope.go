@@ -0,0 +1,103 @@
+package awvs
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Scope 表示允许添加和扫描的目标范围，由域名和CIDR组成
+// 域名以 *. 或 . 开头时匹配其所有子域名（不含自身），否则只匹配该域名
+type Scope struct {
+	hosts    map[string]bool // 精确匹配的域名
+	suffixes []string        // 子域名后缀，以 . 开头
+	prefixes []netip.Prefix  // IP地址段
+}
+
+// ScopeError 表示目标不在允许的扫描范围内
+type ScopeError struct {
+	Host string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("target %s is outside the configured scope", e.Host)
+}
+
+// ParseScope 解析扫描范围，entries为空时返回nil，表示不限制
+func ParseScope(entries []string) (*Scope, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	scope := &Scope{hosts: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			return nil, fmt.Errorf("empty scope entry")
+		case strings.Contains(entry, "/"):
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid scope cidr %q: %w", entry, err)
+			}
+			scope.prefixes = append(scope.prefixes, prefix.Masked())
+		case strings.HasPrefix(entry, "*.") || strings.HasPrefix(entry, "."):
+			suffix := "." + strings.TrimLeft(entry, "*.")
+			if suffix == "." {
+				return nil, fmt.Errorf("invalid scope domain %q", entry)
+			}
+			scope.suffixes = append(scope.suffixes, suffix)
+		default:
+			if addr, err := netip.ParseAddr(entry); err == nil {
+				scope.prefixes = append(scope.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+				continue
+			}
+			if strings.ContainsAny(entry, ":*") {
+				return nil, fmt.Errorf("invalid scope domain %q", entry)
+			}
+			scope.hosts[strings.TrimSuffix(entry, ".")] = true
+		}
+	}
+	return scope, nil
+}
+
+// Check 检查目标地址是否在范围内，scope为nil时不限制
+// 目标为域名时只按域名匹配，不做DNS解析，避免解析结果变化导致范围判断不稳定
+func (s *Scope) Check(address string) error {
+	if s == nil {
+		return nil
+	}
+
+	host := address
+	if u, err := url.Parse(address); err == nil && u.Host != "" {
+		host = u.Hostname()
+	} else if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+	if host == "" {
+		return fmt.Errorf("invalid target address %q", address)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap()
+		for _, prefix := range s.prefixes {
+			if prefix.Contains(addr) {
+				return nil
+			}
+		}
+		return &ScopeError{Host: host}
+	}
+
+	if s.hosts[host] {
+		return nil
+	}
+	for _, suffix := range s.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return &ScopeError{Host: host}
+}
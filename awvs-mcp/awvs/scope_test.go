@@ -0,0 +1,57 @@
+package awvs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScopeCheck(t *testing.T) {
+	scope, err := ParseScope([]string{"app.example.com", "*.corp.example.com", "10.0.0.0/8", "192.168.1.10", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("ParseScope: %v", err)
+	}
+
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"https://app.example.com/login", true},
+		{"https://APP.example.com.:8443/", true},
+		{"https://www.example.com/", false},
+		{"https://api.corp.example.com/", true},
+		{"https://corp.example.com/", false},
+		{"https://evilcorp.example.com/", false},
+		{"http://10.1.2.3:8080/", true},
+		{"http://11.1.2.3/", false},
+		{"http://192.168.1.10/", true},
+		{"http://192.168.1.11/", false},
+		{"http://[2001:db8::1]/", true},
+		{"http://[::ffff:10.0.0.1]/", true},
+		{"app.example.com", true},
+		{"app.example.com:443", true},
+	}
+	for _, tt := range tests {
+		err := scope.Check(tt.address)
+		if tt.allowed && err != nil {
+			t.Errorf("Check(%q) = %v, want allowed", tt.address, err)
+		}
+		var scopeErr *ScopeError
+		if !tt.allowed && !errors.As(err, &scopeErr) {
+			t.Errorf("Check(%q) = %v, want ScopeError", tt.address, err)
+		}
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	if scope, err := ParseScope(nil); err != nil || scope != nil {
+		t.Fatalf("ParseScope(nil) = %v, %v, want nil scope", scope, err)
+	}
+	if err := (*Scope)(nil).Check("https://anything.example.com"); err != nil {
+		t.Errorf("nil scope Check = %v, want nil", err)
+	}
+	for _, entry := range []string{"", "10.0.0.0/33", "*.", "exa*mple.com"} {
+		if _, err := ParseScope([]string{entry}); err == nil {
+			t.Errorf("ParseScope(%q) succeeded, want error", entry)
+		}
+	}
+}
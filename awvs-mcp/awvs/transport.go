@@ -0,0 +1,142 @@
+package awvs
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// 默认超时时间
+const (
+	defaultTimeout        = 30 * time.Second
+	defaultConnectTimeout = 10 * time.Second
+)
+
+// newTransport 根据配置创建HTTP传输层，包括CA证书、证书指纹、客户端证书和代理
+func newTransport(config *Config) (*http.Transport, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// 未配置proxy时使用HTTPS_PROXY/NO_PROXY等环境变量，命中环境变量代理时记录日志，避免流量被静默转发
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, err := ParseProxy(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(proxyURL)
+	} else if apiURL, err := url.Parse(config.APIURL); err == nil && apiURL.Host != "" {
+		if proxyURL, err := proxy(&http.Request{URL: apiURL}); err == nil && proxyURL != nil {
+			log.Printf("实例 %s 未配置proxy，使用环境变量中的代理 %s", config.Name, proxyURL.Redacted())
+		}
+	}
+
+	connectTimeout := config.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = defaultConnectTimeout
+	}
+
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: connectTimeout,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConnsPerHost: 10,
+	}, nil
+}
+
+// newTLSConfig 构建TLS配置
+func newTLSConfig(config *Config) (*tls.Config, error) {
+	// 指定了CA证书说明需要校验证书链，此时即使verify_ssl为false也开启校验
+	verify := config.VerifySSL || config.CAFile != ""
+	tlsConfig := &tls.Config{InsecureSkipVerify: !verify}
+	if !verify && config.CertFingerprint == "" && config.ClientCert != "" {
+		log.Printf("实例 %s 配置了客户端证书但未校验服务端证书，建议设置verify_ssl、ca_file或cert_fingerprint", config.Name)
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file failed: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate found in ca file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCert != "" || config.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate failed: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// 固定证书指纹时，即使关闭了证书链校验也要求服务端证书与指纹一致
+	if config.CertFingerprint != "" {
+		pin, err := ParseFingerprint(config.CertFingerprint)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("server presented no certificate")
+			}
+			sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+			if hex.EncodeToString(sum[:]) != pin {
+				return fmt.Errorf("server certificate fingerprint %s does not match pinned fingerprint", hex.EncodeToString(sum[:]))
+			}
+			return nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// ParseFingerprint 解析SHA-256证书指纹，支持冒号分隔和大小写，返回小写十六进制
+func ParseFingerprint(fingerprint string) (string, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+	normalized = strings.TrimPrefix(normalized, "sha256/")
+	decoded, err := hex.DecodeString(normalized)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("invalid certificate fingerprint %q, must be a sha256 hex digest", fingerprint)
+	}
+	return normalized, nil
+}
+
+// ParseProxy 解析代理地址，支持 http、https、socks5 和 socks5h
+func ParseProxy(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q, scheme must be http, https, socks5 or socks5h", proxy)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q, host is required", proxy)
+	}
+	return proxyURL, nil
+}
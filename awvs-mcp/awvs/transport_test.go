@@ -0,0 +1,44 @@
+package awvs
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 设置ca_file后无论verify_ssl如何都校验证书链
+func TestNewTLSConfigCAFileEnablesVerification(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		config   Config
+		insecure bool
+	}{
+		{"default", Config{}, true},
+		{"verify_ssl", Config{VerifySSL: true}, false},
+		{"ca_file without verify_ssl", Config{CAFile: caFile}, false},
+		{"ca_file with verify_ssl", Config{VerifySSL: true, CAFile: caFile}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := newTLSConfig(&tt.config)
+			if err != nil {
+				t.Fatalf("newTLSConfig: %v", err)
+			}
+			if tlsConfig.InsecureSkipVerify != tt.insecure {
+				t.Errorf("InsecureSkipVerify = %v, want %v", tlsConfig.InsecureSkipVerify, tt.insecure)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package awvs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// 漏洞严重级别常量
+const (
+	SeverityInfo     = 0 // 信息
+	SeverityLow      = 1 // 低危
+	SeverityMedium   = 2 // 中危
+	SeverityHigh     = 3 // 高危
+	SeverityCritical = 4 // 严重（AWVS 15及以上版本）
+)
+
+// severityNames 严重级别名称映射
+var severityNames = map[int]string{
+	SeverityInfo:     "info",
+	SeverityLow:      "low",
+	SeverityMedium:   "medium",
+	SeverityHigh:     "high",
+	SeverityCritical: "critical",
+}
+
+// SeverityName 返回严重级别对应的名称
+func SeverityName(severity int) string {
+	if name, ok := severityNames[severity]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseSeverity 将严重级别名称转换为对应的数值
+func ParseSeverity(name string) (int, error) {
+	for severity, n := range severityNames {
+		if n == name {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity: %s", name)
+}
+
+// Vulnerability 表示AWVS发现的漏洞
+type Vulnerability struct {
+	VulnID        string   `json:"vuln_id"`
+	TargetID      string   `json:"target_id"`
+	VtID          string   `json:"vt_id"`
+	VtName        string   `json:"vt_name"`
+	Severity      int      `json:"severity"`
+	Confidence    int      `json:"confidence"`
+	Criticality   int      `json:"criticality"`
+	AffectsURL    string   `json:"affects_url"`
+	AffectsDetail string   `json:"affects_detail"`
+	Status        string   `json:"status"`
+	LastSeen      string   `json:"last_seen"`
+	Tags          []string `json:"tags"`
+}
+
+// ListVulnerabilities 获取漏洞列表，query为AWVS过滤表达式（如 "severity:3;status:open"），为空时返回全部
+func (c *Client) ListVulnerabilities(query string) ([]Vulnerability, error) {
+	var vulns []Vulnerability
+	err := c.WalkVulnerabilities(query, func(page []Vulnerability) error {
+		vulns = append(vulns, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vulns, nil
+}
+
+// WalkVulnerabilities 逐页获取漏洞，每获取一页调用一次fn
+func (c *Client) WalkVulnerabilities(query string, fn func([]Vulnerability) error) error {
+	err := walkPages(c, "/vulnerabilities", "vulnerabilities", query, func(page []Vulnerability) error {
+		c.observeVulnerabilities(page...)
+		return fn(page)
+	})
+	if err != nil {
+		return fmt.Errorf("list vulnerabilities failed: %w", err)
+	}
+	return nil
+}
+
+// GetVulnerability 获取指定漏洞的详情
+func (c *Client) GetVulnerability(vulnID string) (*Vulnerability, error) {
+	respBytes, err := c.get("/vulnerabilities/" + url.PathEscape(vulnID))
+	if err != nil {
+		return nil, fmt.Errorf("get vulnerability failed: %w", err)
+	}
+
+	var vuln Vulnerability
+	if err := json.Unmarshal(respBytes, &vuln); err != nil {
+		return nil, fmt.Errorf("unmarshal vulnerability response failed: %w", err)
+	}
+	c.observeVulnerabilities(vuln)
+
+	return &vuln, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/taoing/awvs-mcp/audit"
+	"github.com/taoing/awvs-mcp/config"
+	"github.com/taoing/awvs-mcp/models"
+)
+
+// runAudit 执行audit子命令，目前支持校验审计日志的哈希链
+func runAudit(configPath string, explicit bool, args []string) error {
+	if len(args) < 1 || args[0] != "verify" {
+		return fmt.Errorf("usage: awvs-mcp [-config path] audit verify [-file audit.log] [-require-chain]")
+	}
+
+	verifyFlag := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	file := verifyFlag.String("file", "", "审计日志路径，默认使用配置中的 audit.path")
+	requireChain := verifyFlag.Bool("require-chain", false, "要求每条记录都带有哈希，配置中开启了 audit.hash_chain 时默认开启；"+
+		"不开启时允许开启哈希链之前和关闭哈希链之后的记录不带哈希")
+	if err := verifyFlag.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	// 未指定文件时从配置中读取路径；指定了文件时仍读取存在的配置文件，以获取 hash_chain 设置
+	resolved, err := resolveConfigPath(configPath, explicit)
+	if err != nil {
+		return err
+	}
+	var auditConfig *models.AuditConfig
+	if *file == "" {
+		cfg, err := loadConfig(resolved)
+		if err != nil {
+			return err
+		}
+		if cfg.Audit == nil {
+			return fmt.Errorf("audit is not configured, use -file to specify the audit log")
+		}
+		auditConfig = cfg.Audit
+	} else if resolved != "" {
+		cfg, err := config.Load(resolved)
+		if err != nil {
+			return err
+		}
+		auditConfig = cfg.Audit
+	}
+	path := *file
+	if path == "" {
+		path = auditConfig.Path
+	}
+
+	// 开启了哈希链时，不带哈希的记录可能是被删掉了hash字段，未显式指定时默认要求整个日志都带哈希
+	explicitRequire := false
+	verifyFlag.Visit(func(f *flag.Flag) {
+		if f.Name == "require-chain" {
+			explicitRequire = true
+		}
+	})
+	chainFromConfig := false
+	if auditConfig != nil && auditConfig.HashChain && !explicitRequire {
+		*requireChain = true
+		chainFromConfig = true
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open audit log failed: %w", err)
+	}
+	defer f.Close()
+
+	count, err := audit.Verify(f, *requireChain)
+	if err != nil && chainFromConfig {
+		return fmt.Errorf("审计日志校验失败（已校验 %d 条）: %w\n配置中开启了 audit.hash_chain，因此要求每条记录都带哈希；"+
+			"日志中包含开启哈希链之前的记录时，使用 -require-chain=false 校验", count, err)
+	}
+	if err != nil {
+		return fmt.Errorf("审计日志校验失败（已校验 %d 条）: %w", count, err)
+	}
+	fmt.Printf("审计日志校验通过，共 %d 条记录\n", count)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/taoing/awvs-mcp/config"
+	"github.com/taoing/awvs-mcp/models"
+)
+
+// 默认配置文件路径
+const defaultConfigPath = "config.json"
+
+// resolveConfigPath 返回配置文件的绝对路径
+// 未显式指定配置文件且默认文件不存在时返回空，表示只使用环境变量，便于容器中部署
+func resolveConfigPath(path string, explicit bool) (string, error) {
+	if !explicit {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+	}
+
+	// 处理配置文件路径
+	if !filepath.IsAbs(path) {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return "", fmt.Errorf("resolve config path failed: %w", err)
+		}
+		path = absPath
+	}
+	return path, nil
+}
+
+// loadConfig 加载并校验配置
+func loadConfig(path string) (*models.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// runConfig 执行config子命令
+func runConfig(configPath string, explicit bool, args []string) error {
+	if len(args) < 1 || args[0] != "validate" {
+		return fmt.Errorf("usage: awvs-mcp [-config path] config validate")
+	}
+
+	path, err := resolveConfigPath(configPath, explicit)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	instances := len(cfg.Instances)
+	if instances == 0 {
+		instances = 1
+	}
+	fmt.Printf("配置有效：AWVS实例 %d 个", instances)
+	if cfg.Tracker != nil {
+		fmt.Print("，已启用工单集成")
+	}
+	if cfg.Notify != nil {
+		fmt.Printf("，Webhook %d 个", len(cfg.Notify.Webhooks))
+	}
+	if cfg.History != nil {
+		fmt.Print("，已启用历史库")
+	}
+	fmt.Println()
+	return nil
+}
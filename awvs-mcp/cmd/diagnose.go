@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/taoing/awvs-mcp/awvs"
+	"github.com/taoing/awvs-mcp/diagnose"
+)
+
+// runDiagnose 并发诊断指定实例，未指定时诊断全部实例
+func runDiagnose(ctx context.Context, pool *awvs.Pool, instance string) ([]*diagnose.Report, error) {
+	selected, err := instances(pool, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*diagnose.Report, len(selected))
+	var wg sync.WaitGroup
+	for i, instance := range selected {
+		wg.Add(1)
+		go func(i int, instance awvs.Instance) {
+			defer wg.Done()
+			reports[i] = diagnose.Run(instance.Name, instance.Client.WithContext(ctx))
+		}(i, instance)
+	}
+	wg.Wait()
+	return reports, nil
+}
+
+// runDoctor 执行doctor子命令，存在失败项时返回错误
+func runDoctor(pool *awvs.Pool, args []string) error {
+	var instance string
+	doctorFlag := flag.NewFlagSet("doctor", flag.ExitOnError)
+	doctorFlag.StringVar(&instance, "instance", "", "只诊断指定实例，默认诊断全部实例")
+	if err := doctorFlag.Parse(args); err != nil {
+		return err
+	}
+
+	reports, err := runDiagnose(context.Background(), pool, instance)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, report := range reports {
+		report.WriteText(os.Stdout)
+		fmt.Println()
+		if report.Status == diagnose.StatusFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d instance(s) failed diagnosis", failed)
+	}
+	return nil
+}
+
+// 注册诊断工具
+func registerDiagnoseTool(mcpServer *server.MCPServer, pool *awvs.Pool) {
+	diagnoseTool := mcp.NewTool("diagnose",
+		mcp.WithDescription("诊断AWVS连接问题：检查连通性、证书、API密钥、版本、许可证剩余额度、扫描引擎和当前扫描负载，并给出处理建议。工具调用失败时应先运行此工具"),
+		mcp.WithString("instance",
+			mcp.Description("只诊断指定AWVS实例，留空诊断全部实例")),
+	)
+
+	mcpServer.AddTool(diagnoseTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		reports, err := runDiagnose(ctx, pool, instanceArg(request))
+		if err != nil {
+			return errorResult("诊断失败", err), nil
+		}
+
+		responseJSON, _ := json.Marshal(map[string]interface{}{
+			"reports": reports,
+		})
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: string(responseJSON),
+				},
+			},
+		}, nil
+	}))
+}
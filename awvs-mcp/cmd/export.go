@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/taoing/awvs-mcp/awvs"
+	"github.com/taoing/awvs-mcp/export"
+)
+
+// filterFlag 支持多次指定 -filter column=value
+type filterFlag map[string]string
+
+func (f filterFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f filterFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("filter must be in column=value form: %s", value)
+	}
+	f[strings.TrimSpace(key)] = val
+	return nil
+}
+
+// 未指定output时直接返回的导出内容上限，避免大量数据全部驻留内存并塞进工具结果
+const maxInlineExportBytes = 1 << 20
+
+var errExportTooLarge = errors.New("export exceeds the inline size limit")
+
+// cappedBuffer 在写入内容超过上限时返回errExportTooLarge，使导出提前停止
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errExportTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// runExport 执行export子命令
+func runExport(pool *awvs.Pool, args []string) error {
+	var (
+		opts     export.Options
+		columns  string
+		output   string
+		instance string
+		filters  = filterFlag{}
+	)
+
+	exportFlag := flag.NewFlagSet("export", flag.ExitOnError)
+	exportFlag.StringVar(&opts.Kind, "kind", export.KindVulnerabilities, "导出的数据类型: targets, scans 或 vulnerabilities")
+	exportFlag.StringVar(&opts.Format, "format", export.FormatCSV, "导出格式: csv 或 ndjson")
+	exportFlag.StringVar(&columns, "columns", "", "导出的列，逗号分隔，默认全部列")
+	exportFlag.StringVar(&opts.Query, "query", "", "AWVS过滤表达式，如 severity:3;status:open")
+	exportFlag.StringVar(&output, "o", "", "输出文件路径，默认输出到标准输出")
+	exportFlag.Var(filters, "filter", "按列过滤，格式为 column=value[,value]，可重复指定")
+	exportFlag.StringVar(&instance, "instance", "", "AWVS实例名称，默认使用第一个实例")
+	if err := exportFlag.Parse(args); err != nil {
+		return err
+	}
+
+	awvsClient, err := pool.Get(instance)
+	if err != nil {
+		return err
+	}
+
+	opts.Columns = splitColumns(columns)
+	opts.Filters = filters
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("create output file failed: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	count, err := export.Export(awvsClient, w, opts)
+	if err != nil {
+		return err
+	}
+
+	if output != "" {
+		fmt.Printf("已导出 %d 条%s记录到 %s\n", count, opts.Kind, output)
+	}
+	return nil
+}
+
+// 注册数据导出工具
+func registerExportTool(mcpServer *server.MCPServer, pool *awvs.Pool, outputDir string) {
+	exportTool := mcp.NewTool("export_data",
+		mcp.WithDescription("将AWVS的目标、扫描或漏洞数据导出为CSV或NDJSON"),
+		mcp.WithString("kind",
+			mcp.Description("导出的数据类型"),
+			mcp.Enum(export.KindTargets, export.KindScans, export.KindVulnerabilities),
+			mcp.Required(),
+		),
+		mcp.WithString("format",
+			mcp.Description("导出格式"),
+			mcp.Enum(export.FormatCSV, export.FormatNDJSON),
+		),
+		mcp.WithString("columns",
+			mcp.Description("导出的列，逗号分隔，留空导出全部列")),
+		mcp.WithObject("filters",
+			mcp.Description("按列过滤，键为列名，值可用逗号分隔多个候选值"),
+			mcp.AdditionalProperties(true)),
+		mcp.WithString("query",
+			mcp.Description("AWVS过滤表达式，如 severity:3;status:open")),
+		mcp.WithString("output",
+			mcp.Description("导出文件名，保存到配置的output_dir目录下，留空则直接返回导出内容（最多1MB）")),
+		mcp.WithString("instance",
+			mcp.Description(instanceDescription)),
+	)
+
+	mcpServer.AddTool(exportTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		kind, _ := request.Params.Arguments["kind"].(string)
+		format, _ := request.Params.Arguments["format"].(string)
+		columns, _ := request.Params.Arguments["columns"].(string)
+		query, _ := request.Params.Arguments["query"].(string)
+		output, _ := request.Params.Arguments["output"].(string)
+		filtersObj, _ := request.Params.Arguments["filters"].(map[string]interface{})
+
+		opts := export.Options{
+			Kind:    kind,
+			Format:  format,
+			Columns: splitColumns(columns),
+			Filters: make(map[string]string),
+			Query:   query,
+		}
+		for key, value := range filtersObj {
+			opts.Filters[key] = fmt.Sprint(value)
+		}
+
+		awvsClient, err := pool.Get(instanceArg(request))
+		if err != nil {
+			return errorResult("选择AWVS实例失败", err), nil
+		}
+		awvsClient = awvsClient.WithContext(ctx)
+
+		// 指定了输出文件时直接流式写入文件
+		if output != "" {
+			outputPath, err := resolveOutputPath(outputDir, output)
+			if err != nil {
+				return errorResult("创建导出文件失败", err), nil
+			}
+			f, err := os.Create(outputPath)
+			if err != nil {
+				return errorResult("创建导出文件失败", err), nil
+			}
+			defer f.Close()
+
+			count, err := export.Export(awvsClient, f, opts)
+			if err != nil {
+				return errorResult("导出数据失败", err), nil
+			}
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					mcp.TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("已导出 %d 条%s记录到 %s", count, kind, output),
+					},
+				},
+			}, nil
+		}
+
+		buf := &cappedBuffer{limit: maxInlineExportBytes}
+		if _, err := export.Export(awvsClient, buf, opts); err != nil {
+			if errors.Is(err, errExportTooLarge) {
+				err = fmt.Errorf("export is larger than %d bytes, narrow it with query, filters or columns, or save it with output", maxInlineExportBytes)
+			}
+			return errorResult("导出数据失败", err), nil
+		}
+		text := buf.String()
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: text,
+				},
+			},
+		}, nil
+	}))
+}
+
+// splitColumns 解析逗号分隔的列名
+func splitColumns(columns string) []string {
+	if strings.TrimSpace(columns) == "" {
+		return nil
+	}
+	var result []string
+	for _, col := range strings.Split(columns, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			result = append(result, col)
+		}
+	}
+	return result
+}
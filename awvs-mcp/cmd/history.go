@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/taoing/awvs-mcp/awvs"
+	"github.com/taoing/awvs-mcp/history"
+)
+
+// 默认历史库路径
+const defaultHistoryPath = "history.db"
+
+// 工具参数中的日期格式
+const dateLayout = "2006-01-02"
+
+// vulnerability_trends的分组方式
+const (
+	groupByNone   = "none"
+	groupByTarget = "target"
+	groupByGroup  = "group"
+)
+
+// syncHistory 定期将AWVS数据同步到历史库
+func syncHistory(ctx context.Context, store *history.Store, pool *awvs.Pool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, instance := range pool.Snapshot() {
+			if err := store.Sync(instance.Name, instance.Client); err != nil {
+				log.Printf("同步实例 %s 到历史库失败: %v", instance.Name, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 注册历史查询工具
+func registerHistoryTools(mcpServer *server.MCPServer, store *history.Store, pool *awvs.Pool) {
+	historyTargetsTool := mcp.NewTool("history_targets",
+		mcp.WithDescription("列出本地历史库中记录过的所有扫描目标（包括已从AWVS删除的目标）及首次、最后出现时间"),
+		mcp.WithString("instance",
+			mcp.Description("只列出指定AWVS实例的目标，留空列出全部实例")),
+	)
+
+	scanHistoryTool := mcp.NewTool("scan_history",
+		mcp.WithDescription("从本地历史库查询扫描记录，可回答某个目标最近一次扫描是什么时候，无需访问AWVS"),
+		mcp.WithString("target",
+			mcp.Description("目标ID或URL，留空查询全部目标")),
+		mcp.WithNumber("limit",
+			mcp.Description("最多返回的记录数，默认20")),
+		mcp.WithString("instance",
+			mcp.Description("只查询指定AWVS实例的扫描，留空查询全部实例")),
+	)
+
+	trendsTool := mcp.NewTool("vulnerability_trends",
+		mcp.WithDescription("基于本地历史库统计漏洞趋势：各严重级别未修复漏洞的时间序列、平均修复时长（从首次发现到确认修复）、复发率和复发最多的漏洞类型"),
+		mcp.WithString("from",
+			mcp.Description("统计开始日期，格式YYYY-MM-DD，默认30天前")),
+		mcp.WithString("to",
+			mcp.Description("统计结束日期，格式YYYY-MM-DD，默认今天")),
+		mcp.WithString("interval",
+			mcp.Description(fmt.Sprintf("时间序列粒度，时间点数量不能超过%d个", history.MaxSeriesPoints)),
+			mcp.Enum(history.IntervalDay, history.IntervalWeek, history.IntervalMonth)),
+		mcp.WithString("target",
+			mcp.Description("只统计指定目标（ID或URL），留空统计全部目标")),
+		mcp.WithString("group_by",
+			mcp.Description("分组方式，target表示额外输出每个目标的统计，group表示额外输出每个AWVS目标组的统计"),
+			mcp.Enum(groupByNone, groupByTarget, groupByGroup)),
+		mcp.WithString("instance",
+			mcp.Description("group_by为group时读取目标组的AWVS实例，默认使用第一个实例")),
+	)
+
+	mcpServer.AddTool(historyTargetsTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		targets, err := store.Targets()
+		if err != nil {
+			return errorResult("查询历史目标失败", err), nil
+		}
+		if instance := instanceArg(request); instance != "" {
+			filtered := make([]history.TargetRecord, 0, len(targets))
+			for _, t := range targets {
+				if t.Instance == instance {
+					filtered = append(filtered, t)
+				}
+			}
+			targets = filtered
+		}
+
+		responseJSON, _ := json.Marshal(map[string]interface{}{
+			"targets": targets,
+			"count":   len(targets),
+		})
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: string(responseJSON),
+				},
+			},
+		}, nil
+	}))
+
+	mcpServer.AddTool(scanHistoryTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target, _ := request.Params.Arguments["target"].(string)
+		limit := 20
+		if l, ok := request.Params.Arguments["limit"].(float64); ok && l > 0 {
+			limit = int(l)
+		}
+
+		scans, err := store.Scans(target)
+		if err != nil {
+			return errorResult("查询扫描历史失败", err), nil
+		}
+		if instance := instanceArg(request); instance != "" {
+			filtered := make([]history.ScanRecord, 0, len(scans))
+			for _, s := range scans {
+				if s.Instance == instance {
+					filtered = append(filtered, s)
+				}
+			}
+			scans = filtered
+		}
+
+		total := len(scans)
+		if len(scans) > limit {
+			scans = scans[:limit]
+		}
+
+		responseData := map[string]interface{}{
+			"scans": scans,
+			"count": len(scans),
+			"total": total,
+		}
+		if len(scans) > 0 {
+			responseData["last_scan"] = scans[0]
+		}
+		responseJSON, _ := json.Marshal(responseData)
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: string(responseJSON),
+				},
+			},
+		}, nil
+	}))
+	mcpServer.AddTool(trendsTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		from, _ := request.Params.Arguments["from"].(string)
+		to, _ := request.Params.Arguments["to"].(string)
+		interval, _ := request.Params.Arguments["interval"].(string)
+		target, _ := request.Params.Arguments["target"].(string)
+		groupBy, _ := request.Params.Arguments["group_by"].(string)
+
+		opts := history.TrendOptions{
+			Interval: interval,
+			Target:   target,
+		}
+		switch groupBy {
+		case "", groupByNone:
+		case groupByTarget:
+			opts.ByTarget = true
+		case groupByGroup:
+			groups, err := targetGroups(ctx, pool, instanceArg(request))
+			if err != nil {
+				return errorResult("获取目标组失败", err), nil
+			}
+			opts.Groups = groups
+		default:
+			return errorResult("统计漏洞趋势失败", fmt.Errorf("unsupported group_by: %s", groupBy)), nil
+		}
+		if from != "" {
+			t, err := time.ParseInLocation(dateLayout, from, time.Local)
+			if err != nil {
+				return errorResult("解析开始日期失败", err), nil
+			}
+			opts.From = t
+		}
+		if to != "" {
+			t, err := time.ParseInLocation(dateLayout, to, time.Local)
+			if err != nil {
+				return errorResult("解析结束日期失败", err), nil
+			}
+			// 结束日期包含当天
+			opts.To = t.AddDate(0, 0, 1)
+		}
+
+		trends, err := store.Trends(opts)
+		if err != nil {
+			return errorResult("统计漏洞趋势失败", err), nil
+		}
+
+		responseJSON, _ := json.Marshal(trends)
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: string(responseJSON),
+				},
+			},
+		}, nil
+	}))
+}
+
+// targetGroups 从AWVS实例读取全部目标组及其成员
+func targetGroups(ctx context.Context, pool *awvs.Pool, instance string) ([]history.Group, error) {
+	client, err := pool.Get(instance)
+	if err != nil {
+		return nil, err
+	}
+	client = client.WithContext(ctx)
+
+	groups, err := client.ListAllTargetGroups()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]history.Group, 0, len(groups))
+	for _, g := range groups {
+		targetIDs, err := client.GroupTargetIDs(g.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, history.Group{ID: g.GroupID, Name: g.Name, TargetIDs: targetIDs})
+	}
+	return result, nil
+}
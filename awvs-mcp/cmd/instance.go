@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/taoing/awvs-mcp/awvs"
+	"github.com/taoing/awvs-mcp/models"
+)
+
+// 工具通用的实例参数说明
+const instanceDescription = "AWVS实例名称，留空使用默认实例（配置中的第一个实例）"
+
+// newPool 根据配置创建实例池，未配置instances时使用顶层的单实例配置
+func newPool(config *models.Config) (*awvs.Pool, error) {
+	scope, err := awvs.ParseScope(config.Scope)
+	if err != nil {
+		return nil, fmt.Errorf("scope: %w", err)
+	}
+
+	pool := awvs.NewPool()
+	for _, instance := range instanceConfigs(config) {
+		client, err := awvs.NewClient(&awvs.Config{
+			Name:            instance.Name,
+			APIURL:          instance.APIURL,
+			APIKey:          instance.APIKey,
+			VerifySSL:       instance.VerifySSL,
+			CAFile:          instance.CAFile,
+			CertFingerprint: instance.CertFingerprint,
+			ClientCert:      instance.ClientCert,
+			ClientKey:       instance.ClientKey,
+			Proxy:           instance.Proxy,
+			Timeout:         time.Duration(instance.Timeout) * time.Second,
+			ConnectTimeout:  time.Duration(instance.ConnectTimeout) * time.Second,
+			Scope:           scope,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("instance %s: %w", instance.Name, err)
+		}
+		if err := pool.Add(instance.Name, client); err != nil {
+			return nil, err
+		}
+	}
+	return pool, nil
+}
+
+// instanceConfigs 返回配置中的实例列表，未配置instances时使用顶层的单实例配置
+func instanceConfigs(config *models.Config) []models.InstanceConfig {
+	if len(config.Instances) > 0 {
+		return config.Instances
+	}
+	return []models.InstanceConfig{{
+		Name:             awvs.DefaultInstance,
+		APIURL:           config.APIURL,
+		APIKey:           config.APIKey,
+		VerifySSL:        config.VerifySSL,
+		ConnectionConfig: config.ConnectionConfig,
+	}}
+}
+
+// instanceArg 读取工具调用中的instance参数
+func instanceArg(request mcp.CallToolRequest) string {
+	instance, _ := request.Params.Arguments["instance"].(string)
+	return instance
+}
+
+// instanceTarget 带实例名称的目标，用于汇总多个实例的列表
+type instanceTarget struct {
+	Instance string `json:"instance"`
+	awvs.Target
+}
+
+// instanceScan 带实例名称的扫描，用于汇总多个实例的列表
+type instanceScan struct {
+	Instance string `json:"instance"`
+	awvs.Scan
+}
+
+// instances 返回需要查询的实例及其客户端，未指定时返回全部实例
+// 名称和客户端一起取出，查询期间热加载删除实例也不会取到空客户端
+func instances(pool *awvs.Pool, instance string) ([]awvs.Instance, error) {
+	if instance == "" {
+		return pool.Snapshot(), nil
+	}
+	client, err := pool.Get(instance)
+	if err != nil {
+		return nil, err
+	}
+	return []awvs.Instance{{Name: instance, Client: client}}, nil
+}
+
+// joinErrors 将各实例的错误合并为一个错误
+func joinErrors(errs map[string]string) error {
+	messages := make([]string, 0, len(errs))
+	for name, msg := range errs {
+		messages = append(messages, name+": "+msg)
+	}
+	sort.Strings(messages)
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
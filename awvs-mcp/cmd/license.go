@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// instanceLicense 表示单个实例的用户、版本和许可证额度
+type instanceLicense struct {
+	Instance string      `json:"instance"`
+	User     *awvs.Me    `json:"user,omitempty"`
+	Version  string      `json:"version,omitempty"`
+	Quota    *awvs.Quota `json:"quota,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// quotaExceededResult 构建额度不足时返回的结构化结果，便于助手据此调整扫描计划
+func quotaExceededResult(instance string, err *awvs.QuotaError) *mcp.CallToolResult {
+	responseJSON, _ := json.Marshal(map[string]interface{}{
+		"error":    "quota_exceeded",
+		"instance": instance,
+		"reason":   err.Reason,
+		"detail":   err.Detail,
+		"quota":    err.Quota,
+	})
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(responseJSON),
+			},
+		},
+		IsError: true,
+	}
+}
+
+// 注册许可证信息工具
+func registerLicenseTool(mcpServer *server.MCPServer, pool *awvs.Pool) {
+	licenseTool := mcp.NewTool("license_info",
+		mcp.WithDescription("查询AWVS许可证信息和剩余额度：到期时间、目标数上限与剩余目标数、并发扫描上限与当前运行的扫描数，用于在额度内规划扫描"),
+		mcp.WithString("instance",
+			mcp.Description("只查询指定AWVS实例，留空查询全部实例")),
+	)
+
+	mcpServer.AddTool(licenseTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		selected, err := instances(pool, instanceArg(request))
+		if err != nil {
+			return errorResult("查询许可证信息失败", err), nil
+		}
+
+		licenses := make([]instanceLicense, 0, len(selected))
+		for _, instance := range selected {
+			client := instance.Client.WithContext(ctx)
+			item := instanceLicense{Instance: instance.Name}
+
+			if me, err := client.GetMe(); err == nil {
+				item.User = me
+			}
+			info, err := client.GetInfo()
+			if err == nil {
+				item.Version = info.Version()
+				item.Quota, err = client.QuotaFromInfo(info)
+			}
+			if err != nil {
+				item.Error = err.Error()
+			}
+			licenses = append(licenses, item)
+		}
+
+		responseJSON, _ := json.Marshal(map[string]interface{}{
+			"instances": licenses,
+		})
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: string(responseJSON),
+				},
+			},
+		}, nil
+	}))
+}
@@ -3,18 +3,24 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/taoing/awvs-mcp/audit"
 	"github.com/taoing/awvs-mcp/awvs"
+	"github.com/taoing/awvs-mcp/history"
+	"github.com/taoing/awvs-mcp/metrics"
 	"github.com/taoing/awvs-mcp/models"
+	"github.com/taoing/awvs-mcp/notify"
+	"github.com/taoing/awvs-mcp/ticket"
+	"github.com/taoing/awvs-mcp/tracing"
 
 	"os/signal"
 )
@@ -27,20 +33,46 @@ func main() {
 		configPath string
 	)
 
-	flag.StringVar(&configPath, "config", "config.json", "配置文件路径")
+	flag.StringVar(&configPath, "config", defaultConfigPath, "配置文件路径，支持JSON和YAML")
 
 	// 解析命令行参数
 	flag.Parse()
 
+	// 是否显式指定了配置文件
+	explicitConfig := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			explicitConfig = true
+		}
+	})
+
 	// 判断运行模式
 	args := flag.Args()
 	if len(args) < 1 {
-		fmt.Println("请指定运行模式: stdio 或 http")
+		fmt.Println("请指定运行模式: stdio、http、export、doctor、config 或 audit")
 		os.Exit(1)
 	}
 
 	mode = args[0]
 
+	// 校验配置后直接退出
+	if mode == "config" {
+		if err := runConfig(configPath, explicitConfig, args[1:]); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 校验审计日志后直接退出
+	if mode == "audit" {
+		if err := runAudit(configPath, explicitConfig, args[1:]); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 处理http模式的端口参数
 	if mode == "http" {
 		portFlag := flag.NewFlagSet("http", flag.ExitOnError)
@@ -51,58 +83,151 @@ func main() {
 		}
 	}
 
-	// 处理配置文件路径
-	if !filepath.IsAbs(configPath) {
-		absPath, err := filepath.Abs(configPath)
+	// 加载配置，环境变量优先于配置文件
+	configPath, err := resolveConfigPath(configPath, explicitConfig)
+	if err != nil {
+		fmt.Printf("加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Printf("加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 初始化上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 配置了链路追踪时为每次工具调用和AWVS请求创建span
+	if config.Tracing != nil {
+		shutdownTracing, err := tracing.Setup(ctx, &tracing.Config{
+			Exporter:    config.Tracing.Exporter,
+			Endpoint:    config.Tracing.Endpoint,
+			Headers:     config.Tracing.Headers,
+			ServiceName: config.Tracing.ServiceName,
+			SampleRatio: config.Tracing.SampleRatio,
+		})
 		if err != nil {
-			fmt.Printf("获取配置文件绝对路径失败: %v\n", err)
+			fmt.Printf("初始化链路追踪失败: %v\n", err)
 			os.Exit(1)
 		}
-		configPath = absPath
+		// 退出前导出尚未发送的span
+		defer func() {
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer shutdownCancel()
+			if err := shutdownTracing(shutdownCtx); err != nil {
+				log.Printf("导出链路追踪数据失败: %v", err)
+			}
+		}()
 	}
 
-	// 读取配置文件
-	configData, err := os.ReadFile(configPath)
-	if err != nil {
-		fmt.Printf("读取配置文件失败: %v\n", err)
-		os.Exit(1)
+	// 配置了审计日志时记录每次工具调用，必须在注册工具前打开
+	if config.Audit != nil && (mode == "stdio" || mode == "http") {
+		closeAudit, err := audit.Setup(config.Audit.Path, config.Audit.HashChain)
+		if err != nil {
+			fmt.Printf("打开审计日志失败: %v\n", err)
+			os.Exit(1)
+		}
+		defer closeAudit()
 	}
 
-	// 解析配置
-	var config models.Config
-	if err := json.Unmarshal(configData, &config); err != nil {
-		fmt.Printf("解析配置文件失败: %v\n", err)
-		os.Exit(1)
+	// 配置了历史库时记录客户端见过的所有数据
+	var historyStore *history.Store
+	if config.History != nil && (mode == "stdio" || mode == "http") {
+		if config.History.Path == "" {
+			config.History.Path = defaultHistoryPath
+		}
+		historyStore, err = history.Open(config.History.Path)
+		if err != nil {
+			fmt.Printf("打开历史库失败: %v\n", err)
+			os.Exit(1)
+		}
+		defer historyStore.Close()
 	}
 
-	// 创建AWVS客户端
-	awvsClient := awvs.NewClient(&awvs.Config{
-		APIURL:    config.APIURL,
-		APIKey:    config.APIKey,
-		VerifySSL: config.VerifySSL,
-	})
+	// 创建AWVS实例池，配置了Webhook时在后台监听每个实例的扫描事件
+	pool := awvs.NewPool()
+	configReloader := &reloader{
+		configPath:   configPath,
+		pool:         pool,
+		historyStore: historyStore,
+		watch:        mode == "stdio" || mode == "http",
+	}
+	if err := configReloader.apply(ctx, config); err != nil {
+		fmt.Printf("初始化AWVS实例失败: %v\n", err)
+		os.Exit(1)
+	}
 
 	// 创建MCP服务器
+	hooks := &server.Hooks{}
+	if config.Metrics != nil {
+		metrics.InstrumentHooks(hooks)
+	}
+	audit.TrackClients(hooks)
 	mcpServer := server.NewMCPServer(
 		"AWVS Scanner", // 服务器名称
 		"1.0.0",       // 版本
 		server.WithLogging(),
 		server.WithToolCapabilities(true),
+		server.WithHooks(hooks),
 	)
 
 	// 注册AWVS工具
-	registerAWVSTool(mcpServer, awvsClient)
+	registerAWVSTool(mcpServer, pool, historyStore)
+	registerReportTool(mcpServer, pool, config.ReportTemplateDir, config.OutputDir)
+	registerExportTool(mcpServer, pool, config.OutputDir)
+	registerDiagnoseTool(mcpServer, pool)
+	registerLicenseTool(mcpServer, pool)
+	if historyStore != nil {
+		registerHistoryTools(mcpServer, historyStore, pool)
+	}
 
-	// 初始化上下文
-	ctx := context.Background()
+	// 配置了工单系统时注册建单工具
+	if config.Tracker != nil {
+		tracker, err := ticket.NewTracker(&ticket.Config{
+			BaseURL:        config.Tracker.BaseURL,
+			Username:       config.Tracker.Username,
+			APIToken:       config.Tracker.APIToken,
+			Project:        config.Tracker.Project,
+			IssueType:      config.Tracker.IssueType,
+			PriorityMap:    config.Tracker.PriorityMap,
+			FieldMap:       config.Tracker.FieldMap,
+			Labels:         config.Tracker.Labels,
+			DoneTransition: config.Tracker.DoneTransition,
+			StateFile:      config.Tracker.StateFile,
+		}, pool)
+		if err != nil {
+			fmt.Printf("初始化工单集成失败: %v\n", err)
+			os.Exit(1)
+		}
+		registerTicketTools(mcpServer, tracker)
+	}
+
+	// 修改配置文件或收到SIGHUP时重新加载配置
+	if mode == "stdio" || mode == "http" {
+		go configReloader.run(ctx)
+	}
+
+	// 配置了指标服务时提供 /metrics 接口
+	if config.Metrics != nil && (mode == "stdio" || mode == "http") {
+		go metrics.Serve(ctx, config.Metrics.Listen)
+		go sampleActiveScans(ctx, pool, time.Duration(config.Metrics.ScanInterval)*time.Second)
+	}
+
+	// 定期同步AWVS数据到历史库
+	if historyStore != nil && config.History.SyncInterval > 0 {
+		go syncHistory(ctx, historyStore, pool, time.Duration(config.History.SyncInterval)*time.Second)
+	}
 
 	// 根据模式启动服务器
 	switch mode {
 	case "stdio":
 		fmt.Println("启动AWVS扫描器服务器 (Stdio模式)...")
 		log.Println("Starting AWVS Scanner in Stdio mode...")
-		// 启动终端模式服务
-		if err := server.ServeStdio(mcpServer); err != nil {
+		// 启动终端模式服务，收到SIGINT或SIGTERM时ServeStdio返回context.Canceled，
+		// 属于正常退出，需正常返回以执行上面的defer，导出尚未发送的span
+		if err := server.ServeStdio(mcpServer); err != nil && !errors.Is(err, context.Canceled) {
 			fmt.Printf("服务器错误: %v\n", err)
 			os.Exit(1)
 		}
@@ -126,14 +251,26 @@ func main() {
 		shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 5*time.Second)
 		defer shutdownCancel()
 		sseServer.Shutdown(shutdownCtx)
+	case "export":
+		// 导出数据后直接退出
+		if err := runExport(pool, args[1:]); err != nil {
+			fmt.Printf("导出失败: %v\n", err)
+			os.Exit(1)
+		}
+	case "doctor":
+		// 输出诊断报告后退出，存在失败项时返回非零状态码
+		if err := runDoctor(pool, args[1:]); err != nil {
+			fmt.Printf("诊断未通过: %v\n", err)
+			os.Exit(1)
+		}
 	default:
-		fmt.Printf("不支持的模式 '%s'，请使用 'stdio' 或 'http'\n", mode)
+		fmt.Printf("不支持的模式 '%s'，请使用 'stdio'、'http'、'export'、'doctor'、'config' 或 'audit'\n", mode)
 		os.Exit(1)
 	}
 }
 
 // 注册AWVS扫描工具
-func registerAWVSTool(mcpServer *server.MCPServer, awvsClient *awvs.Client) {
+func registerAWVSTool(mcpServer *server.MCPServer, pool *awvs.Pool, historyStore *history.Store) {
 	// 创建扫描站点工具
 	scanTool := mcp.NewTool("scan_website",
 		mcp.WithDescription("扫描网站漏洞"),
@@ -151,25 +288,33 @@ func registerAWVSTool(mcpServer *server.MCPServer, awvsClient *awvs.Client) {
 		mcp.WithObject("headers",
 			mcp.Description("扫描时使用的HTTP头"),
 			mcp.AdditionalProperties(true)),
+		mcp.WithString("instance",
+			mcp.Description("AWVS实例名称，留空时自动选择当前运行扫描最少的实例")),
 	)
 
 	// 创建列出目标工具
 	listTargetsTool := mcp.NewTool("list_targets",
-		mcp.WithDescription("列出所有扫描目标"),
+		mcp.WithDescription("列出所有扫描目标，未指定实例时汇总所有实例"),
+		mcp.WithString("instance",
+			mcp.Description("AWVS实例名称，留空汇总所有实例")),
 	)
 
 	// 创建列出扫描工具
 	listScansTool := mcp.NewTool("list_scans",
-		mcp.WithDescription("列出所有扫描任务"),
+		mcp.WithDescription("列出所有扫描任务，未指定实例时汇总所有实例"),
+		mcp.WithString("instance",
+			mcp.Description("AWVS实例名称，留空汇总所有实例")),
 	)
 
 	// 创建删除所有目标工具
 	deleteAllTool := mcp.NewTool("delete_all",
-		mcp.WithDescription("删除所有目标和扫描"),
+		mcp.WithDescription("删除指定实例上的所有目标和扫描"),
+		mcp.WithString("instance",
+			mcp.Description("AWVS实例名称，配置了多个实例时必须指定")),
 	)
 
 	// 添加扫描工具到服务器
-	mcpServer.AddTool(scanTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	mcpServer.AddTool(scanTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// 从请求中获取参数
 		url, _ := request.Params.Arguments["url"].(string)
 		scanType, _ := request.Params.Arguments["scan_type"].(string)
@@ -186,17 +331,26 @@ func registerAWVSTool(mcpServer *server.MCPServer, awvsClient *awvs.Client) {
 			}
 		}
 
+		// 未指定实例且配置了多个实例时选择负载最低的实例
+		instance := instanceArg(request)
+		var awvsClient *awvs.Client
+		var err error
+		if instance == "" && pool.Len() > 1 {
+			instance, awvsClient, err = pool.LeastBusy()
+		} else {
+			instance, awvsClient, err = pool.Resolve(instance)
+		}
+		if err != nil {
+			return errorResult("选择AWVS实例失败", err), nil
+		}
+
 		// 添加目标并开始扫描
-		scan, target, err := awvsClient.AddAndScan(url, scanType, cookies, headersMap)
+		scan, target, err := awvsClient.WithContext(ctx).AddAndScan(url, scanType, cookies, headersMap)
+		if quotaErr := (*awvs.QuotaError)(nil); errors.As(err, &quotaErr) {
+			return quotaExceededResult(instance, quotaErr), nil
+		}
 		if err != nil {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("扫描失败: %v", err),
-					},
-				},
-			}, nil
+			return errorResult("扫描失败", err), nil
 		}
 
 		// 构建响应
@@ -205,6 +359,7 @@ func registerAWVSTool(mcpServer *server.MCPServer, awvsClient *awvs.Client) {
 			"scan_id":   scan.ScanID,
 			"url":       url,
 			"scan_type": scanType,
+			"instance":  instance,
 		}
 
 		// 转换为JSON
@@ -219,21 +374,30 @@ func registerAWVSTool(mcpServer *server.MCPServer, awvsClient *awvs.Client) {
 				},
 			},
 		}, nil
-	})
+	}))
 
 	// 添加列出目标工具到服务器
-	mcpServer.AddTool(listTargetsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		// 获取所有目标
-		targets, err := awvsClient.ListTargets()
+	mcpServer.AddTool(listTargetsTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		selected, err := instances(pool, instanceArg(request))
 		if err != nil {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("获取目标失败: %v", err),
-					},
-				},
-			}, nil
+			return errorResult("获取目标失败", err), nil
+		}
+
+		// 获取所有实例的目标，单个实例失败不影响其他实例
+		targets := []instanceTarget{}
+		errs := make(map[string]string)
+		for _, instance := range selected {
+			items, err := instance.Client.WithContext(ctx).ListAllTargets("")
+			if err != nil {
+				errs[instance.Name] = err.Error()
+				continue
+			}
+			for _, t := range items {
+				targets = append(targets, instanceTarget{Instance: instance.Name, Target: t})
+			}
+		}
+		if len(errs) == len(selected) {
+			return errorResult("获取目标失败", joinErrors(errs)), nil
 		}
 
 		// 构建响应
@@ -241,6 +405,9 @@ func registerAWVSTool(mcpServer *server.MCPServer, awvsClient *awvs.Client) {
 			"targets": targets,
 			"count":   len(targets),
 		}
+		if len(errs) > 0 {
+			responseData["errors"] = errs
+		}
 
 		// 转换为JSON
 		responseJSON, _ := json.Marshal(responseData)
@@ -254,21 +421,30 @@ func registerAWVSTool(mcpServer *server.MCPServer, awvsClient *awvs.Client) {
 				},
 			},
 		}, nil
-	})
+	}))
 
 	// 添加列出扫描工具到服务器
-	mcpServer.AddTool(listScansTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		// 获取所有扫描
-		scans, err := awvsClient.ListScans()
+	mcpServer.AddTool(listScansTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		selected, err := instances(pool, instanceArg(request))
 		if err != nil {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("获取扫描失败: %v", err),
-					},
-				},
-			}, nil
+			return errorResult("获取扫描失败", err), nil
+		}
+
+		// 获取所有实例的扫描，单个实例失败不影响其他实例
+		scans := []instanceScan{}
+		errs := make(map[string]string)
+		for _, instance := range selected {
+			items, err := instance.Client.WithContext(ctx).ListAllScans("")
+			if err != nil {
+				errs[instance.Name] = err.Error()
+				continue
+			}
+			for _, s := range items {
+				scans = append(scans, instanceScan{Instance: instance.Name, Scan: s})
+			}
+		}
+		if len(errs) == len(selected) {
+			return errorResult("获取扫描失败", joinErrors(errs)), nil
 		}
 
 		// 构建响应
@@ -276,6 +452,9 @@ func registerAWVSTool(mcpServer *server.MCPServer, awvsClient *awvs.Client) {
 			"scans": scans,
 			"count": len(scans),
 		}
+		if len(errs) > 0 {
+			responseData["errors"] = errs
+		}
 
 		// 转换为JSON
 		responseJSON, _ := json.Marshal(responseData)
@@ -289,30 +468,88 @@ func registerAWVSTool(mcpServer *server.MCPServer, awvsClient *awvs.Client) {
 				},
 			},
 		}, nil
-	})
+	}))
 
 	// 注册删除所有目标工具
-	mcpServer.AddTool(deleteAllTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	mcpServer.AddTool(deleteAllTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// 删除操作不可恢复，多实例时必须明确指定实例
+		instance := instanceArg(request)
+		if instance == "" && pool.Len() > 1 {
+			return errorResult("删除所有目标失败", fmt.Errorf("instance is required when multiple instances are configured")), nil
+		}
+		instance, awvsClient, err := pool.Resolve(instance)
+		if err != nil {
+			return errorResult("删除所有目标失败", err), nil
+		}
+		awvsClient = awvsClient.WithContext(ctx)
+
+		// 删除前先把当前数据同步到历史库，删除后仍可查询
+		if historyStore != nil {
+			if err := historyStore.Sync(instance, awvsClient); err != nil {
+				log.Printf("删除前同步历史库失败: %v", err)
+			}
+		}
+
 		// 删除所有目标
-		err := awvsClient.DeleteAllTargets()
+		deleted, err := awvsClient.DeleteAllTargets()
+		audit.Affect(ctx, "target", deleted...)
 		if err != nil {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					mcp.TextContent{
-						Type: "text",
-						Text: fmt.Sprintf("删除所有目标失败: %v", err),
-					},
-				},
-			}, nil
+			return errorResult("删除所有目标失败", err), nil
 		}
 
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
 				mcp.TextContent{
 					Type: "text",
-					Text: "所有目标和扫描已成功删除",
+					Text: fmt.Sprintf("实例 %s 的所有目标和扫描已成功删除", instance),
 				},
 			},
 		}, nil
-	})
+	}))
+}
+
+// wrapTool 为工具处理函数添加链路追踪和审计日志
+func wrapTool(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return tracing.WrapTool(audit.WrapTool(handler))
 }
+
+// errorResult 构建工具调用失败时返回的文本结果
+// 设置IsError使客户端能识别失败，指标、链路追踪和审计日志也据此判断调用状态
+func errorResult(msg string, err error) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s: %v", msg, err),
+			},
+		},
+		IsError: true,
+	}
+}
+
+// newWatcher 根据配置创建扫描事件监听器
+func newWatcher(instance string, awvsClient *awvs.Client, config *models.NotifyConfig) (*notify.Watcher, error) {
+	notifyConfig := &notify.Config{
+		PollInterval: time.Duration(config.PollInterval) * time.Second,
+		MinSeverity:  awvs.SeverityHigh,
+	}
+	if config.MinSeverity != "" {
+		severity, err := awvs.ParseSeverity(config.MinSeverity)
+		if err != nil {
+			return nil, err
+		}
+		notifyConfig.MinSeverity = severity
+	}
+	for _, wc := range config.Webhooks {
+		notifyConfig.Webhooks = append(notifyConfig.Webhooks, notify.WebhookConfig{
+			Name:       wc.Name,
+			URL:        wc.URL,
+			Type:       wc.Type,
+			Secret:     wc.Secret,
+			Events:     wc.Events,
+			MaxRetries: wc.MaxRetries,
+		})
+	}
+	return notify.NewWatcher(instance, awvsClient, notifyConfig)
+}
+
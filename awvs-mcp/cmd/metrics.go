@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+	"github.com/taoing/awvs-mcp/metrics"
+)
+
+// 默认采集扫描数量的间隔
+const defaultScanInterval = 60 * time.Second
+
+// sampleActiveScans 定期采集每个实例排队和运行中的扫描数量
+func sampleActiveScans(ctx context.Context, pool *awvs.Pool, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultScanInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// 实例列表可能在热加载后变化，每次重新生成全部指标
+		counts := make(map[string]int)
+		for _, instance := range pool.Snapshot() {
+			active, err := instance.Client.ActiveScans()
+			if err != nil {
+				log.Printf("采集实例 %s 的扫描数量失败: %v", instance.Name, err)
+				continue
+			}
+			counts[instance.Name] = active
+		}
+		metrics.ResetActiveScans()
+		for name, active := range counts {
+			metrics.SetActiveScans(name, active)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
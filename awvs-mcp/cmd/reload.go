@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/taoing/awvs-mcp/awvs"
+	"github.com/taoing/awvs-mcp/history"
+	"github.com/taoing/awvs-mcp/models"
+	"github.com/taoing/awvs-mcp/notify"
+)
+
+// 配置文件变化后等待的时间，编辑器保存时通常会连续触发多个事件
+const reloadDebounce = 500 * time.Millisecond
+
+// reloader 负责在配置变化时重建AWVS实例和扫描事件监听
+// 实例池整体替换，已开始的工具调用继续使用旧客户端完成，MCP会话不受影响
+type reloader struct {
+	configPath   string
+	pool         *awvs.Pool
+	historyStore *history.Store
+	watch        bool // 是否启动扫描事件监听
+
+	mu       sync.Mutex
+	config   *models.Config
+	watchers map[string]*notify.Watcher // 实例名称到当前运行的监听器
+}
+
+// apply 根据配置重建实例池并重启扫描事件监听
+func (r *reloader) apply(ctx context.Context, config *models.Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pool, err := newPool(config)
+	if err != nil {
+		return err
+	}
+
+	// 先创建所有监听器，配置有误时保持原有状态不变
+	watchers := make(map[string]*notify.Watcher)
+	if r.watch && config.Notify != nil && len(config.Notify.Webhooks) > 0 {
+		for _, instance := range pool.Snapshot() {
+			watcher, err := newWatcher(instance.Name, instance.Client, config.Notify)
+			if err != nil {
+				return fmt.Errorf("create watcher failed: %w", err)
+			}
+			watchers[instance.Name] = watcher
+		}
+	}
+
+	// 配置了历史库时记录客户端见过的所有数据
+	if r.historyStore != nil {
+		for _, instance := range pool.Snapshot() {
+			instance.Client.SetObserver(r.historyStore.Observer(instance.Name))
+		}
+	}
+
+	r.pool.Replace(pool)
+
+	// 旧监听器停止轮询，已生成的事件和进行中的重试仍由旧的接收端投递完
+	for _, old := range r.watchers {
+		old.Stop()
+	}
+	// 仍指向同一AWVS地址的实例沿用旧监听器的基线，两次轮询之间的事件不会丢失
+	for name, watcher := range watchers {
+		if old, ok := r.watchers[name]; ok && sameScanner(r.config, config, name) {
+			watcher.Inherit(old)
+		}
+	}
+	for _, watcher := range watchers {
+		go watcher.Run(ctx)
+	}
+	r.watchers = watchers
+
+	if r.config != nil {
+		warnRestartRequired(r.config, config)
+	}
+	r.config = config
+	return nil
+}
+
+// sameScanner 判断实例在新旧配置中是否指向同一个AWVS地址
+func sameScanner(old, new *models.Config, name string) bool {
+	if old == nil {
+		return false
+	}
+	apiURL := func(config *models.Config) string {
+		for _, instance := range instanceConfigs(config) {
+			if instance.Name == name {
+				return instance.APIURL
+			}
+		}
+		return ""
+	}
+	oldURL := apiURL(old)
+	return oldURL != "" && oldURL == apiURL(new)
+}
+
+// reload 重新加载配置文件，失败时保留当前配置
+func (r *reloader) reload(ctx context.Context) {
+	config, err := loadConfig(r.configPath)
+	if err != nil {
+		log.Printf("重新加载配置失败，继续使用当前配置: %v", err)
+		return
+	}
+	if err := r.apply(ctx, config); err != nil {
+		log.Printf("应用新配置失败，继续使用当前配置: %v", err)
+		return
+	}
+	log.Printf("配置已重新加载，当前AWVS实例: %v", r.pool.Names())
+}
+
+// run 监听SIGHUP信号和配置文件变化，直到ctx被取消
+func (r *reloader) run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// 只使用环境变量时没有可监听的文件
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if r.configPath != "" {
+		fileWatcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Printf("创建配置文件监听失败，仅支持SIGHUP重新加载: %v", err)
+		} else {
+			defer fileWatcher.Close()
+			// 监听所在目录而不是文件本身，编辑器保存和K8s ConfigMap更新都是替换文件
+			if err := fileWatcher.Add(filepath.Dir(r.configPath)); err != nil {
+				log.Printf("监听配置文件失败，仅支持SIGHUP重新加载: %v", err)
+			} else {
+				events, errs = fileWatcher.Events, fileWatcher.Errors
+			}
+		}
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Printf("收到SIGHUP信号，重新加载配置")
+			r.reload(ctx)
+		case event := <-events:
+			if r.isConfigEvent(event) {
+				debounce = time.After(reloadDebounce)
+			}
+		case err := <-errs:
+			log.Printf("监听配置文件出错: %v", err)
+		case <-debounce:
+			debounce = nil
+			log.Printf("配置文件已修改，重新加载配置")
+			r.reload(ctx)
+		}
+	}
+}
+
+// isConfigEvent 判断文件事件是否与配置文件有关
+func (r *reloader) isConfigEvent(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Base(event.Name)
+	// K8s挂载的ConfigMap通过替换 ..data 符号链接更新
+	return filepath.Clean(event.Name) == r.configPath || name == "..data"
+}
+
+// warnRestartRequired 提示修改后需要重启才能生效的配置
+func warnRestartRequired(old, new *models.Config) {
+	if new.History != nil && new.History.Path == "" {
+		new.History.Path = defaultHistoryPath
+	}
+
+	var changed []string
+	if !reflect.DeepEqual(old.History, new.History) {
+		changed = append(changed, "history")
+	}
+	if !reflect.DeepEqual(old.Tracker, new.Tracker) {
+		changed = append(changed, "tracker")
+	}
+	if old.ReportTemplateDir != new.ReportTemplateDir {
+		changed = append(changed, "report_template_dir")
+	}
+	if old.OutputDir != new.OutputDir {
+		changed = append(changed, "output_dir")
+	}
+	if !reflect.DeepEqual(old.Metrics, new.Metrics) {
+		changed = append(changed, "metrics")
+	}
+	if !reflect.DeepEqual(old.Tracing, new.Tracing) {
+		changed = append(changed, "tracing")
+	}
+	if !reflect.DeepEqual(old.Audit, new.Audit) {
+		changed = append(changed, "audit")
+	}
+	if len(changed) > 0 {
+		log.Printf("以下配置的修改需要重启后生效: %v", changed)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/taoing/awvs-mcp/awvs"
+	"github.com/taoing/awvs-mcp/report"
+)
+
+// 注册报告生成工具
+func registerReportTool(mcpServer *server.MCPServer, pool *awvs.Pool, templateDir, outputDir string) {
+	reportTool := mcp.NewTool("generate_report",
+		mcp.WithDescription("根据AWVS目标、扫描和漏洞数据在本地生成Markdown或HTML汇总报告"),
+		mcp.WithString("format",
+			mcp.Description("报告格式"),
+			mcp.Enum(report.FormatMarkdown, report.FormatHTML),
+		),
+		mcp.WithString("target_id",
+			mcp.Description("只汇总指定目标，留空则汇总全部目标")),
+		mcp.WithString("output",
+			mcp.Description("报告文件名，保存到配置的output_dir目录下，留空则直接返回报告内容")),
+		mcp.WithString("instance",
+			mcp.Description(instanceDescription)),
+	)
+
+	mcpServer.AddTool(reportTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		format, _ := request.Params.Arguments["format"].(string)
+		targetID, _ := request.Params.Arguments["target_id"].(string)
+		output, _ := request.Params.Arguments["output"].(string)
+		if format == "" {
+			format = report.FormatMarkdown
+		}
+
+		// 先校验保存路径，避免无效路径时白白拉取数据
+		var outputPath string
+		if output != "" {
+			var err error
+			if outputPath, err = resolveOutputPath(outputDir, output); err != nil {
+				return errorResult("保存报告失败", err), nil
+			}
+		}
+
+		// 每次调用重新加载模板，便于用户修改模板后立即生效
+		renderer, err := report.NewRenderer(templateDir)
+		if err != nil {
+			return errorResult("加载报告模板失败", err), nil
+		}
+
+		awvsClient, err := pool.Get(instanceArg(request))
+		if err != nil {
+			return errorResult("选择AWVS实例失败", err), nil
+		}
+		awvsClient = awvsClient.WithContext(ctx)
+
+		summary, err := report.Collect(awvsClient, targetID)
+		if err != nil {
+			return errorResult("获取报告数据失败", err), nil
+		}
+
+		var buf bytes.Buffer
+		if err := renderer.Render(&buf, format, summary); err != nil {
+			return errorResult("渲染报告失败", err), nil
+		}
+
+		text := buf.String()
+		if output != "" {
+			if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+				return errorResult("保存报告失败", err), nil
+			}
+			text = fmt.Sprintf("报告已保存到 %s（目标 %d 个，漏洞 %d 个）", output, summary.TargetCount, summary.Total)
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: text,
+				},
+			},
+		}, nil
+	}))
+}
+
+// resolveOutputPath 将工具参数中的文件名解析为output_dir下的路径
+// MCP客户端可能是远程的，因此只允许写入配置的目录，拒绝绝对路径和 ..
+func resolveOutputPath(outputDir, name string) (string, error) {
+	if outputDir == "" {
+		return "", fmt.Errorf("output_dir is not configured, omit output to return the content directly")
+	}
+	name = filepath.Clean(name)
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("output must be a relative path inside output_dir: %s", name)
+	}
+	return filepath.Join(outputDir, name), nil
+}
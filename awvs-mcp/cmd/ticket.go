@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/taoing/awvs-mcp/ticket"
+)
+
+// 注册工单集成工具
+func registerTicketTools(mcpServer *server.MCPServer, tracker *ticket.Tracker) {
+	fileTicketTool := mcp.NewTool("file_ticket",
+		mcp.WithDescription("为选定的AWVS漏洞在工单系统中创建工单，已建单的漏洞不会重复创建"),
+		mcp.WithArray("vuln_ids",
+			mcp.Description("要建单的漏洞ID列表"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+			mcp.Required(),
+		),
+		mcp.WithString("instance",
+			mcp.Description("漏洞所在的AWVS实例名称，留空使用默认实例（配置中的第一个实例）")),
+	)
+
+	syncTicketsTool := mcp.NewTool("sync_tickets",
+		mcp.WithDescription("同步工单状态，AWVS中已修复的漏洞将对应工单流转为完成"),
+	)
+
+	mcpServer.AddTool(fileTicketTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		idsObj, _ := request.Params.Arguments["vuln_ids"].([]interface{})
+
+		var vulnIDs []string
+		for _, id := range idsObj {
+			if s, ok := id.(string); ok && strings.TrimSpace(s) != "" {
+				vulnIDs = append(vulnIDs, strings.TrimSpace(s))
+			}
+		}
+		if len(vulnIDs) == 0 {
+			return errorResult("建单失败", fmt.Errorf("vuln_ids must contain at least one id")), nil
+		}
+
+		results, err := tracker.FileTickets(ctx, instanceArg(request), vulnIDs)
+		if err != nil {
+			return errorResult("建单失败", err), nil
+		}
+
+		created := 0
+		for _, r := range results {
+			if r.Created {
+				created++
+			}
+		}
+		responseJSON, _ := json.Marshal(map[string]interface{}{
+			"results": results,
+			"created": created,
+		})
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: string(responseJSON),
+				},
+			},
+		}, nil
+	}))
+
+	mcpServer.AddTool(syncTicketsTool, wrapTool(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		results := tracker.Sync(ctx)
+
+		resolved := 0
+		for _, r := range results {
+			if r.Status == ticket.StatusResolved {
+				resolved++
+			}
+		}
+		responseJSON, _ := json.Marshal(map[string]interface{}{
+			"results":  results,
+			"resolved": resolved,
+		})
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: string(responseJSON),
+				},
+			},
+		}, nil
+	}))
+}
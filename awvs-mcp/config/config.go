@@ -0,0 +1,423 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/taoing/awvs-mcp/awvs"
+	"github.com/taoing/awvs-mcp/models"
+	"github.com/taoing/awvs-mcp/notify"
+	"github.com/taoing/awvs-mcp/tracing"
+	"gopkg.in/yaml.v3"
+)
+
+// 环境变量名称，均支持 <NAME>_FILE 形式从文件读取（适用于Docker/K8s secret）
+const (
+	EnvAPIURL       = "AWVS_API_URL"
+	EnvAPIKey       = "AWVS_API_KEY"
+	EnvVerifySSL    = "AWVS_VERIFY_SSL"
+	EnvTrackerToken = "AWVS_TRACKER_API_TOKEN"
+	fileEnvSuffix   = "_FILE"
+)
+
+// InstanceEnv 返回实例专用的环境变量名称，如实例prod-scanner的API密钥为 AWVS_PROD_SCANNER_API_KEY
+// 配置了instances时使用这些变量覆盖各实例的配置，名称中字母数字以外的字符替换为下划线
+func InstanceEnv(instance, env string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, instance)
+	return "AWVS_" + name + "_" + strings.TrimPrefix(env, "AWVS_")
+}
+
+// Load 读取配置文件并应用环境变量覆盖，path为空时只使用环境变量
+// 文件扩展名为 .yaml 或 .yml 时按YAML解析，否则按JSON解析
+func Load(path string) (*models.Config, error) {
+	var config models.Config
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config file failed: %w", err)
+		}
+		if err := unmarshal(path, data, &config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(&config); err != nil {
+		return nil, err
+	}
+	if err := resolveSecretFiles(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// unmarshal 按文件类型解析配置
+// YAML先解析为通用结构再转为JSON，这样配置结构只需要维护一套json标签
+func unmarshal(path string, data []byte, config *models.Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("parse yaml config failed: %w", err)
+		}
+		converted, err := json.Marshal(normalize(raw))
+		if err != nil {
+			return fmt.Errorf("parse yaml config failed: %w", err)
+		}
+		data = converted
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("parse config failed: %w", err)
+	}
+	return nil
+}
+
+// normalize 将YAML解析出的map[interface{}]interface{}等结构转换为可JSON编码的结构
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = normalize(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			converted[fmt.Sprint(k)] = normalize(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalize(item)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// applyEnv 使用环境变量覆盖配置文件中的值
+// 配置了instances时顶层的连接变量不再生效，设置了会报错，各实例使用 InstanceEnv 返回的变量
+func applyEnv(config *models.Config) error {
+	if len(config.Instances) > 0 {
+		for _, env := range []string{EnvAPIURL, EnvAPIKey, EnvVerifySSL} {
+			if _, ok, err := lookupEnv(env); err != nil {
+				return err
+			} else if ok {
+				return fmt.Errorf("%s cannot be used when instances are configured, set %s instead", env, InstanceEnv("<name>", env))
+			}
+		}
+		for i := range config.Instances {
+			instance := &config.Instances[i]
+			if err := applyEndpointEnv(instance.Name, &instance.APIURL, &instance.APIKey, &instance.VerifySSL); err != nil {
+				return err
+			}
+		}
+	} else if err := applyEndpointEnv("", &config.APIURL, &config.APIKey, &config.VerifySSL); err != nil {
+		return err
+	}
+	if value, ok, err := lookupEnv(EnvTrackerToken); err != nil {
+		return err
+	} else if ok && config.Tracker != nil {
+		config.Tracker.APIToken = value
+	}
+	return nil
+}
+
+// applyEndpointEnv 使用环境变量覆盖AWVS地址、密钥和证书校验设置，instance为空时使用顶层变量
+func applyEndpointEnv(instance string, apiURL, apiKey *string, verifySSL *bool) error {
+	name := func(env string) string {
+		if instance == "" {
+			return env
+		}
+		return InstanceEnv(instance, env)
+	}
+
+	if value, ok, err := lookupEnv(name(EnvAPIURL)); err != nil {
+		return err
+	} else if ok {
+		*apiURL = value
+	}
+	if value, ok, err := lookupEnv(name(EnvAPIKey)); err != nil {
+		return err
+	} else if ok {
+		*apiKey = value
+	}
+	if value, ok, err := lookupEnv(name(EnvVerifySSL)); err != nil {
+		return err
+	} else if ok {
+		verify, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid boolean %q", name(EnvVerifySSL), value)
+		}
+		*verifySSL = verify
+	}
+	return nil
+}
+
+// lookupEnv 读取环境变量，未设置时尝试读取 <NAME>_FILE 指向的文件
+func lookupEnv(name string) (string, bool, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true, nil
+	}
+	path, ok := os.LookupEnv(name + fileEnvSuffix)
+	if !ok || path == "" {
+		return "", false, nil
+	}
+	value, err := readSecret(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s%s: %w", name, fileEnvSuffix, err)
+	}
+	return value, true, nil
+}
+
+// resolveSecretFiles 读取配置中以 *_file 指定的密钥文件
+func resolveSecretFiles(config *models.Config) error {
+	if config.APIKey == "" && config.APIKeyFile != "" {
+		value, err := readSecret(config.APIKeyFile)
+		if err != nil {
+			return fmt.Errorf("api_key_file: %w", err)
+		}
+		config.APIKey = value
+	}
+	for i := range config.Instances {
+		instance := &config.Instances[i]
+		if instance.APIKey == "" && instance.APIKeyFile != "" {
+			value, err := readSecret(instance.APIKeyFile)
+			if err != nil {
+				return fmt.Errorf("instances[%d].api_key_file: %w", i, err)
+			}
+			instance.APIKey = value
+		}
+	}
+	if config.Tracker != nil && config.Tracker.APIToken == "" && config.Tracker.APITokenFile != "" {
+		value, err := readSecret(config.Tracker.APITokenFile)
+		if err != nil {
+			return fmt.Errorf("tracker.api_token_file: %w", err)
+		}
+		config.Tracker.APIToken = value
+	}
+	return nil
+}
+
+// readSecret 读取密钥文件内容并去掉首尾空白
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file failed: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return value, nil
+}
+
+// Validate 检查配置是否完整有效，返回所有发现的问题
+func Validate(config *models.Config) error {
+	var errs []error
+	addErr := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if len(config.Instances) == 0 {
+		validateEndpoint("", config.APIURL, config.APIKey, addErr)
+		validateConnection("", &config.ConnectionConfig, addErr)
+	} else {
+		names := make(map[string]bool)
+		envNames := make(map[string]string)
+		for i, instance := range config.Instances {
+			field := fmt.Sprintf("instances[%d].", i)
+			if instance.Name == "" {
+				addErr("%sname: is required", field)
+			} else if names[instance.Name] {
+				addErr("%sname: duplicate instance name %q", field, instance.Name)
+			}
+			names[instance.Name] = true
+			// 实例专用环境变量按名称生成，不同名称映射到同一变量时无法区分
+			if env := InstanceEnv(instance.Name, EnvAPIKey); instance.Name != "" {
+				if other, ok := envNames[env]; ok && other != instance.Name {
+					addErr("%sname: %q and %q share environment variable %s", field, other, instance.Name, env)
+				}
+				envNames[env] = instance.Name
+			}
+			validateEndpoint(field, instance.APIURL, instance.APIKey, addErr)
+			validateConnection(field, &instance.ConnectionConfig, addErr)
+		}
+	}
+
+	if _, err := awvs.ParseScope(config.Scope); err != nil {
+		addErr("scope: %v", err)
+	}
+
+	if dir := config.ReportTemplateDir; dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			addErr("report_template_dir: %s is not a directory", dir)
+		}
+	}
+	if dir := config.OutputDir; dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			addErr("output_dir: %s is not a directory", dir)
+		}
+	}
+
+	if t := config.Tracker; t != nil {
+		if err := validateURL(t.BaseURL); err != nil {
+			addErr("tracker.base_url: %v", err)
+		}
+		if t.APIToken == "" {
+			addErr("tracker.api_token: is required (or set tracker.api_token_file / %s)", EnvTrackerToken)
+		}
+		if t.Project == "" {
+			addErr("tracker.project: is required")
+		}
+	}
+
+	if n := config.Notify; n != nil {
+		if n.PollInterval < 0 {
+			addErr("notify.poll_interval: must not be negative")
+		}
+		if n.MinSeverity != "" {
+			if _, err := awvs.ParseSeverity(n.MinSeverity); err != nil {
+				addErr("notify.min_severity: %v", err)
+			}
+		}
+		for i, w := range n.Webhooks {
+			field := fmt.Sprintf("notify.webhooks[%d]", i)
+			if err := validateURL(w.URL); err != nil {
+				addErr("%s.url: %v", field, err)
+			}
+			switch w.Type {
+			case "", notify.TypeGeneric, notify.TypeSlack, notify.TypeDingTalk, notify.TypeFeishu:
+			default:
+				addErr("%s.type: unsupported type %q, must be one of generic, slack, dingtalk, feishu", field, w.Type)
+			}
+			if w.MaxRetries != nil && *w.MaxRetries < 0 {
+				addErr("%s.max_retries: must not be negative", field)
+			}
+		}
+	}
+
+	if h := config.History; h != nil && h.SyncInterval < 0 {
+		addErr("history.sync_interval: must not be negative")
+	}
+
+	if m := config.Metrics; m != nil {
+		if m.Listen == "" {
+			addErr("metrics.listen: is required")
+		}
+		if m.ScanInterval < 0 {
+			addErr("metrics.scan_interval: must not be negative")
+		}
+	}
+
+	if a := config.Audit; a != nil && a.Path == "" {
+		addErr("audit.path: is required")
+	}
+
+	if t := config.Tracing; t != nil {
+		switch t.Exporter {
+		case tracing.ExporterOTLP:
+			if t.Endpoint != "" {
+				if err := validateURL(t.Endpoint); err != nil {
+					addErr("tracing.endpoint: %v", err)
+				}
+			}
+		case tracing.ExporterStdout:
+		default:
+			addErr("tracing.exporter: unsupported exporter %q, must be one of otlp, stdout", t.Exporter)
+		}
+		if t.SampleRatio < 0 || t.SampleRatio > 1 {
+			addErr("tracing.sample_ratio: must be between 0 and 1")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateEndpoint 检查AWVS地址和密钥，field为空时表示顶层单实例配置
+func validateEndpoint(field, apiURL, apiKey string, addErr func(string, ...interface{})) {
+	if err := validateURL(apiURL); err != nil {
+		if field == "" {
+			addErr("api_url: %v (or set %s)", err, EnvAPIURL)
+		} else {
+			addErr("%sapi_url: %v", field, err)
+		}
+	}
+	if apiKey == "" {
+		if field == "" {
+			addErr("api_key: is required (or set api_key_file / %s)", EnvAPIKey)
+		} else {
+			addErr("%sapi_key: is required (or set %sapi_key_file)", field, field)
+		}
+	}
+}
+
+// validateConnection 检查TLS、代理和超时配置
+func validateConnection(field string, c *models.ConnectionConfig, addErr func(string, ...interface{})) {
+	files := []struct{ name, path string }{
+		{"ca_file", c.CAFile},
+		{"client_cert", c.ClientCert},
+		{"client_key", c.ClientKey},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			addErr("%s%s: %v", field, f.name, err)
+		}
+	}
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		addErr("%sclient_cert and %sclient_key must be set together", field, field)
+	}
+	if c.CertFingerprint != "" {
+		if _, err := awvs.ParseFingerprint(c.CertFingerprint); err != nil {
+			addErr("%scert_fingerprint: %v", field, err)
+		}
+	}
+	if c.Proxy != "" {
+		if _, err := awvs.ParseProxy(c.Proxy); err != nil {
+			addErr("%sproxy: %v", field, err)
+		}
+	}
+	if c.Timeout < 0 {
+		addErr("%stimeout: must not be negative", field)
+	}
+	if c.ConnectTimeout < 0 {
+		addErr("%sconnect_timeout: must not be negative", field)
+	}
+}
+
+// validateURL 检查地址是否为完整的http(s)地址
+func validateURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %v", raw, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q, must be an absolute http(s) url", raw)
+	}
+	return nil
+}
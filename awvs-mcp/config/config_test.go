@@ -0,0 +1,119 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/taoing/awvs-mcp/models"
+)
+
+func TestInstanceEnv(t *testing.T) {
+	tests := []struct {
+		instance, env, want string
+	}{
+		{"prod", EnvAPIKey, "AWVS_PROD_API_KEY"},
+		{"prod-scanner", EnvAPIURL, "AWVS_PROD_SCANNER_API_URL"},
+		{"eu.2", EnvVerifySSL, "AWVS_EU_2_VERIFY_SSL"},
+	}
+	for _, tt := range tests {
+		if got := InstanceEnv(tt.instance, tt.env); got != tt.want {
+			t.Errorf("InstanceEnv(%q, %q) = %q, want %q", tt.instance, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestApplyEnvInstances(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(secret, []byte("file-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWVS_PROD_API_URL", "https://prod.example.com:3443")
+	t.Setenv("AWVS_PROD_VERIFY_SSL", "true")
+	t.Setenv("AWVS_STAGING_API_KEY_FILE", secret)
+
+	config := &models.Config{Instances: []models.InstanceConfig{
+		{Name: "prod", APIURL: "https://old.example.com", APIKey: "prod-key"},
+		{Name: "staging", APIURL: "https://staging.example.com", APIKey: "old-key"},
+	}}
+	if err := applyEnv(config); err != nil {
+		t.Fatalf("applyEnv: %v", err)
+	}
+
+	prod, staging := config.Instances[0], config.Instances[1]
+	if prod.APIURL != "https://prod.example.com:3443" || !prod.VerifySSL || prod.APIKey != "prod-key" {
+		t.Errorf("prod = %+v", prod)
+	}
+	if staging.APIKey != "file-key" || staging.APIURL != "https://staging.example.com" || staging.VerifySSL {
+		t.Errorf("staging = %+v", staging)
+	}
+}
+
+func TestApplyEnvRejectsGlobalWithInstances(t *testing.T) {
+	t.Setenv(EnvAPIKey, "global-key")
+	config := &models.Config{Instances: []models.InstanceConfig{{Name: "prod"}}}
+	err := applyEnv(config)
+	if err == nil || !strings.Contains(err.Error(), EnvAPIKey) {
+		t.Fatalf("applyEnv error = %v, want error mentioning %s", err, EnvAPIKey)
+	}
+}
+
+// 同一份配置写成JSON和YAML时加载结果和校验结果一致
+func TestLoadYAMLJSONParity(t *testing.T) {
+	for _, env := range []string{EnvAPIURL, EnvAPIKey, EnvVerifySSL, EnvTrackerToken} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{
+			"instances": [{"name": "prod", "api_url": "https://prod.example.com:3443", "api_key": "key", "verify_ssl": true}],
+			"scope": ["*.example.com", "10.0.0.0/8"],
+			"tracker": {"base_url": "https://jira.example.com", "api_token": "token", "project": "SEC", "priority_map": {"high": "High", "true": "Low"}},
+			"notify": {"poll_interval": -1, "webhooks": [{"url": "https://hooks.example.com/a", "type": "slack"}]}
+		}`,
+		"config.yaml": `
+instances:
+  - name: prod
+    api_url: https://prod.example.com:3443
+    api_key: key
+    verify_ssl: true
+scope: ["*.example.com", 10.0.0.0/8]
+tracker:
+  base_url: https://jira.example.com
+  api_token: token
+  project: SEC
+  priority_map:
+    high: High
+    true: Low
+notify:
+  poll_interval: -1
+  webhooks:
+    - url: https://hooks.example.com/a
+      type: slack
+`,
+	}
+	loaded := make(map[string]*models.Config)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", name, err)
+		}
+		loaded[name] = config
+	}
+
+	fromJSON, fromYAML := loaded["config.json"], loaded["config.yaml"]
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("yaml config = %+v, want %+v", fromYAML, fromJSON)
+	}
+	jsonErr, yamlErr := Validate(fromJSON), Validate(fromYAML)
+	if jsonErr == nil || yamlErr == nil || jsonErr.Error() != yamlErr.Error() {
+		t.Errorf("yaml Validate() = %v, json Validate() = %v, want the same poll_interval error", yamlErr, jsonErr)
+	}
+}
@@ -0,0 +1,288 @@
+package diagnose
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// 检查结果状态
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// 证书或许可证即将到期的提醒阈值
+const expiryWarning = 30 * 24 * time.Hour
+
+// Check 表示单项检查结果
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Advice string `json:"advice,omitempty"` // 检查未通过时的处理建议
+}
+
+// Report 表示单个AWVS实例的诊断报告
+type Report struct {
+	Instance  string    `json:"instance"`
+	APIURL    string    `json:"api_url"`
+	Status    string    `json:"status"`
+	Checks    []Check   `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// add 添加检查结果并更新整体状态
+func (r *Report) add(check Check) {
+	r.Checks = append(r.Checks, check)
+	if rank(check.Status) > rank(r.Status) {
+		r.Status = check.Status
+	}
+}
+
+func rank(status string) int {
+	switch status {
+	case StatusFail:
+		return 2
+	case StatusWarn:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Run 依次检查连通性、证书、API密钥、版本、许可证、扫描引擎和扫描负载
+func Run(instance string, client *awvs.Client) *Report {
+	config := client.Config()
+	report := &Report{
+		Instance:  instance,
+		APIURL:    config.APIURL,
+		Status:    StatusOK,
+		CheckedAt: time.Now(),
+	}
+
+	state, err := client.ProbeConnection()
+	if err != nil {
+		report.add(Check{
+			Name:   "connectivity",
+			Status: StatusFail,
+			Detail: err.Error(),
+			Advice: "检查 api_url 是否正确（只需填写到端口，如 https://awvs:3443），以及网络、防火墙和代理（proxy）设置",
+		})
+		for _, name := range []string{"tls", "api_key", "version", "license", "engines", "scan_load"} {
+			report.add(Check{Name: name, Status: StatusSkip, Detail: "AWVS不可达，跳过"})
+		}
+		return report
+	}
+	report.add(Check{
+		Name:   "connectivity",
+		Status: StatusOK,
+		Detail: fmt.Sprintf("HTTP %d，耗时 %s", state.StatusCode, state.Latency.Round(time.Millisecond)),
+	})
+
+	tlsCheck := checkTLS(config, state.TLS)
+	report.add(tlsCheck)
+	if tlsCheck.Status == StatusFail {
+		for _, name := range []string{"api_key", "version", "license", "engines", "scan_load"} {
+			report.add(Check{Name: name, Status: StatusSkip, Detail: "证书校验未通过，跳过"})
+		}
+		return report
+	}
+
+	targetCount, err := client.CountTargets()
+	if err != nil {
+		report.add(apiKeyFailure(err))
+		for _, name := range []string{"version", "license", "engines", "scan_load"} {
+			report.add(Check{Name: name, Status: StatusSkip, Detail: "API密钥校验未通过，跳过"})
+		}
+		return report
+	}
+	report.add(Check{Name: "api_key", Status: StatusOK, Detail: fmt.Sprintf("API密钥有效，当前目标 %d 个", targetCount)})
+
+	info, err := client.GetInfo()
+	if err != nil {
+		report.add(Check{Name: "version", Status: StatusWarn, Detail: err.Error(), Advice: "无法获取版本信息，部分功能可能与该AWVS版本不兼容"})
+		report.add(Check{Name: "license", Status: StatusSkip, Detail: "无法获取许可证信息"})
+	} else {
+		report.add(Check{Name: "version", Status: StatusOK, Detail: "AWVS " + info.Version()})
+		report.add(checkLicense(info.License, targetCount))
+	}
+
+	report.add(checkEngines(client))
+	report.add(checkScanLoad(client, info))
+
+	return report
+}
+
+// checkTLS 检查证书是否可信及到期时间
+func checkTLS(config awvs.Config, state *awvs.TLSState) Check {
+	check := Check{Name: "tls"}
+	if state == nil {
+		check.Status = StatusWarn
+		check.Detail = "未使用HTTPS，API密钥以明文传输"
+		check.Advice = "将 api_url 改为 https 地址"
+		return check
+	}
+
+	expiry := fmt.Sprintf("%s，证书 %s 由 %s 签发，%s 到期，SHA-256指纹 %s",
+		state.Version, state.Subject, state.Issuer, state.NotAfter.Format("2006-01-02"), state.Fingerprint)
+
+	switch {
+	case state.PinMatched != nil && !*state.PinMatched:
+		check.Status = StatusFail
+		check.Detail = "服务端证书与 cert_fingerprint 不一致：" + expiry
+		check.Advice = "确认AWVS证书是否已更换，如已更换请更新 cert_fingerprint"
+	case state.Verified:
+		check.Status = StatusOK
+		check.Detail = "证书校验通过：" + expiry
+	case state.PinMatched != nil:
+		check.Status = StatusOK
+		check.Detail = "证书与 cert_fingerprint 一致：" + expiry
+	case config.CAFile != "":
+		// 配置了ca_file时客户端总是校验证书，与verify_ssl无关
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("证书未由 ca_file 中的CA签发（%s）：%s", state.VerifyError, expiry)
+		check.Advice = "确认 ca_file 是否为签发AWVS证书的CA；自签名证书可配置 cert_fingerprint"
+	case config.VerifySSL:
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("证书校验失败（%s）：%s", state.VerifyError, expiry)
+		check.Advice = "如使用内部CA，请通过 ca_file 指定CA证书；自签名证书可配置 cert_fingerprint"
+	default:
+		check.Status = StatusWarn
+		check.Detail = fmt.Sprintf("verify_ssl 已关闭，证书未通过校验（%s）：%s", state.VerifyError, expiry)
+		check.Advice = "配置 ca_file 或 cert_fingerprint 后开启 verify_ssl"
+	}
+
+	if check.Status == StatusOK && time.Until(state.NotAfter) < expiryWarning {
+		check.Status = StatusWarn
+		check.Advice = "证书即将到期或已过期，请及时更换AWVS证书"
+	}
+	return check
+}
+
+// apiKeyFailure 根据请求错误给出API密钥检查结果
+func apiKeyFailure(err error) Check {
+	check := Check{Name: "api_key", Status: StatusFail, Detail: err.Error()}
+	var apiErr *awvs.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			check.Detail = fmt.Sprintf("API密钥无效或已过期（HTTP %d）", apiErr.StatusCode)
+			check.Advice = "在AWVS的 Profile 页面重新生成API Key，并更新 api_key 或 AWVS_API_KEY"
+			return check
+		case http.StatusNotFound:
+			check.Advice = "接口不存在，请确认 api_url 不包含 /api/v1 后缀"
+			return check
+		}
+	}
+	check.Advice = "检查AWVS服务状态和日志"
+	return check
+}
+
+// checkLicense 检查许可证是否过期及剩余目标数
+func checkLicense(license awvs.License, targetCount int) Check {
+	check := Check{Name: "license", Status: StatusOK}
+
+	var parts []string
+	if license.ProductCode != "" {
+		parts = append(parts, license.ProductCode)
+	}
+	if license.Expires != "" {
+		parts = append(parts, "有效期至 "+license.Expires)
+	}
+	if license.MaxTargets > 0 {
+		parts = append(parts, fmt.Sprintf("剩余目标 %d/%d", license.MaxTargets-targetCount, license.MaxTargets))
+	} else {
+		parts = append(parts, "目标数不限")
+	}
+	if license.MaxConcurrentScans > 0 {
+		parts = append(parts, fmt.Sprintf("最大并发扫描 %d", license.MaxConcurrentScans))
+	}
+	check.Detail = strings.Join(parts, "，")
+
+	switch {
+	case license.Expired:
+		check.Status = StatusFail
+		check.Advice = "许可证已过期，请联系管理员续期"
+	case license.MaxTargets > 0 && targetCount >= license.MaxTargets:
+		check.Status = StatusFail
+		check.Advice = "目标数已达许可证上限，删除不再需要的目标后才能添加新目标"
+	default:
+		if expires, err := time.Parse(time.RFC3339, license.Expires); err == nil && time.Until(expires) < expiryWarning {
+			check.Status = StatusWarn
+			check.Advice = "许可证即将到期，请联系管理员续期"
+		}
+	}
+	return check
+}
+
+// checkEngines 检查扫描引擎状态
+func checkEngines(client *awvs.Client) Check {
+	check := Check{Name: "engines", Status: StatusOK}
+
+	workers, err := client.ListWorkers()
+	if err != nil {
+		check.Status = StatusWarn
+		check.Detail = err.Error()
+		check.Advice = "无法获取扫描引擎列表，当前API密钥可能没有管理员权限"
+		return check
+	}
+	if len(workers) == 0 {
+		check.Detail = "使用内置扫描引擎"
+		return check
+	}
+
+	var offline []string
+	for _, w := range workers {
+		if w.Status != "online" {
+			offline = append(offline, fmt.Sprintf("%s(%s)", w.Endpoint, w.Status))
+		}
+	}
+	check.Detail = fmt.Sprintf("扫描引擎 %d 个，在线 %d 个", len(workers), len(workers)-len(offline))
+	if len(offline) > 0 {
+		check.Status = StatusWarn
+		check.Detail += "，离线: " + strings.Join(offline, ", ")
+		check.Advice = "检查离线扫描引擎的服务状态和网络连接"
+		if len(offline) == len(workers) {
+			check.Status = StatusFail
+			check.Advice = "没有可用的扫描引擎，扫描将一直排队"
+		}
+	}
+	return check
+}
+
+// checkScanLoad 检查当前扫描负载
+func checkScanLoad(client *awvs.Client, info *awvs.Info) Check {
+	check := Check{Name: "scan_load", Status: StatusOK}
+
+	active, err := client.ActiveScans()
+	if err != nil {
+		check.Status = StatusWarn
+		check.Detail = err.Error()
+		return check
+	}
+	check.Detail = fmt.Sprintf("排队和运行中的扫描 %d 个", active)
+	if info != nil && info.License.MaxConcurrentScans > 0 && active >= info.License.MaxConcurrentScans {
+		check.Status = StatusWarn
+		check.Advice = "并发扫描已满，新扫描将排队等待"
+	}
+	return check
+}
+
+// WriteText 以适合终端阅读的格式输出报告
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "[%s] %s  %s\n", strings.ToUpper(r.Status), r.Instance, r.APIURL)
+	for _, check := range r.Checks {
+		fmt.Fprintf(w, "  %-5s %-13s %s\n", strings.ToUpper(check.Status), check.Name, check.Detail)
+		if check.Advice != "" {
+			fmt.Fprintf(w, "        %-13s 建议: %s\n", "", check.Advice)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package diagnose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+const testAPIKey = "valid-key"
+
+// newFakeAWVS 模拟AWVS的信息、目标、扫描和扫描引擎接口，API密钥不正确时返回401
+func newFakeAWVS(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth") != testAPIKey {
+			http.Error(w, `{"code":401,"message":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		var resp interface{}
+		switch r.URL.Path {
+		case "/api/v1/info":
+			resp = map[string]interface{}{
+				"major_version": 24,
+				"minor_version": "1",
+				"license":       map[string]interface{}{"product_code": "WVSC", "max_targets": 10},
+			}
+		case "/api/v1/targets":
+			resp = map[string]interface{}{
+				"targets":    []awvs.Target{{TargetID: "t1"}},
+				"pagination": awvs.Pagination{Count: 3},
+			}
+		case "/api/v1/scans":
+			resp = map[string]interface{}{"scans": []awvs.Scan{}}
+		case "/api/v1/workers":
+			resp = map[string]interface{}{"workers": []awvs.Worker{}}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeCA 将测试服务器证书写入CA文件
+func writeCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile
+}
+
+// writeOtherCA 生成一个未签发测试服务器证书的CA并写入CA文件
+// httptest的所有TLS服务器共用同一张证书，不能用另一个测试服务器代替
+func writeOtherCA(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Other CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "other-ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile
+}
+
+func runDiagnose(t *testing.T, config *awvs.Config) *Report {
+	t.Helper()
+	client, err := awvs.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Run("main", client)
+}
+
+func statuses(report *Report) map[string]string {
+	result := make(map[string]string)
+	for _, check := range report.Checks {
+		result[check.Name] = check.Status
+	}
+	return result
+}
+
+func TestRunHealthy(t *testing.T) {
+	server := newFakeAWVS(t)
+	report := runDiagnose(t, &awvs.Config{APIURL: server.URL, APIKey: testAPIKey, VerifySSL: true, CAFile: writeCA(t, server)})
+
+	if report.Status != StatusOK {
+		t.Errorf("Status = %s, want ok: %+v", report.Status, report.Checks)
+	}
+	want := map[string]string{
+		"connectivity": StatusOK,
+		"tls":          StatusOK,
+		"api_key":      StatusOK,
+		"version":      StatusOK,
+		"license":      StatusOK,
+		"engines":      StatusOK,
+		"scan_load":    StatusOK,
+	}
+	got := statuses(report)
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s = %q, want %q", name, got[name], status)
+		}
+	}
+	for _, check := range report.Checks {
+		if check.Name == "license" && !strings.Contains(check.Detail, "剩余目标 7/10") {
+			t.Errorf("license detail = %q, want remaining targets 7/10", check.Detail)
+		}
+	}
+}
+
+// API密钥错误时给出重新生成密钥的建议，并跳过依赖密钥的检查
+func TestRunBadAPIKey(t *testing.T) {
+	server := newFakeAWVS(t)
+	report := runDiagnose(t, &awvs.Config{APIURL: server.URL, APIKey: "wrong", VerifySSL: true, CAFile: writeCA(t, server)})
+
+	if report.Status != StatusFail {
+		t.Errorf("Status = %s, want fail", report.Status)
+	}
+	got := statuses(report)
+	if got["connectivity"] != StatusOK || got["tls"] != StatusOK {
+		t.Errorf("connectivity = %q, tls = %q, want both ok", got["connectivity"], got["tls"])
+	}
+	if got["api_key"] != StatusFail {
+		t.Errorf("api_key = %q, want fail", got["api_key"])
+	}
+	for _, name := range []string{"version", "license", "engines", "scan_load"} {
+		if got[name] != StatusSkip {
+			t.Errorf("%s = %q, want skip", name, got[name])
+		}
+	}
+	for _, check := range report.Checks {
+		if check.Name == "api_key" && !strings.Contains(check.Detail, "HTTP 401") {
+			t.Errorf("api_key detail = %q, want HTTP 401", check.Detail)
+		}
+	}
+}
+
+// 证书不受信任时报告校验失败原因，并跳过后续检查
+func TestRunUntrustedCertificate(t *testing.T) {
+	server := newFakeAWVS(t)
+	report := runDiagnose(t, &awvs.Config{APIURL: server.URL, APIKey: testAPIKey, VerifySSL: true})
+
+	if report.Status != StatusFail {
+		t.Errorf("Status = %s, want fail", report.Status)
+	}
+	got := statuses(report)
+	if got["connectivity"] != StatusOK || got["tls"] != StatusFail {
+		t.Errorf("connectivity = %q, tls = %q, want ok and fail", got["connectivity"], got["tls"])
+	}
+	for _, name := range []string{"api_key", "version", "license", "engines", "scan_load"} {
+		if got[name] != StatusSkip {
+			t.Errorf("%s = %q, want skip", name, got[name])
+		}
+	}
+
+	// 关闭verify_ssl时只给出警告
+	report = runDiagnose(t, &awvs.Config{APIURL: server.URL, APIKey: testAPIKey})
+	if got := statuses(report); got["tls"] != StatusWarn || got["api_key"] != StatusOK {
+		t.Errorf("without verify_ssl: tls = %q, api_key = %q, want warn and ok", got["tls"], got["api_key"])
+	}
+}
+
+// 配置了ca_file时总是校验证书，即使verify_ssl关闭，CA不信任证书也是失败而不是警告
+func TestRunCAFileWithoutVerifySSL(t *testing.T) {
+	server := newFakeAWVS(t)
+	report := runDiagnose(t, &awvs.Config{APIURL: server.URL, APIKey: testAPIKey, CAFile: writeOtherCA(t)})
+
+	if report.Status != StatusFail {
+		t.Errorf("Status = %s, want fail", report.Status)
+	}
+	got := statuses(report)
+	if got["tls"] != StatusFail {
+		t.Errorf("tls = %q, want fail", got["tls"])
+	}
+	for _, name := range []string{"api_key", "version", "license", "engines", "scan_load"} {
+		if got[name] != StatusSkip {
+			t.Errorf("%s = %q, want skip", name, got[name])
+		}
+	}
+
+	// CA信任证书时同样通过
+	report = runDiagnose(t, &awvs.Config{APIURL: server.URL, APIKey: testAPIKey, CAFile: writeCA(t, server)})
+	if got := statuses(report); got["tls"] != StatusOK || got["api_key"] != StatusOK {
+		t.Errorf("trusted ca_file: tls = %q, api_key = %q, want both ok", got["tls"], got["api_key"])
+	}
+}
+
+func TestRunUnreachable(t *testing.T) {
+	server := newFakeAWVS(t)
+	url := server.URL
+	server.Close()
+
+	report := runDiagnose(t, &awvs.Config{APIURL: url, APIKey: testAPIKey})
+	got := statuses(report)
+	if report.Status != StatusFail || got["connectivity"] != StatusFail || got["api_key"] != StatusSkip {
+		t.Errorf("Status = %s, checks = %v, want connectivity fail and the rest skipped", report.Status, got)
+	}
+}
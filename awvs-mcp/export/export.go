@@ -0,0 +1,284 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// 导出数据类型常量
+const (
+	KindTargets         = "targets"
+	KindScans           = "scans"
+	KindVulnerabilities = "vulnerabilities"
+)
+
+// 导出格式常量
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// column 表示一个可导出的列
+type column[T any] struct {
+	name  string
+	value func(T) interface{}
+}
+
+var targetColumns = []column[awvs.Target]{
+	{"target_id", func(t awvs.Target) interface{} { return t.TargetID }},
+	{"address", func(t awvs.Target) interface{} { return t.Address }},
+	{"criticity", func(t awvs.Target) interface{} { return t.Criticity }},
+	{"status", func(t awvs.Target) interface{} { return t.Status }},
+}
+
+var scanColumns = []column[awvs.Scan]{
+	{"scan_id", func(s awvs.Scan) interface{} { return s.ScanID }},
+	{"target_id", func(s awvs.Scan) interface{} { return s.TargetID }},
+	{"scan_type", func(s awvs.Scan) interface{} { return s.ScanType }},
+	{"profile_id", func(s awvs.Scan) interface{} { return s.ProfileID }},
+	{"status", func(s awvs.Scan) interface{} { return s.State() }},
+	{"progress", func(s awvs.Scan) interface{} { return s.Progress }},
+	{"critical", func(s awvs.Scan) interface{} { return s.Counts().Critical }},
+	{"high", func(s awvs.Scan) interface{} { return s.Counts().High }},
+	{"medium", func(s awvs.Scan) interface{} { return s.Counts().Medium }},
+	{"low", func(s awvs.Scan) interface{} { return s.Counts().Low }},
+	{"info", func(s awvs.Scan) interface{} { return s.Counts().Info }},
+}
+
+var vulnerabilityColumns = []column[awvs.Vulnerability]{
+	{"vuln_id", func(v awvs.Vulnerability) interface{} { return v.VulnID }},
+	{"target_id", func(v awvs.Vulnerability) interface{} { return v.TargetID }},
+	{"vt_id", func(v awvs.Vulnerability) interface{} { return v.VtID }},
+	{"vt_name", func(v awvs.Vulnerability) interface{} { return v.VtName }},
+	{"severity", func(v awvs.Vulnerability) interface{} { return v.Severity }},
+	{"severity_name", func(v awvs.Vulnerability) interface{} { return awvs.SeverityName(v.Severity) }},
+	{"confidence", func(v awvs.Vulnerability) interface{} { return v.Confidence }},
+	{"criticality", func(v awvs.Vulnerability) interface{} { return v.Criticality }},
+	{"affects_url", func(v awvs.Vulnerability) interface{} { return v.AffectsURL }},
+	{"affects_detail", func(v awvs.Vulnerability) interface{} { return v.AffectsDetail }},
+	{"status", func(v awvs.Vulnerability) interface{} { return v.Status }},
+	{"last_seen", func(v awvs.Vulnerability) interface{} { return v.LastSeen }},
+	{"tags", func(v awvs.Vulnerability) interface{} { return strings.Join(v.Tags, ";") }},
+}
+
+// Options 导出选项
+type Options struct {
+	Kind    string            // 导出的数据类型
+	Format  string            // 导出格式，csv或ndjson
+	Columns []string          // 导出的列，为空时导出全部列
+	Filters map[string]string // 按列过滤，值可用逗号分隔多个候选值
+	Query   string            // 透传给AWVS的过滤表达式（q参数）
+}
+
+// Columns 返回指定数据类型支持的全部列名
+func Columns(kind string) ([]string, error) {
+	switch kind {
+	case KindTargets:
+		return columnNames(targetColumns), nil
+	case KindScans:
+		return columnNames(scanColumns), nil
+	case KindVulnerabilities:
+		return columnNames(vulnerabilityColumns), nil
+	default:
+		return nil, fmt.Errorf("unsupported export kind: %s", kind)
+	}
+}
+
+// Export 将AWVS数据逐页写入w，返回导出的记录数
+func Export(client *awvs.Client, w io.Writer, opts Options) (int, error) {
+	switch opts.Kind {
+	case KindTargets:
+		return run(w, opts, targetColumns, client.WalkTargets)
+	case KindScans:
+		return run(w, opts, scanColumns, client.WalkScans)
+	case KindVulnerabilities:
+		return run(w, opts, vulnerabilityColumns, client.WalkVulnerabilities)
+	default:
+		return 0, fmt.Errorf("unsupported export kind: %s", opts.Kind)
+	}
+}
+
+// run 按列定义导出一种数据类型
+func run[T any](w io.Writer, opts Options, all []column[T], walk func(string, func([]T) error) error) (int, error) {
+	selected, err := selectColumns(all, opts.Columns)
+	if err != nil {
+		return 0, err
+	}
+
+	filters, err := parseFilters(all, opts.Filters)
+	if err != nil {
+		return 0, err
+	}
+
+	writer, err := newRowWriter(w, opts.Format, columnNames(selected))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = walk(opts.Query, func(page []T) error {
+		for _, item := range page {
+			if !matches(item, filters) {
+				continue
+			}
+			values := make([]interface{}, len(selected))
+			for i, col := range selected {
+				values[i] = col.value(item)
+			}
+			if err := writer.write(values); err != nil {
+				return fmt.Errorf("write record failed: %w", err)
+			}
+			count++
+		}
+		// 每页写完后刷新，保证大数据量时边拉取边输出
+		return writer.flush()
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, writer.flush()
+}
+
+func columnNames[T any](cols []column[T]) []string {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.name
+	}
+	return names
+}
+
+func findColumn[T any](cols []column[T], name string) (column[T], bool) {
+	for _, col := range cols {
+		if col.name == name {
+			return col, true
+		}
+	}
+	return column[T]{}, false
+}
+
+// selectColumns 根据列名选择要导出的列，保持用户指定的顺序
+func selectColumns[T any](all []column[T], names []string) ([]column[T], error) {
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	selected := make([]column[T], 0, len(names))
+	for _, name := range names {
+		col, ok := findColumn(all, strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, available columns: %s", name, strings.Join(columnNames(all), ","))
+		}
+		selected = append(selected, col)
+	}
+	return selected, nil
+}
+
+// filter 表示一个列过滤条件
+type filter[T any] struct {
+	col    column[T]
+	values map[string]bool
+}
+
+func parseFilters[T any](all []column[T], filters map[string]string) ([]filter[T], error) {
+	// 按列名排序，保证错误信息稳定
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]filter[T], 0, len(filters))
+	for _, name := range names {
+		col, ok := findColumn(all, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown filter column %q", name)
+		}
+		values := make(map[string]bool)
+		for _, v := range strings.Split(filters[name], ",") {
+			values[strings.TrimSpace(v)] = true
+		}
+		result = append(result, filter[T]{col: col, values: values})
+	}
+	return result, nil
+}
+
+func matches[T any](item T, filters []filter[T]) bool {
+	for _, f := range filters {
+		if !f.values[fmt.Sprint(f.col.value(item))] {
+			return false
+		}
+	}
+	return true
+}
+
+// rowWriter 按格式写出记录
+type rowWriter struct {
+	format  string
+	columns []string
+	csv     *csv.Writer
+	out     io.Writer
+}
+
+func newRowWriter(w io.Writer, format string, columns []string) (*rowWriter, error) {
+	switch format {
+	case FormatCSV, "":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return nil, fmt.Errorf("write csv header failed: %w", err)
+		}
+		return &rowWriter{format: FormatCSV, columns: columns, csv: cw}, nil
+	case FormatNDJSON, "jsonl":
+		return &rowWriter{format: FormatNDJSON, columns: columns, out: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+func (rw *rowWriter) write(values []interface{}) error {
+	if rw.format == FormatCSV {
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = fmt.Sprint(v)
+		}
+		return rw.csv.Write(record)
+	}
+
+	// 逐列拼接JSON对象，保持用户指定的列顺序（map会被encoding/json按键排序）
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(rw.columns[i])
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+	_, err := rw.out.Write(buf.Bytes())
+	return err
+}
+
+func (rw *rowWriter) flush() error {
+	if rw.csv != nil {
+		rw.csv.Flush()
+		return rw.csv.Error()
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package export
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// fakeAWVS 模拟AWVS漏洞列表接口，每页返回一条记录
+type fakeAWVS struct {
+	vulns []awvs.Vulnerability
+	out   *syncBuffer
+	seen  []string // 请求每一页时输出中已有的内容
+}
+
+func (a *fakeAWVS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/v1/vulnerabilities" {
+		http.NotFound(w, r)
+		return
+	}
+	a.seen = append(a.seen, a.out.String())
+
+	page := 0
+	if c := r.URL.Query().Get("c"); c != "" {
+		page = int(c[0] - '0')
+	}
+	resp := map[string]interface{}{"vulnerabilities": a.vulns[page : page+1]}
+	if page+1 < len(a.vulns) {
+		resp["pagination"] = awvs.Pagination{NextCursor: string(rune('0' + page + 1))}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// syncBuffer 供测试服务器和导出并发读写的缓冲区
+type syncBuffer struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.String()
+}
+
+func newTestClient(t *testing.T) (*awvs.Client, *fakeAWVS) {
+	t.Helper()
+
+	fake := &fakeAWVS{
+		out: &syncBuffer{},
+		vulns: []awvs.Vulnerability{
+			{VulnID: "v1", TargetID: "t1", VtName: "XSS", Severity: awvs.SeverityMedium, Status: "open"},
+			{VulnID: "v2", TargetID: "t1", VtName: "SQL Injection", Severity: awvs.SeverityHigh, Status: "fixed"},
+			{VulnID: "v3", TargetID: "t2", VtName: "CSRF", Severity: awvs.SeverityLow, Status: "open"},
+		},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := awvs.NewClient(&awvs.Config{Name: "test", APIURL: server.URL, APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, fake
+}
+
+// NDJSON按用户指定的列顺序输出字段
+func TestExportNDJSONColumnOrder(t *testing.T) {
+	client, fake := newTestClient(t)
+
+	count, err := Export(client, fake.out, Options{
+		Kind:    KindVulnerabilities,
+		Format:  FormatNDJSON,
+		Columns: []string{"vt_name", "severity", "vuln_id"},
+	})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+
+	lines := strings.Split(strings.TrimSpace(fake.out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), fake.out.String())
+	}
+	if want := `{"vt_name":"XSS","severity":2,"vuln_id":"v1"}`; lines[0] != want {
+		t.Errorf("line = %s, want %s", lines[0], want)
+	}
+}
+
+// 每页写完后立即输出，不等全部数据拉取完毕
+func TestExportStreamsPages(t *testing.T) {
+	client, fake := newTestClient(t)
+
+	if _, err := Export(client, fake.out, Options{Kind: KindVulnerabilities, Format: FormatCSV, Columns: []string{"vuln_id"}}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	if len(fake.seen) != 3 {
+		t.Fatalf("fetched %d pages, want 3", len(fake.seen))
+	}
+	if want := "vuln_id\nv1\n"; fake.seen[1] != want {
+		t.Errorf("output before second page = %q, want %q", fake.seen[1], want)
+	}
+	if want := "vuln_id\nv1\nv2\nv3\n"; fake.out.String() != want {
+		t.Errorf("output = %q, want %q", fake.out.String(), want)
+	}
+}
+
+// 扫描导出包含严重级别的数量，与漏洞趋势的统计口径一致
+func TestExportScanSeverityCounts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"scans":[{"scan_id":"s1","current_session":{"status":"completed",` +
+			`"severity_counts":{"critical":1,"high":2,"medium":3,"low":4,"info":5}}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	client, err := awvs.NewClient(&awvs.Config{Name: "test", APIURL: server.URL, APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if _, err := Export(client, &out, Options{Kind: KindScans, Format: FormatCSV, Columns: []string{"scan_id", "critical", "high", "info"}}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if want := "scan_id,critical,high,info\ns1,1,2,5\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+// 列过滤支持逗号分隔的多个候选值，多个过滤条件同时满足
+func TestExportFilters(t *testing.T) {
+	client, fake := newTestClient(t)
+
+	count, err := Export(client, fake.out, Options{
+		Kind:    KindVulnerabilities,
+		Format:  FormatCSV,
+		Columns: []string{"vuln_id", "severity_name"},
+		Filters: map[string]string{"status": "open", "severity_name": "medium, high"},
+	})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+	if want := "vuln_id,severity_name\nv1,medium\n"; fake.out.String() != want {
+		t.Errorf("output = %q, want %q", fake.out.String(), want)
+	}
+}
+
+func TestExportInvalidOptions(t *testing.T) {
+	client, fake := newTestClient(t)
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"unknown kind", Options{Kind: "users"}},
+		{"unknown format", Options{Kind: KindVulnerabilities, Format: "xml"}},
+		{"unknown column", Options{Kind: KindVulnerabilities, Columns: []string{"password"}}},
+		{"unknown filter", Options{Kind: KindVulnerabilities, Filters: map[string]string{"password": "x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Export(client, fake.out, tt.opts); err == nil {
+				t.Error("Export succeeded, want error")
+			}
+		})
+	}
+	if len(fake.seen) != 0 {
+		t.Errorf("invalid options fetched %d pages, want 0", len(fake.seen))
+	}
+}
@@ -0,0 +1,536 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+	bolt "go.etcd.io/bbolt"
+)
+
+// 数据类型常量，同时用作快照的类型标识
+const (
+	KindTarget        = "target"
+	KindScan          = "scan"
+	KindVulnerability = "vulnerability"
+)
+
+// StatusDeleted 表示对象已通过服务器删除，删除后的漏洞不再计为未修复
+const StatusDeleted = "deleted"
+
+// 存储桶名称
+var (
+	bucketTargets   = []byte("targets")
+	bucketScans     = []byte("scans")
+	bucketVulns     = []byte("vulnerabilities")
+	bucketSnapshots = []byte("snapshots")
+	bucketLastState = []byte("last_state") // 每个对象最后一次快照的内容，用于判断是否变化
+)
+
+// TargetRecord 表示本地记录的扫描目标
+type TargetRecord struct {
+	awvs.Target
+	Instance  string     `json:"instance,omitempty"`
+	FirstSeen time.Time  `json:"first_seen"`
+	LastSeen  time.Time  `json:"last_seen"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ScanRecord 表示本地记录的扫描任务
+type ScanRecord struct {
+	Instance  string        `json:"instance,omitempty"`
+	ScanID    string        `json:"scan_id"`
+	TargetID  string        `json:"target_id"`
+	Address   string        `json:"address"`
+	ProfileID string        `json:"profile_id"`
+	Status    string        `json:"status"`
+	StartDate string        `json:"start_date"`
+	Severity  awvs.Severity `json:"severity"`
+	FirstSeen time.Time     `json:"first_seen"`
+	LastSeen  time.Time     `json:"last_seen"`
+}
+
+// VulnerabilityRecord 表示本地记录的漏洞
+type VulnerabilityRecord struct {
+	awvs.Vulnerability
+	Instance  string    `json:"instance,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Snapshot 表示某一时刻观察到的对象状态
+type Snapshot struct {
+	Time time.Time       `json:"time"`
+	Kind string          `json:"kind"`
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Store 基于bbolt的本地历史库，记录服务器见过的所有目标、扫描和漏洞
+type Store struct {
+	db *bolt.DB
+}
+
+// Open 打开或创建历史库
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create history dir failed: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open history db failed: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketTargets, bucketScans, bucketVulns, bucketSnapshots, bucketLastState} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init history db failed: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close 关闭历史库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Observer 返回记录指定实例数据的观察者
+func (s *Store) Observer(instance string) awvs.Observer {
+	return &instanceObserver{store: s, instance: instance}
+}
+
+// instanceObserver 实现awvs.Observer，将客户端见过的数据记录到历史库
+type instanceObserver struct {
+	store    *Store
+	instance string
+}
+
+func (o *instanceObserver) ObserveTargets(targets []awvs.Target) {
+	if err := o.store.RecordTargets(o.instance, targets, time.Now()); err != nil {
+		log.Printf("记录目标历史失败: %v", err)
+	}
+}
+
+func (o *instanceObserver) ObserveScans(scans []awvs.Scan) {
+	if err := o.store.RecordScans(o.instance, scans, time.Now()); err != nil {
+		log.Printf("记录扫描历史失败: %v", err)
+	}
+}
+
+func (o *instanceObserver) ObserveVulnerabilities(vulns []awvs.Vulnerability) {
+	if err := o.store.RecordVulnerabilities(o.instance, vulns, time.Now()); err != nil {
+		log.Printf("记录漏洞历史失败: %v", err)
+	}
+}
+
+func (o *instanceObserver) ObserveTargetsDeleted(targetIDs []string) {
+	if err := o.store.RecordTargetsDeleted(o.instance, targetIDs, time.Now()); err != nil {
+		log.Printf("记录目标删除失败: %v", err)
+	}
+}
+
+func (o *instanceObserver) ObserveScansDeleted(scanIDs []string) {
+	if err := o.store.RecordScansDeleted(o.instance, scanIDs, time.Now()); err != nil {
+		log.Printf("记录扫描删除失败: %v", err)
+	}
+}
+
+// RecordTargets 记录目标，内容变化时追加快照
+func (s *Store) RecordTargets(instance string, targets []awvs.Target, now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, t := range targets {
+			if t.TargetID == "" {
+				continue
+			}
+			record := TargetRecord{Target: t, Instance: instance, FirstSeen: now, LastSeen: now}
+			var previous TargetRecord
+			if found, err := get(tx, bucketTargets, t.TargetID, &previous); err != nil {
+				return err
+			} else if found {
+				record.FirstSeen = previous.FirstSeen
+				// 列表接口可能缺少部分字段，保留已知的地址
+				if record.Address == "" {
+					record.Address = previous.Address
+				}
+			}
+			if err := upsert(tx, bucketTargets, KindTarget, t.TargetID, record, t, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordScans 记录扫描任务，状态或统计变化时追加快照
+func (s *Store) RecordScans(instance string, scans []awvs.Scan, now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, scan := range scans {
+			if scan.ScanID == "" {
+				continue
+			}
+			record := ScanRecord{
+				Instance:  instance,
+				ScanID:    scan.ScanID,
+				TargetID:  scan.TargetID,
+				Address:   scan.Target.Address,
+				ProfileID: scan.ProfileID,
+				Status:    scan.State(),
+				StartDate: scan.CurrentSession.StartDate,
+				Severity:  scan.Counts(),
+				FirstSeen: now,
+				LastSeen:  now,
+			}
+			var previous ScanRecord
+			if found, err := get(tx, bucketScans, scan.ScanID, &previous); err != nil {
+				return err
+			} else if found {
+				record.FirstSeen = previous.FirstSeen
+				if record.Address == "" {
+					record.Address = previous.Address
+				}
+				if record.StartDate == "" {
+					record.StartDate = previous.StartDate
+				}
+			}
+			if record.Address == "" {
+				var target TargetRecord
+				if _, err := get(tx, bucketTargets, scan.TargetID, &target); err != nil {
+					return err
+				}
+				record.Address = target.Address
+			}
+
+			if err := upsert(tx, bucketScans, KindScan, scan.ScanID, record, record.state(), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordVulnerabilities 记录漏洞，状态变化时追加快照
+func (s *Store) RecordVulnerabilities(instance string, vulns []awvs.Vulnerability, now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, v := range vulns {
+			if v.VulnID == "" {
+				continue
+			}
+			record := VulnerabilityRecord{Vulnerability: v, Instance: instance, FirstSeen: now, LastSeen: now}
+			var previous VulnerabilityRecord
+			if found, err := get(tx, bucketVulns, v.VulnID, &previous); err != nil {
+				return err
+			} else if found {
+				record.FirstSeen = previous.FirstSeen
+			}
+
+			if err := upsert(tx, bucketVulns, KindVulnerability, v.VulnID, record, record.state(), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordTargetsDeleted 记录通过服务器删除的目标，目标下的扫描和未修复漏洞随之记为已删除，
+// 否则这些漏洞不会再出现在AWVS中，也就永远不会有关闭快照
+func (s *Store) RecordTargetsDeleted(instance string, targetIDs []string, now time.Time) error {
+	deleted := make(map[string]bool, len(targetIDs))
+	for _, id := range targetIDs {
+		deleted[id] = true
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for id := range deleted {
+			var record TargetRecord
+			if found, err := get(tx, bucketTargets, id, &record); err != nil {
+				return err
+			} else if !found || record.Instance != instance {
+				continue
+			}
+			record.DeletedAt = &now
+			if err := put(tx, bucketTargets, id, record); err != nil {
+				return err
+			}
+		}
+
+		// 先收集再写入，避免遍历存储桶时修改其内容
+		var scans []ScanRecord
+		err := tx.Bucket(bucketScans).ForEach(func(k, v []byte) error {
+			var record ScanRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if record.Instance == instance && deleted[record.TargetID] && record.Status != StatusDeleted {
+				scans = append(scans, record)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, record := range scans {
+			record.Status = StatusDeleted
+			if err := upsert(tx, bucketScans, KindScan, record.ScanID, record, record.state(), now); err != nil {
+				return err
+			}
+		}
+
+		var vulns []VulnerabilityRecord
+		err = tx.Bucket(bucketVulns).ForEach(func(k, v []byte) error {
+			var record VulnerabilityRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if record.Instance == instance && deleted[record.TargetID] && isOpenStatus(record.Status) {
+				vulns = append(vulns, record)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, record := range vulns {
+			record.Status = StatusDeleted
+			if err := upsert(tx, bucketVulns, KindVulnerability, record.VulnID, record, record.state(), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordScansDeleted 记录通过服务器删除的扫描任务
+func (s *Store) RecordScansDeleted(instance string, scanIDs []string, now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range scanIDs {
+			var record ScanRecord
+			if found, err := get(tx, bucketScans, id, &record); err != nil {
+				return err
+			} else if !found || record.Instance != instance || record.Status == StatusDeleted {
+				continue
+			}
+			record.Status = StatusDeleted
+			if err := upsert(tx, bucketScans, KindScan, id, record, record.state(), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Sync 从指定实例逐页拉取全部目标、扫描和漏洞写入历史库
+func (s *Store) Sync(instance string, client *awvs.Client) error {
+	// 每页由Sync自行写入，不再经观察者重复记录
+	client = client.WithoutObserver()
+	now := time.Now()
+	err := client.WalkTargets("", func(page []awvs.Target) error {
+		return s.RecordTargets(instance, page, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = client.WalkScans("", func(page []awvs.Scan) error {
+		return s.RecordScans(instance, page, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	return client.WalkVulnerabilities("", func(page []awvs.Vulnerability) error {
+		return s.RecordVulnerabilities(instance, page, now)
+	})
+}
+
+// Targets 返回记录过的全部目标，按最后出现时间倒序
+func (s *Store) Targets() ([]TargetRecord, error) {
+	var targets []TargetRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketTargets).ForEach(func(k, v []byte) error {
+			var record TargetRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			targets = append(targets, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read target history failed: %w", err)
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].LastSeen.After(targets[j].LastSeen)
+	})
+	return targets, nil
+}
+
+// Scans 返回记录过的扫描任务，target可以是目标ID或地址，为空时返回全部，按开始时间倒序
+func (s *Store) Scans(target string) ([]ScanRecord, error) {
+	var scans []ScanRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketScans).ForEach(func(k, v []byte) error {
+			var record ScanRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if target == "" || record.TargetID == target || record.Address == target {
+				scans = append(scans, record)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read scan history failed: %w", err)
+	}
+
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].startTime().After(scans[j].startTime())
+	})
+	return scans, nil
+}
+
+// Vulnerabilities 返回记录过的全部漏洞
+func (s *Store) Vulnerabilities() ([]VulnerabilityRecord, error) {
+	var vulns []VulnerabilityRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketVulns).ForEach(func(k, v []byte) error {
+			var record VulnerabilityRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			vulns = append(vulns, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read vulnerability history failed: %w", err)
+	}
+	return vulns, nil
+}
+
+// Snapshots 返回[from, to)时间范围内指定类型的快照，kind为空时返回全部类型
+func (s *Store) Snapshots(kind string, from, to time.Time) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketSnapshots).Cursor()
+		end := timeKey(to)
+		for k, v := c.Seek(timeKey(from)); k != nil && bytes.Compare(k[:8], end) < 0; k, v = c.Next() {
+			var snapshot Snapshot
+			if err := json.Unmarshal(v, &snapshot); err != nil {
+				return err
+			}
+			if kind == "" || snapshot.Kind == kind {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read snapshots failed: %w", err)
+	}
+	return snapshots, nil
+}
+
+// startTime 返回扫描开始时间，缺少开始时间时使用首次记录时间
+func (r ScanRecord) startTime() time.Time {
+	if t, err := time.Parse(time.RFC3339, r.StartDate); err == nil {
+		return t
+	}
+	return r.FirstSeen
+}
+
+// state 返回扫描快照关心的字段，快照只记录会变化的部分
+func (r ScanRecord) state() interface{} {
+	return struct {
+		Status   string        `json:"status"`
+		Severity awvs.Severity `json:"severity"`
+	}{r.Status, r.Severity}
+}
+
+// state 返回漏洞快照关心的字段
+func (r VulnerabilityRecord) state() interface{} {
+	return struct {
+		Status   string `json:"status"`
+		Severity int    `json:"severity"`
+		TargetID string `json:"target_id"`
+		VtName   string `json:"vt_name"`
+	}{r.Status, r.Severity, r.TargetID, r.VtName}
+}
+
+// get 读取最新记录，返回是否存在
+func get(tx *bolt.Tx, bucket []byte, id string, out interface{}) (bool, error) {
+	data := tx.Bucket(bucket).Get([]byte(id))
+	if data == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("unmarshal %s/%s failed: %w", bucket, id, err)
+	}
+	return true, nil
+}
+
+// upsert 写入最新记录，state与上一次快照不同时追加一条快照
+func upsert(tx *bolt.Tx, bucket []byte, kind, id string, record, state interface{}, now time.Time) error {
+	recordData, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	stateData, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	lastBucket := tx.Bucket(bucketLastState)
+	lastKey := []byte(kind + "/" + id)
+	if !bytes.Equal(lastBucket.Get(lastKey), stateData) {
+		snapshot, err := json.Marshal(Snapshot{Time: now, Kind: kind, ID: id, Data: stateData})
+		if err != nil {
+			return err
+		}
+		key := append(timeKey(now), []byte("/"+kind+"/"+id)...)
+		if err := tx.Bucket(bucketSnapshots).Put(key, snapshot); err != nil {
+			return err
+		}
+		if err := lastBucket.Put(lastKey, stateData); err != nil {
+			return err
+		}
+	}
+
+	return tx.Bucket(bucket).Put([]byte(id), recordData)
+}
+
+// put 只更新最新记录，不追加快照
+func put(tx *bolt.Tx, bucket []byte, id string, record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put([]byte(id), data)
+}
+
+// timeKey 将时间编码为可按字节序排序的8字节键
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
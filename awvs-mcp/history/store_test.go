@@ -0,0 +1,109 @@
+package history
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRecordScansDeleted(t *testing.T) {
+	store := openTestStore(t)
+	now := time.Now()
+	if err := store.RecordScans("main", []awvs.Scan{{ScanID: "s1", TargetID: "t1"}, {ScanID: "s2", TargetID: "t1"}}, now); err != nil {
+		t.Fatalf("RecordScans: %v", err)
+	}
+	if err := store.RecordScansDeleted("main", []string{"s1", "missing"}, now.Add(time.Minute)); err != nil {
+		t.Fatalf("RecordScansDeleted: %v", err)
+	}
+
+	scans, err := store.Scans("")
+	if err != nil {
+		t.Fatalf("Scans: %v", err)
+	}
+	for _, s := range scans {
+		want := s.ScanID == "s1"
+		if (s.Status == StatusDeleted) != want {
+			t.Errorf("scan %s status = %q", s.ScanID, s.Status)
+		}
+	}
+}
+
+// countingObserver 统计客户端通知观察者的次数
+type countingObserver struct {
+	calls int
+}
+
+func (o *countingObserver) ObserveTargets([]awvs.Target)                { o.calls++ }
+func (o *countingObserver) ObserveScans([]awvs.Scan)                    { o.calls++ }
+func (o *countingObserver) ObserveVulnerabilities([]awvs.Vulnerability) { o.calls++ }
+func (o *countingObserver) ObserveTargetsDeleted([]string)              { o.calls++ }
+func (o *countingObserver) ObserveScansDeleted([]string)                { o.calls++ }
+
+// Sync自行写入每一页，不再经客户端观察者重复记录
+func TestSyncBypassesObserver(t *testing.T) {
+	pages := map[string]interface{}{
+		"targets":         []awvs.Target{{TargetID: "t1", Address: "https://a.example.com"}},
+		"scans":           []awvs.Scan{{ScanID: "s1", TargetID: "t1"}},
+		"vulnerabilities": []awvs.Vulnerability{{VulnID: "v1", TargetID: "t1", Severity: 3, Status: "open"}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+		json.NewEncoder(w).Encode(map[string]interface{}{key: pages[key]})
+	}))
+	defer server.Close()
+
+	client, err := awvs.NewClient(&awvs.Config{Name: "main", APIURL: server.URL, APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	observer := &countingObserver{}
+	client.SetObserver(observer)
+
+	store := openTestStore(t)
+	if err := store.Sync("main", client); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if observer.calls != 0 {
+		t.Errorf("observer called %d times during Sync, want 0", observer.calls)
+	}
+
+	targets, err := store.Targets()
+	if err != nil {
+		t.Fatalf("Targets: %v", err)
+	}
+	scans, err := store.Scans("")
+	if err != nil {
+		t.Fatalf("Scans: %v", err)
+	}
+	vulns, err := store.Vulnerabilities()
+	if err != nil {
+		t.Fatalf("Vulnerabilities: %v", err)
+	}
+	if len(targets) != 1 || len(scans) != 1 || len(vulns) != 1 {
+		t.Errorf("recorded %d targets, %d scans, %d vulnerabilities, want 1 each", len(targets), len(scans), len(vulns))
+	}
+
+	// 传入的客户端仍保留观察者
+	if _, err := client.ListAllTargets(""); err != nil {
+		t.Fatal(err)
+	}
+	if observer.calls != 1 {
+		t.Errorf("observer called %d times after ListAllTargets, want 1", observer.calls)
+	}
+}
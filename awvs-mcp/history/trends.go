@@ -0,0 +1,402 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// 趋势统计的时间粒度
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// 默认返回的高频复发漏洞数量
+const topRecurringLimit = 10
+
+// 时间序列最多包含的时间点数量，超出时需缩小时间范围或使用更大的粒度
+const MaxSeriesPoints = 366
+
+// TrendOptions 趋势统计选项
+type TrendOptions struct {
+	From     time.Time // 统计开始时间
+	To       time.Time // 统计结束时间
+	Interval string    // 时间粒度
+	Target   string    // 只统计指定目标（ID或地址），为空时统计全部
+	ByTarget bool      // 是否按目标分组输出
+	Groups   []Group   // 非空时额外输出每个目标组的统计
+}
+
+// Group 表示一个AWVS目标组及其成员
+type Group struct {
+	ID        string
+	Name      string
+	TargetIDs []string
+}
+
+// TrendPoint 表示某一时刻各严重级别的未修复漏洞数量
+type TrendPoint struct {
+	Time     time.Time `json:"time"`
+	Critical int       `json:"critical"`
+	High     int       `json:"high"`
+	Medium   int       `json:"medium"`
+	Low      int       `json:"low"`
+	Info     int       `json:"info"`
+	Total    int       `json:"total"`
+}
+
+// RecurringType 表示复发次数较多的漏洞类型
+type RecurringType struct {
+	VtName      string `json:"vt_name"`
+	Recurrences int    `json:"recurrences"`
+}
+
+// TrendStats 表示一组漏洞的修复与复发统计
+type TrendStats struct {
+	Open           int             `json:"open"`            // 统计结束时仍未修复的漏洞数
+	Fixed          int             `json:"fixed"`           // 统计范围内修复的漏洞数
+	MTTRHours      float64         `json:"mttr_hours"`      // 平均修复时长（小时）
+	Recurrences    int             `json:"recurrences"`     // 修复后再次出现的次数
+	RecurrenceRate float64         `json:"recurrence_rate"` // 复发次数占修复次数的比例
+	TopRecurring   []RecurringType `json:"top_recurring"`   // 复发最多的漏洞类型
+}
+
+// TargetTrend 表示单个目标的趋势统计
+type TargetTrend struct {
+	TargetID string `json:"target_id"`
+	Address  string `json:"address"`
+	TrendStats
+}
+
+// GroupTrend 表示单个目标组的趋势统计
+type GroupTrend struct {
+	GroupID string `json:"group_id"`
+	Name    string `json:"name"`
+	TrendStats
+}
+
+// Trends 表示漏洞趋势统计结果
+type Trends struct {
+	From     time.Time    `json:"from"`
+	To       time.Time    `json:"to"`
+	Interval string       `json:"interval"`
+	Series   []TrendPoint `json:"series"`
+	TrendStats
+	Targets []TargetTrend `json:"targets,omitempty"`
+	Groups  []GroupTrend  `json:"groups,omitempty"`
+}
+
+// vulnPoint 表示漏洞在某一时刻的状态
+type vulnPoint struct {
+	time     time.Time
+	status   string
+	severity int
+	targetID string
+	vtName   string
+}
+
+// isOpenStatus 判断漏洞状态是否视为未修复
+func isOpenStatus(status string) bool {
+	switch status {
+	case "fixed", "ignored", "false_positive", StatusDeleted, "":
+		return false
+	default:
+		return true
+	}
+}
+
+// Trends 根据本地历史快照计算漏洞趋势
+func (s *Store) Trends(opts TrendOptions) (*Trends, error) {
+	if opts.To.IsZero() {
+		opts.To = time.Now()
+	}
+	if opts.From.IsZero() {
+		opts.From = opts.To.AddDate(0, 0, -30)
+	}
+	if !opts.From.Before(opts.To) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	if opts.Interval == "" {
+		opts.Interval = IntervalDay
+	}
+	if err := checkSeriesLength(opts); err != nil {
+		return nil, err
+	}
+
+	// 目标过滤同时支持ID和地址
+	targets, err := s.Targets()
+	if err != nil {
+		return nil, err
+	}
+	addresses := make(map[string]string)
+	for _, t := range targets {
+		addresses[t.TargetID] = t.Address
+	}
+	matchTarget := func(targetID string) bool {
+		return opts.Target == "" || targetID == opts.Target || addresses[targetID] == opts.Target
+	}
+
+	// 从最早的快照开始重建每个漏洞的状态变化，才能知道统计开始时哪些漏洞仍未修复
+	snapshots, err := s.Snapshots(KindVulnerability, time.Unix(0, 0), opts.To.Add(time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	timelines := make(map[string][]vulnPoint)
+	for _, snapshot := range snapshots {
+		var state struct {
+			Status   string `json:"status"`
+			Severity int    `json:"severity"`
+			TargetID string `json:"target_id"`
+			VtName   string `json:"vt_name"`
+		}
+		if err := json.Unmarshal(snapshot.Data, &state); err != nil {
+			return nil, fmt.Errorf("unmarshal snapshot failed: %w", err)
+		}
+		if !matchTarget(state.TargetID) {
+			continue
+		}
+		timelines[snapshot.ID] = append(timelines[snapshot.ID], vulnPoint{
+			time:     snapshot.Time,
+			status:   state.Status,
+			severity: state.Severity,
+			targetID: state.TargetID,
+			vtName:   state.VtName,
+		})
+	}
+
+	trends := &Trends{
+		From:     opts.From,
+		To:       opts.To,
+		Interval: opts.Interval,
+	}
+
+	series, err := buildSeries(timelines, opts)
+	if err != nil {
+		return nil, err
+	}
+	trends.Series = series
+	trends.TrendStats = computeStats(timelines, opts)
+
+	if opts.ByTarget {
+		grouped := make(map[string]map[string][]vulnPoint)
+		for id, points := range timelines {
+			targetID := points[len(points)-1].targetID
+			if grouped[targetID] == nil {
+				grouped[targetID] = make(map[string][]vulnPoint)
+			}
+			grouped[targetID][id] = points
+		}
+		for targetID, group := range grouped {
+			trends.Targets = append(trends.Targets, TargetTrend{
+				TargetID:   targetID,
+				Address:    addresses[targetID],
+				TrendStats: computeStats(group, opts),
+			})
+		}
+		sort.Slice(trends.Targets, func(i, j int) bool {
+			return trends.Targets[i].Open > trends.Targets[j].Open
+		})
+	}
+
+	// 一个目标可以属于多个目标组，分别计入每个组
+	for _, g := range opts.Groups {
+		members := make(map[string]bool, len(g.TargetIDs))
+		for _, id := range g.TargetIDs {
+			members[id] = true
+		}
+		group := make(map[string][]vulnPoint)
+		for id, points := range timelines {
+			if members[points[len(points)-1].targetID] {
+				group[id] = points
+			}
+		}
+		trends.Groups = append(trends.Groups, GroupTrend{
+			GroupID:    g.ID,
+			Name:       g.Name,
+			TrendStats: computeStats(group, opts),
+		})
+	}
+	sort.SliceStable(trends.Groups, func(i, j int) bool {
+		return trends.Groups[i].Open > trends.Groups[j].Open
+	})
+
+	return trends, nil
+}
+
+// checkSeriesLength 校验时间序列的时间点数量不超过MaxSeriesPoints，避免返回过大的结果
+func checkSeriesLength(opts TrendOptions) error {
+	t := opts.From
+	for n := 1; ; n++ {
+		next, err := advance(t, opts.Interval)
+		if err != nil {
+			return err
+		}
+		if !next.Before(opts.To) {
+			return nil
+		}
+		if n >= MaxSeriesPoints {
+			return fmt.Errorf("time range produces more than %d %s intervals, narrow the range or use a larger interval", MaxSeriesPoints, opts.Interval)
+		}
+		t = next
+	}
+}
+
+// buildSeries 计算每个时间点的未修复漏洞数量
+func buildSeries(timelines map[string][]vulnPoint, opts TrendOptions) ([]TrendPoint, error) {
+	var series []TrendPoint
+	for t := opts.From; ; {
+		next, err := advance(t, opts.Interval)
+		if err != nil {
+			return nil, err
+		}
+		// 每个区间取区间结束时刻的状态
+		at := next
+		if at.After(opts.To) {
+			at = opts.To
+		}
+
+		point := TrendPoint{Time: at}
+		for _, points := range timelines {
+			state, ok := stateAt(points, at)
+			if !ok || !isOpenStatus(state.status) {
+				continue
+			}
+			switch state.severity {
+			case awvs.SeverityCritical:
+				point.Critical++
+			case awvs.SeverityHigh:
+				point.High++
+			case awvs.SeverityMedium:
+				point.Medium++
+			case awvs.SeverityLow:
+				point.Low++
+			default:
+				point.Info++
+			}
+			point.Total++
+		}
+		series = append(series, point)
+
+		if !next.Before(opts.To) {
+			break
+		}
+		t = next
+	}
+	return series, nil
+}
+
+// computeStats 计算修复时长和复发情况
+func computeStats(timelines map[string][]vulnPoint, opts TrendOptions) TrendStats {
+	var stats TrendStats
+	var totalRepair time.Duration
+	recurring := make(map[string]int)
+
+	// 同一目标上同类漏洞最早被修复的时间，用于识别以新漏洞ID重新出现的情况
+	type typeKey struct{ targetID, vtName string }
+	fixedTypes := make(map[typeKey]time.Time)
+
+	// 按首次出现时间处理，保证先修复的漏洞先登记
+	ids := make([]string, 0, len(timelines))
+	for id := range timelines {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return timelines[ids[i]][0].time.Before(timelines[ids[j]][0].time)
+	})
+
+	for _, id := range ids {
+		points := timelines[id]
+		first := points[0]
+		key := typeKey{first.targetID, first.vtName}
+
+		// 新漏洞ID出现时，如果同一目标上同类漏洞此前已被修复，视为复发
+		if fixedAt, ok := fixedTypes[key]; ok && isOpenStatus(first.status) && first.time.After(fixedAt) && inRange(first.time, opts) {
+			stats.Recurrences++
+			recurring[first.vtName]++
+		}
+
+		var openedAt time.Time
+		wasOpen := false
+		wasFixed := false
+		for _, p := range points {
+			open := isOpenStatus(p.status)
+			switch {
+			case open && !wasOpen:
+				openedAt = p.time
+				// 同一漏洞修复后重新打开
+				if wasFixed && inRange(p.time, opts) {
+					stats.Recurrences++
+					recurring[p.vtName]++
+				}
+			case !open && wasOpen && p.status == "fixed":
+				wasFixed = true
+				if inRange(p.time, opts) {
+					stats.Fixed++
+					totalRepair += p.time.Sub(openedAt)
+				}
+				if fixedAt, ok := fixedTypes[key]; !ok || p.time.Before(fixedAt) {
+					fixedTypes[key] = p.time
+				}
+			}
+			wasOpen = open
+		}
+
+		if state, ok := stateAt(points, opts.To); ok && isOpenStatus(state.status) {
+			stats.Open++
+		}
+	}
+
+	if stats.Fixed > 0 {
+		stats.MTTRHours = totalRepair.Hours() / float64(stats.Fixed)
+		stats.RecurrenceRate = float64(stats.Recurrences) / float64(stats.Fixed)
+	}
+
+	for name, count := range recurring {
+		stats.TopRecurring = append(stats.TopRecurring, RecurringType{VtName: name, Recurrences: count})
+	}
+	sort.Slice(stats.TopRecurring, func(i, j int) bool {
+		if stats.TopRecurring[i].Recurrences != stats.TopRecurring[j].Recurrences {
+			return stats.TopRecurring[i].Recurrences > stats.TopRecurring[j].Recurrences
+		}
+		return stats.TopRecurring[i].VtName < stats.TopRecurring[j].VtName
+	})
+	if len(stats.TopRecurring) > topRecurringLimit {
+		stats.TopRecurring = stats.TopRecurring[:topRecurringLimit]
+	}
+
+	return stats
+}
+
+// stateAt 返回漏洞在指定时刻的状态
+func stateAt(points []vulnPoint, at time.Time) (vulnPoint, bool) {
+	idx := sort.Search(len(points), func(i int) bool {
+		return points[i].time.After(at)
+	})
+	if idx == 0 {
+		return vulnPoint{}, false
+	}
+	return points[idx-1], true
+}
+
+func inRange(t time.Time, opts TrendOptions) bool {
+	return !t.Before(opts.From) && !t.After(opts.To)
+}
+
+// advance 返回下一个统计时间点
+func advance(t time.Time, interval string) (time.Time, error) {
+	switch interval {
+	case IntervalDay:
+		return t.AddDate(0, 0, 1), nil
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7), nil
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported interval: %s", interval)
+	}
+}
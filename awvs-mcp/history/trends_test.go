@@ -0,0 +1,157 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// 通过服务器删除的目标下的漏洞应记为已删除，不再计入未修复数量
+func TestTrendsDeletedTarget(t *testing.T) {
+	store := openTestStore(t)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	targets := []awvs.Target{{TargetID: "t1", Address: "https://a.example.com"}, {TargetID: "t2", Address: "https://b.example.com"}}
+	if err := store.RecordTargets("main", targets, start); err != nil {
+		t.Fatalf("RecordTargets: %v", err)
+	}
+	vulns := []awvs.Vulnerability{
+		{VulnID: "v1", TargetID: "t1", Severity: 3, Status: "open", VtName: "SQL Injection"},
+		{VulnID: "v2", TargetID: "t1", Severity: 2, Status: "fixed", VtName: "XSS"},
+		{VulnID: "v3", TargetID: "t2", Severity: 3, Status: "open", VtName: "SQL Injection"},
+	}
+	if err := store.RecordVulnerabilities("main", vulns, start); err != nil {
+		t.Fatalf("RecordVulnerabilities: %v", err)
+	}
+	if err := store.RecordScans("main", []awvs.Scan{{ScanID: "s1", TargetID: "t1"}}, start); err != nil {
+		t.Fatalf("RecordScans: %v", err)
+	}
+
+	// 其他实例中的同名目标不受影响
+	deletedAt := start.AddDate(0, 0, 2)
+	if err := store.RecordTargetsDeleted("other", []string{"t2"}, deletedAt); err != nil {
+		t.Fatalf("RecordTargetsDeleted: %v", err)
+	}
+	if err := store.RecordTargetsDeleted("main", []string{"t1"}, deletedAt); err != nil {
+		t.Fatalf("RecordTargetsDeleted: %v", err)
+	}
+
+	trends, err := store.Trends(TrendOptions{From: start, To: start.AddDate(0, 0, 4)})
+	if err != nil {
+		t.Fatalf("Trends: %v", err)
+	}
+	if trends.Open != 1 {
+		t.Errorf("Open = %d, want 1", trends.Open)
+	}
+	if trends.Fixed != 0 {
+		t.Errorf("Fixed = %d, want 0 (deletion is not a fix)", trends.Fixed)
+	}
+	// 删除前两个漏洞未修复，删除后只剩t2的漏洞
+	if got := trends.Series[0].Total; got != 2 {
+		t.Errorf("Series[0].Total = %d, want 2", got)
+	}
+	if got := trends.Series[len(trends.Series)-1].Total; got != 1 {
+		t.Errorf("last Series.Total = %d, want 1", got)
+	}
+
+	scans, err := store.Scans("t1")
+	if err != nil {
+		t.Fatalf("Scans: %v", err)
+	}
+	if len(scans) != 1 || scans[0].Status != StatusDeleted {
+		t.Errorf("scans = %+v, want one deleted scan", scans)
+	}
+	records, err := store.Targets()
+	if err != nil {
+		t.Fatalf("Targets: %v", err)
+	}
+	for _, r := range records {
+		if (r.DeletedAt != nil) != (r.TargetID == "t1") {
+			t.Errorf("target %s DeletedAt = %v", r.TargetID, r.DeletedAt)
+		}
+	}
+}
+
+// 时间序列的时间点数量超过上限时拒绝统计
+func TestTrendsSeriesLimit(t *testing.T) {
+	store := openTestStore(t)
+	to := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := store.Trends(TrendOptions{From: time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), To: to}); err == nil {
+		t.Error("Trends over 126 years by day succeeded, want error")
+	}
+
+	trends, err := store.Trends(TrendOptions{From: to.AddDate(0, 0, -MaxSeriesPoints), To: to})
+	if err != nil {
+		t.Fatalf("Trends with %d days: %v", MaxSeriesPoints, err)
+	}
+	if len(trends.Series) != MaxSeriesPoints {
+		t.Errorf("len(Series) = %d, want %d", len(trends.Series), MaxSeriesPoints)
+	}
+	if _, err := store.Trends(TrendOptions{From: to.AddDate(0, 0, -MaxSeriesPoints-1), To: to}); err == nil {
+		t.Errorf("Trends with %d days succeeded, want error", MaxSeriesPoints+1)
+	}
+
+	// 更大的粒度可以覆盖更长的范围
+	trends, err = store.Trends(TrendOptions{From: to.AddDate(-10, 0, 0), To: to, Interval: IntervalMonth})
+	if err != nil {
+		t.Fatalf("Trends over 10 years by month: %v", err)
+	}
+	if len(trends.Series) != 120 {
+		t.Errorf("len(Series) = %d, want 120", len(trends.Series))
+	}
+}
+
+// 修复时长、复发和按目标组分组统计
+func TestTrendsStatsAndGroups(t *testing.T) {
+	store := openTestStore(t)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	vulns := []awvs.Vulnerability{
+		{VulnID: "v1", TargetID: "t1", Severity: 3, Status: "open", VtName: "SQL Injection"},
+		{VulnID: "v2", TargetID: "t2", Severity: 2, Status: "open", VtName: "XSS"},
+	}
+	if err := store.RecordVulnerabilities("main", vulns, start); err != nil {
+		t.Fatalf("RecordVulnerabilities: %v", err)
+	}
+	vulns[0].Status = "fixed"
+	if err := store.RecordVulnerabilities("main", vulns[:1], start.Add(48*time.Hour)); err != nil {
+		t.Fatalf("RecordVulnerabilities: %v", err)
+	}
+	vulns[0].Status = "open"
+	if err := store.RecordVulnerabilities("main", vulns[:1], start.Add(72*time.Hour)); err != nil {
+		t.Fatalf("RecordVulnerabilities: %v", err)
+	}
+
+	trends, err := store.Trends(TrendOptions{
+		From: start,
+		To:   start.AddDate(0, 0, 5),
+		Groups: []Group{
+			{ID: "g1", Name: "web", TargetIDs: []string{"t1"}},
+			{ID: "g2", Name: "all", TargetIDs: []string{"t1", "t2"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Trends: %v", err)
+	}
+	if trends.Fixed != 1 || trends.MTTRHours != 48 {
+		t.Errorf("Fixed = %d, MTTRHours = %v, want 1 and 48", trends.Fixed, trends.MTTRHours)
+	}
+	if trends.Recurrences != 1 || len(trends.TopRecurring) != 1 || trends.TopRecurring[0].VtName != "SQL Injection" {
+		t.Errorf("Recurrences = %d, TopRecurring = %+v, want one SQL Injection recurrence", trends.Recurrences, trends.TopRecurring)
+	}
+	if len(trends.Series) != 5 || trends.Series[1].Total != 1 || trends.Series[2].Total != 2 {
+		t.Errorf("Series = %+v, want 5 points with 1 open at the fix and 2 after the recurrence", trends.Series)
+	}
+
+	if len(trends.Groups) != 2 {
+		t.Fatalf("len(Groups) = %d, want 2", len(trends.Groups))
+	}
+	if g := trends.Groups[0]; g.GroupID != "g2" || g.Open != 2 {
+		t.Errorf("Groups[0] = %+v, want g2 with 2 open", g)
+	}
+	if g := trends.Groups[1]; g.GroupID != "g1" || g.Open != 1 || g.Fixed != 1 {
+		t.Errorf("Groups[1] = %+v, want g1 with 1 open and 1 fixed", g)
+	}
+}
@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "awvs_mcp"
+
+// 工具调用结果
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "MCP工具调用次数",
+	}, []string{"tool", "status"})
+
+	toolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_call_duration_seconds",
+		Help:      "MCP工具调用耗时",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"tool"})
+
+	upstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "AWVS API请求次数，code为HTTP状态码，请求失败时为error",
+	}, []string{"instance", "endpoint", "code"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "AWVS API请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"instance", "endpoint"})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook事件投递次数（含重试后的最终结果）",
+	}, []string{"webhook", "status"})
+
+	webhookRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_retries_total",
+		Help:      "Webhook投递重试次数",
+	}, []string{"webhook"})
+
+	activeScans = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_scans",
+		Help:      "AWVS实例上排队和运行中的扫描数量",
+	}, []string{"instance"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		toolCalls, toolDuration,
+		upstreamRequests, upstreamDuration,
+		webhookDeliveries, webhookRetries,
+		activeScans,
+	)
+}
+
+// ObserveUpstream 记录一次AWVS API请求，statusCode为0表示请求未得到响应
+func ObserveUpstream(instance, path string, statusCode int, duration time.Duration) {
+	code := "error"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	endpoint := Endpoint(path)
+	upstreamRequests.WithLabelValues(instance, endpoint, code).Inc()
+	upstreamDuration.WithLabelValues(instance, endpoint).Observe(duration.Seconds())
+}
+
+// ObserveWebhook 记录一次Webhook投递的最终结果
+func ObserveWebhook(webhook string, err error) {
+	status := StatusOK
+	if err != nil {
+		status = StatusError
+	}
+	webhookDeliveries.WithLabelValues(webhook, status).Inc()
+}
+
+// ObserveWebhookRetry 记录一次Webhook重试
+func ObserveWebhookRetry(webhook string) {
+	webhookRetries.WithLabelValues(webhook).Inc()
+}
+
+// SetActiveScans 更新实例当前的扫描数量
+func SetActiveScans(instance string, count int) {
+	activeScans.WithLabelValues(instance).Set(float64(count))
+}
+
+// ResetActiveScans 清空扫描数量，用于实例列表变化后去掉已移除的实例
+func ResetActiveScans() {
+	activeScans.Reset()
+}
+
+// Endpoint 将请求路径归一化为接口名称，去掉查询参数并把ID替换为占位符，避免标签基数过高
+func Endpoint(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if i > 0 && isID(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// isID 判断路径片段是否为UUID等资源ID
+func isID(segment string) bool {
+	if len(segment) < 16 {
+		return false
+	}
+	for _, r := range segment {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// InstrumentHooks 在MCP服务器钩子中记录工具调用次数、耗时和结果
+func InstrumentHooks(hooks *server.Hooks) {
+	var starts sync.Map // *mcp.CallToolRequest -> time.Time
+
+	finish := func(request *mcp.CallToolRequest, status string) {
+		value, ok := starts.LoadAndDelete(request)
+		if !ok {
+			return
+		}
+		tool := request.Params.Name
+		toolCalls.WithLabelValues(tool, status).Inc()
+		toolDuration.WithLabelValues(tool).Observe(time.Since(value.(time.Time)).Seconds())
+	}
+
+	hooks.AddBeforeCallTool(func(ctx context.Context, id any, request *mcp.CallToolRequest) {
+		starts.Store(request, time.Now())
+	})
+	hooks.AddAfterCallTool(func(ctx context.Context, id any, request *mcp.CallToolRequest, result *mcp.CallToolResult) {
+		status := StatusOK
+		if result != nil && result.IsError {
+			status = StatusError
+		}
+		finish(request, status)
+	})
+	hooks.AddOnError(func(ctx context.Context, id any, method mcp.MCPMethod, message any, err error) {
+		if request, ok := message.(*mcp.CallToolRequest); ok {
+			finish(request, StatusError)
+		}
+	})
+}
+
+// Serve 在指定地址提供 /metrics 接口，直到ctx被取消
+func Serve(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("启动指标服务，地址 %s/metrics", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("指标服务错误: %v", err)
+	}
+}
@@ -5,4 +5,104 @@ type Config struct {
 	APIURL    string `json:"api_url"`    // AWVS API URL
 	APIKey    string `json:"api_key"`    // AWVS API 密钥
 	VerifySSL bool   `json:"verify_ssl"` // 是否验证SSL证书
+
+	APIKeyFile string `json:"api_key_file,omitempty"` // 从文件读取API密钥，api_key为空时生效
+
+	ConnectionConfig // 连接参数：CA证书、证书指纹、客户端证书、代理和超时
+
+	Instances []InstanceConfig `json:"instances,omitempty"` // 多个命名AWVS实例，配置后忽略上面的单实例配置
+
+	Scope []string `json:"scope,omitempty"` // 允许添加和扫描的目标范围：域名、*.子域名、IP或CIDR，为空时不限制
+
+	ReportTemplateDir string `json:"report_template_dir,omitempty"` // 自定义报告模板目录
+	OutputDir         string `json:"output_dir,omitempty"`          // 工具保存报告和导出文件的目录，未配置时工具不能写文件
+
+	Tracker *TrackerConfig `json:"tracker,omitempty"` // 工单系统集成配置
+	Notify  *NotifyConfig  `json:"notify,omitempty"`  // 扫描事件Webhook通知配置
+	History *HistoryConfig `json:"history,omitempty"` // 本地扫描历史库配置
+	Metrics *MetricsConfig `json:"metrics,omitempty"` // Prometheus指标配置
+	Tracing *TracingConfig `json:"tracing,omitempty"` // OpenTelemetry链路追踪配置
+	Audit   *AuditConfig   `json:"audit,omitempty"`   // 工具调用审计日志配置
+}
+
+// InstanceConfig 表示一个命名的AWVS实例
+type InstanceConfig struct {
+	Name      string `json:"name"`       // 实例名称，如 prod-scanner
+	APIURL    string `json:"api_url"`    // AWVS API URL
+	APIKey    string `json:"api_key"`    // AWVS API 密钥
+	VerifySSL bool   `json:"verify_ssl"` // 是否验证SSL证书
+
+	APIKeyFile string `json:"api_key_file,omitempty"` // 从文件读取API密钥，api_key为空时生效
+
+	ConnectionConfig // 连接参数，未配置时使用默认值
+}
+
+// ConnectionConfig 表示连接AWVS时使用的TLS、代理和超时配置
+type ConnectionConfig struct {
+	CAFile          string `json:"ca_file,omitempty"`          // 自定义CA证书文件（PEM），用于内部CA签发的证书
+	CertFingerprint string `json:"cert_fingerprint,omitempty"` // 固定的服务端证书SHA-256指纹
+	ClientCert      string `json:"client_cert,omitempty"`      // mTLS客户端证书文件
+	ClientKey       string `json:"client_key,omitempty"`       // mTLS客户端私钥文件
+	Proxy           string `json:"proxy,omitempty"`            // 出站代理，如 http://proxy:3128 或 socks5://proxy:1080
+	Timeout         int    `json:"timeout,omitempty"`          // 单次请求超时（秒），默认30
+	ConnectTimeout  int    `json:"connect_timeout,omitempty"`  // 建立连接和TLS握手超时（秒），默认10
+}
+
+// TrackerConfig 表示Jira兼容工单系统配置
+type TrackerConfig struct {
+	BaseURL        string            `json:"base_url"`                  // 工单系统地址
+	Username       string            `json:"username,omitempty"`        // 用户名，为空时使用Bearer令牌认证
+	APIToken       string            `json:"api_token"`                 // API令牌
+	APITokenFile   string            `json:"api_token_file,omitempty"`  // 从文件读取API令牌，api_token为空时生效
+	Project        string            `json:"project"`                   // 项目Key
+	IssueType      string            `json:"issue_type,omitempty"`      // 工单类型，默认Bug
+	PriorityMap    map[string]string `json:"priority_map,omitempty"`    // 严重级别到优先级的映射
+	FieldMap       map[string]string `json:"field_map,omitempty"`       // 自定义字段到漏洞属性的映射
+	Labels         []string          `json:"labels,omitempty"`          // 附加标签
+	DoneTransition string            `json:"done_transition,omitempty"` // 漏洞修复后使用的流转，默认Done
+	StateFile      string            `json:"state_file,omitempty"`      // 已创建工单记录文件，默认tickets.json
+}
+
+// NotifyConfig 表示扫描事件通知配置
+type NotifyConfig struct {
+	PollInterval int             `json:"poll_interval,omitempty"` // 轮询间隔（秒），默认60
+	MinSeverity  string          `json:"min_severity,omitempty"`  // 触发新漏洞通知的最低级别，默认high
+	Webhooks     []WebhookConfig `json:"webhooks"`                // Webhook接收端列表
+}
+
+// WebhookConfig 表示一个Webhook接收端
+type WebhookConfig struct {
+	Name       string   `json:"name"`                  // 名称
+	URL        string   `json:"url"`                   // 接收地址
+	Type       string   `json:"type,omitempty"`        // 消息格式: generic、slack、dingtalk、feishu
+	Secret     string   `json:"secret,omitempty"`      // 签名密钥
+	Events     []string `json:"events,omitempty"`      // 订阅的事件，为空时订阅全部
+	MaxRetries *int     `json:"max_retries,omitempty"` // 失败后的最大重试次数，默认3，0表示不重试
+}
+
+// MetricsConfig 表示Prometheus指标配置
+type MetricsConfig struct {
+	Listen       string `json:"listen"`                  // 指标服务监听地址，如 :9090
+	ScanInterval int    `json:"scan_interval,omitempty"` // 采集各实例扫描数量的间隔（秒），默认60
+}
+
+// TracingConfig 表示OpenTelemetry链路追踪配置
+type TracingConfig struct {
+	Exporter    string            `json:"exporter"`               // 导出方式：otlp 或 stdout
+	Endpoint    string            `json:"endpoint,omitempty"`     // OTLP/HTTP接收地址，如 http://localhost:4318，为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT
+	Headers     map[string]string `json:"headers,omitempty"`      // 发送到OTLP接收端的附加请求头
+	ServiceName string            `json:"service_name,omitempty"` // 服务名称，默认 awvs-mcp
+	SampleRatio float64           `json:"sample_ratio,omitempty"` // 采样比例（0-1），默认1即全部采样
+}
+
+// AuditConfig 表示工具调用审计日志配置
+type AuditConfig struct {
+	Path      string `json:"path"`                 // 日志文件路径，JSON Lines格式，只追加写入
+	HashChain bool   `json:"hash_chain,omitempty"` // 是否为每条记录计算哈希并链接上一条记录，用于发现篡改
+}
+
+// HistoryConfig 表示本地扫描历史库配置
+type HistoryConfig struct {
+	Path         string `json:"path"`                    // 数据库文件路径
+	SyncInterval int    `json:"sync_interval,omitempty"` // 定期全量同步间隔（秒），0表示只记录工具调用中见过的数据
 }
@@ -0,0 +1,298 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// 事件类型常量
+const (
+	EventScanStarted       = "scan_started"
+	EventScanCompleted     = "scan_completed"
+	EventScanFailed        = "scan_failed"
+	EventHighVulnerability = "high_vulnerability"
+)
+
+// 默认轮询间隔
+const defaultPollInterval = 60 * time.Second
+
+// Config Webhook通知配置
+type Config struct {
+	PollInterval time.Duration   // 轮询间隔
+	MinSeverity  int             // 触发新漏洞通知的最低严重级别
+	Webhooks     []WebhookConfig // 接收端列表
+}
+
+// Event 表示一次扫描事件
+type Event struct {
+	Type          string              `json:"event"`
+	Instance      string              `json:"instance"`
+	Timestamp     time.Time           `json:"timestamp"`
+	Message       string              `json:"message"`
+	Scan          *awvs.Scan          `json:"scan,omitempty"`
+	Vulnerability *awvs.Vulnerability `json:"vulnerability,omitempty"`
+}
+
+// Watcher 定期轮询AWVS扫描任务和漏洞，在状态变化时触发Webhook
+type Watcher struct {
+	instance string
+	client   *awvs.Client
+	config   *Config
+	webhooks []*webhook
+	stop     chan struct{} // 关闭后停止轮询
+	stopOnce sync.Once
+
+	// mu 保护以下轮询状态，轮询只在比较和更新状态时持有，访问AWVS时不持有
+	mu         sync.Mutex
+	scanStates map[string]string // 扫描ID到上次看到的状态
+	seenVulns  map[string]bool   // 已通知过的漏洞ID
+	primed     bool              // 是否已完成首次轮询
+	retired    bool              // 状态已交给新的监听器，不再轮询
+}
+
+// NewWatcher 为指定实例创建扫描事件监听器
+func NewWatcher(instance string, client *awvs.Client, config *Config) (*Watcher, error) {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	webhooks := make([]*webhook, 0, len(config.Webhooks))
+	for _, wc := range config.Webhooks {
+		if wc.URL == "" {
+			return nil, fmt.Errorf("webhook %s: url is required", wc.Name)
+		}
+		switch wc.Type {
+		case "", TypeGeneric, TypeSlack, TypeDingTalk, TypeFeishu:
+		default:
+			return nil, fmt.Errorf("webhook %s: unsupported type %s", wc.Name, wc.Type)
+		}
+		webhooks = append(webhooks, newWebhook(wc))
+	}
+
+	return &Watcher{
+		instance:   instance,
+		client:     client,
+		config:     config,
+		webhooks:   webhooks,
+		stop:       make(chan struct{}),
+		scanStates: make(map[string]string),
+		seenVulns:  make(map[string]bool),
+	}, nil
+}
+
+// Inherit 接管旧监听器的扫描状态和已通知漏洞，重新加载配置后不必重新建立基线
+// 接管后旧监听器不再轮询和推送事件，进行中轮询拉取到的结果会被丢弃
+func (w *Watcher) Inherit(old *Watcher) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.scanStates = old.scanStates
+	w.seenVulns = old.seenVulns
+	w.primed = old.primed
+	old.scanStates = make(map[string]string)
+	old.seenVulns = make(map[string]bool)
+	old.retired = true
+}
+
+// Run 持续轮询直到ctx被取消或调用Stop
+// 调用Stop后不再轮询，已生成的事件和进行中的重试仍会投递完，直到ctx被取消
+func (w *Watcher) Run(ctx context.Context) {
+	log.Printf("启动实例 %s 的扫描事件监听，轮询间隔 %s，Webhook %d 个", w.instance, w.config.PollInterval, len(w.webhooks))
+
+	for _, hook := range w.webhooks {
+		hook.start(ctx)
+	}
+
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			// 轮询只在本协程中分发事件，此时关闭队列不会再有新事件写入
+			for _, hook := range w.webhooks {
+				hook.close()
+			}
+			return
+		default:
+		}
+
+		w.poll()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stop:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 停止轮询，重新加载配置时用于替换旧监听器，不等待剩余事件投递完成
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// poll 执行一次轮询并分发事件
+// 访问AWVS时不持有锁，重新加载配置时Inherit不必等待整轮轮询完成
+func (w *Watcher) poll() {
+	w.mu.Lock()
+	retired := w.retired
+	w.mu.Unlock()
+	if retired {
+		return
+	}
+
+	// 逐页拉取全部扫描，扫描数超过一页时也能发现每个扫描的状态变化
+	scans, scanErr := w.client.ListAllScans("")
+	if scanErr != nil {
+		log.Printf("轮询扫描任务失败: %v", scanErr)
+	}
+	vulns, vulnErr := w.listVulnerabilities()
+	if vulnErr != nil {
+		log.Printf("轮询漏洞失败: %v", vulnErr)
+	}
+
+	// 分发时可能等待队列腾出位置，放在锁外进行
+	for _, event := range w.diff(scans, scanErr, vulns, vulnErr) {
+		w.dispatch(event)
+	}
+}
+
+// diff 比较拉取结果与上次的状态，返回需要推送的事件
+func (w *Watcher) diff(scans []awvs.Scan, scanErr error, vulns []awvs.Vulnerability, vulnErr error) []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// 拉取期间状态已交给新的监听器，由新监听器基于接管的状态继续比较
+	if w.retired {
+		return nil
+	}
+
+	var events []Event
+	if scanErr == nil {
+		events = append(events, w.diffScans(scans)...)
+	}
+	if vulnErr == nil {
+		events = append(events, w.diffVulnerabilities(vulns)...)
+	}
+
+	// 首次轮询只记录基线，避免启动时推送大量历史事件
+	if !w.primed {
+		w.primed = scanErr == nil && vulnErr == nil
+		return nil
+	}
+	return events
+}
+
+// diffScans 比较扫描状态变化生成事件，调用方需持有w.mu
+func (w *Watcher) diffScans(scans []awvs.Scan) []Event {
+	// 只保留当前仍存在的扫描，已删除扫描的状态随之清理
+	states := make(map[string]string, len(scans))
+	var events []Event
+	for i := range scans {
+		scan := scans[i]
+		state := scan.State()
+		previous, seen := w.scanStates[scan.ScanID]
+		states[scan.ScanID] = state
+		if seen && previous == state {
+			continue
+		}
+
+		switch state {
+		case "queued", "starting", "processing":
+			if !seen || isFinished(previous) {
+				events = append(events, w.newScanEvent(EventScanStarted, &scan))
+			}
+		case "completed":
+			events = append(events, w.newScanEvent(EventScanCompleted, &scan))
+		case "failed", "aborted":
+			events = append(events, w.newScanEvent(EventScanFailed, &scan))
+		}
+	}
+	w.scanStates = states
+	return events
+}
+
+// listVulnerabilities 拉取达到通知级别且仍处于open状态的漏洞
+func (w *Watcher) listVulnerabilities() ([]awvs.Vulnerability, error) {
+	var severities []string
+	for s := awvs.SeverityCritical; s >= w.config.MinSeverity; s-- {
+		severities = append(severities, fmt.Sprintf("%d", s))
+	}
+	query := fmt.Sprintf("severity:%s;status:open", strings.Join(severities, ","))
+
+	return w.client.ListVulnerabilities(query)
+}
+
+// diffVulnerabilities 查找新出现的高危漏洞，调用方需持有w.mu
+func (w *Watcher) diffVulnerabilities(vulns []awvs.Vulnerability) []Event {
+	// 只保留仍处于open状态的漏洞，修复或扫描结束后关闭的漏洞不再占用内存
+	seen := make(map[string]bool, len(vulns))
+	var events []Event
+	for i := range vulns {
+		vuln := vulns[i]
+		seen[vuln.VulnID] = true
+		if w.seenVulns[vuln.VulnID] {
+			continue
+		}
+		events = append(events, Event{
+			Type:          EventHighVulnerability,
+			Instance:      w.instance,
+			Timestamp:     time.Now(),
+			Message:       fmt.Sprintf("[AWVS:%s] 发现新的%s漏洞: %s (%s)", w.instance, awvs.SeverityName(vuln.Severity), vuln.VtName, vuln.AffectsURL),
+			Vulnerability: &vuln,
+		})
+	}
+	w.seenVulns = seen
+	return events
+}
+
+// dispatch 将事件投递给所有订阅的接收端
+func (w *Watcher) dispatch(event Event) {
+	for _, hook := range w.webhooks {
+		if !hook.subscribed(event.Type) {
+			continue
+		}
+		// 每个接收端有独立队列，避免一个接收端重试阻塞其他接收端
+		hook.enqueue(event, webhookEnqueueTimeout)
+	}
+}
+
+func (w *Watcher) newScanEvent(eventType string, scan *awvs.Scan) Event {
+	target := scan.TargetID
+	if scan.Target.Address != "" {
+		target = scan.Target.Address
+	}
+
+	var message string
+	switch eventType {
+	case EventScanStarted:
+		message = fmt.Sprintf("[AWVS:%s] 扫描已开始: %s", w.instance, target)
+	case EventScanCompleted:
+		counts := scan.Counts()
+		message = fmt.Sprintf("[AWVS:%s] 扫描已完成: %s（严重 %d，高危 %d，中危 %d，低危 %d，信息 %d）",
+			w.instance, target, counts.Critical, counts.High, counts.Medium, counts.Low, counts.Info)
+	case EventScanFailed:
+		message = fmt.Sprintf("[AWVS:%s] 扫描失败: %s（状态 %s）", w.instance, target, scan.State())
+	}
+
+	return Event{
+		Type:      eventType,
+		Instance:  w.instance,
+		Timestamp: time.Now(),
+		Message:   message,
+		Scan:      scan,
+	}
+}
+
+func isFinished(state string) bool {
+	return state == "completed" || state == "failed" || state == "aborted"
+}
@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// 轮询访问AWVS期间重新加载配置，Inherit不等待轮询完成
+func TestInheritDoesNotWaitForPoll(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/scans" {
+			select {
+			case requested <- struct{}{}:
+			default:
+			}
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"scans": []awvs.Scan{{ScanID: "s2", CurrentSession: awvs.ScanSession{Status: "processing"}}},
+		})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := awvs.NewClient(&awvs.Config{Name: "test", APIURL: server.URL, APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := NewWatcher("main", client, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.scanStates["s1"] = "completed"
+	old.primed = true
+
+	polled := make(chan struct{})
+	go func() {
+		old.poll()
+		close(polled)
+	}()
+	<-requested
+
+	w, err := NewWatcher("main", client, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inherited := make(chan struct{})
+	go func() {
+		w.Inherit(old)
+		close(inherited)
+	}()
+	select {
+	case <-inherited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Inherit blocked on the in-flight poll")
+	}
+
+	release <- struct{}{}
+	<-polled
+
+	// 旧监听器拉取到的结果被丢弃，不会覆盖已交出的状态
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.scanStates) != 1 || w.scanStates["s1"] != "completed" || !w.primed {
+		t.Errorf("inherited state = %v, primed = %v, want s1 completed and primed", w.scanStates, w.primed)
+	}
+}
+
+// 重新加载配置时旧监听器停止轮询，但已生成的事件仍会投递完，包括投递中和排队中的事件
+func TestStopDeliversQueuedEvents(t *testing.T) {
+	awvsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"scans": []awvs.Scan{
+				{ScanID: "s1", CurrentSession: awvs.ScanSession{Status: "completed"}},
+				{ScanID: "s2", CurrentSession: awvs.ScanSession{Status: "completed"}},
+				{ScanID: "s3", CurrentSession: awvs.ScanSession{Status: "completed"}},
+			},
+		})
+	}))
+	defer awvsServer.Close()
+
+	// 接收端在放行前阻塞所有请求，两个投递协程都在投递中，第三个事件留在队列里
+	var mu sync.Mutex
+	delivered := make(map[string]bool)
+	requested := make(chan struct{}, 3)
+	release := make(chan struct{})
+	hookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-release
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		delivered[event.Scan.ScanID] = true
+		mu.Unlock()
+	}))
+	defer hookServer.Close()
+
+	client, err := awvs.NewClient(&awvs.Config{Name: "test", APIURL: awvsServer.URL, APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{PollInterval: time.Hour, Webhooks: []WebhookConfig{{Name: "hook", URL: hookServer.URL}}}
+	old, err := NewWatcher("main", client, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.scanStates = map[string]string{"s1": "processing", "s2": "processing", "s3": "processing"}
+	old.primed = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go old.Run(ctx)
+	for i := 0; i < webhookWorkers; i++ {
+		<-requested
+	}
+
+	// 与reloader.apply相同：旧监听器停止轮询，新监听器接管基线
+	old.Stop()
+	w, err := NewWatcher("main", client, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Inherit(old)
+	close(release)
+
+	deadline := time.After(5 * time.Second)
+	for {
+		mu.Lock()
+		done := len(delivered) == 3
+		mu.Unlock()
+		if done {
+			return
+		}
+		select {
+		case <-deadline:
+			mu.Lock()
+			defer mu.Unlock()
+			t.Fatalf("delivered = %v, want s1, s2 and s3", delivered)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
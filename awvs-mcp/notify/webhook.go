@@ -0,0 +1,274 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/taoing/awvs-mcp/metrics"
+	"github.com/taoing/awvs-mcp/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Webhook类型常量
+const (
+	TypeGeneric  = "generic"  // 通用JSON
+	TypeSlack    = "slack"    // Slack Incoming Webhook
+	TypeDingTalk = "dingtalk" // 钉钉机器人
+	TypeFeishu   = "feishu"   // 飞书机器人
+)
+
+// 默认重试次数
+const defaultMaxRetries = 3
+
+// 每个接收端的投递并发数和待投递队列长度，避免事件突增时无限创建goroutine
+// 队列满时最多等待webhookEnqueueTimeout，接收端长时间不可用时才丢弃事件
+const (
+	webhookWorkers        = 2
+	webhookQueueSize      = 100
+	webhookEnqueueTimeout = 30 * time.Second
+)
+
+// WebhookConfig 表示一个Webhook接收端
+type WebhookConfig struct {
+	Name       string   // 名称，仅用于日志
+	URL        string   // 接收地址
+	Type       string   // 消息格式
+	Secret     string   // 签名密钥，为空时不签名
+	Events     []string // 订阅的事件，为空时订阅全部事件
+	MaxRetries *int     // 失败后的最大重试次数，为nil时使用默认值，0表示不重试
+}
+
+// webhook 负责向单个接收端投递事件
+type webhook struct {
+	config  WebhookConfig
+	retries int // 失败后的最大重试次数
+	httpCli *http.Client
+	queue   chan Event // 待投递事件
+}
+
+func newWebhook(config WebhookConfig) *webhook {
+	if config.Type == "" {
+		config.Type = TypeGeneric
+	}
+	retries := defaultMaxRetries
+	if config.MaxRetries != nil && *config.MaxRetries >= 0 {
+		retries = *config.MaxRetries
+	}
+	return &webhook{
+		config:  config,
+		retries: retries,
+		httpCli: &http.Client{Timeout: 10 * time.Second},
+		queue:   make(chan Event, webhookQueueSize),
+	}
+}
+
+// start 启动投递协程。队列关闭后协程投递完剩余事件再退出；
+// ctx取消后协程立即退出，进行中的重试随之中止
+func (w *webhook) start(ctx context.Context) {
+	for i := 0; i < webhookWorkers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case event, ok := <-w.queue:
+					if !ok {
+						return
+					}
+					if err := w.send(ctx, event); err != nil {
+						log.Printf("Webhook %s 投递事件 %s 失败: %v", w.config.Name, event.Type, err)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// close 关闭待投递队列，调用后不能再调用enqueue
+func (w *webhook) close() {
+	close(w.queue)
+}
+
+// enqueue 将事件放入待投递队列，队列已满时等待投递协程腾出位置，
+// 超过timeout仍已满时丢弃并记录日志
+func (w *webhook) enqueue(event Event, timeout time.Duration) {
+	select {
+	case w.queue <- event:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case w.queue <- event:
+	case <-timer.C:
+		log.Printf("Webhook %s 待投递队列已满，等待 %s 后丢弃事件 %s", w.config.Name, timeout, event.Type)
+		metrics.ObserveWebhook(w.config.Name, fmt.Errorf("queue full"))
+	}
+}
+
+// subscribed 判断接收端是否订阅了事件
+func (w *webhook) subscribed(eventType string) bool {
+	if len(w.config.Events) == 0 {
+		return true
+	}
+	for _, e := range w.config.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// send 投递事件，首次失败后按指数退避最多重试retries次，ctx取消时立即放弃
+func (w *webhook) send(ctx context.Context, event Event) error {
+	ctx, span := tracing.Tracer().Start(ctx, "webhook "+w.config.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("webhook.name", w.config.Name),
+			attribute.String("webhook.type", w.config.Type),
+			attribute.String("awvs.instance", event.Instance),
+			attribute.String("event.type", event.Type),
+		),
+	)
+	defer span.End()
+
+	var lastErr error
+	for attempt := 0; attempt <= w.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				lastErr = ctx.Err()
+				span.RecordError(lastErr)
+				span.SetStatus(codes.Error, lastErr.Error())
+				return lastErr
+			case <-time.After(time.Duration(1<<(attempt-1)) * time.Second):
+			}
+			metrics.ObserveWebhookRetry(w.config.Name)
+		}
+		span.SetAttributes(attribute.Int("http.request.resend_count", attempt))
+
+		retry, err := w.deliver(ctx, event)
+		if err == nil {
+			metrics.ObserveWebhook(w.config.Name, nil)
+			return nil
+		}
+		lastErr = err
+		log.Printf("Webhook %s 第%d次投递失败: %v", w.config.Name, attempt+1, err)
+		if !retry {
+			break
+		}
+	}
+	span.RecordError(lastErr)
+	span.SetStatus(codes.Error, lastErr.Error())
+	metrics.ObserveWebhook(w.config.Name, lastErr)
+	return lastErr
+}
+
+// deliver 执行一次投递，返回失败时是否值得重试
+func (w *webhook) deliver(ctx context.Context, event Event) (bool, error) {
+	body, target, err := w.payload(event)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// 通用格式使用请求头携带HMAC签名，接收端可用同一密钥校验
+	if w.config.Type == TypeGeneric && w.config.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(w.config.Secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		req.Header.Set("X-AWVS-MCP-Timestamp", timestamp)
+		req.Header.Set("X-AWVS-MCP-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.httpCli.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("execute request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return false, nil
+}
+
+// payload 按接收端类型构建请求体和请求地址
+func (w *webhook) payload(event Event) ([]byte, string, error) {
+	var body interface{}
+	target := w.config.URL
+
+	switch w.config.Type {
+	case TypeGeneric:
+		body = event
+	case TypeSlack:
+		body = map[string]string{"text": event.Message}
+	case TypeDingTalk:
+		body = map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": event.Message},
+		}
+		// 钉钉加签：签名放在URL参数中
+		if w.config.Secret != "" {
+			timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			mac := hmac.New(sha256.New, []byte(w.config.Secret))
+			mac.Write([]byte(timestamp + "\n" + w.config.Secret))
+			sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+			u, err := url.Parse(target)
+			if err != nil {
+				return nil, "", fmt.Errorf("parse webhook url failed: %w", err)
+			}
+			q := u.Query()
+			q.Set("timestamp", timestamp)
+			q.Set("sign", sign)
+			u.RawQuery = q.Encode()
+			target = u.String()
+		}
+	case TypeFeishu:
+		msg := map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": event.Message},
+		}
+		// 飞书加签：以"timestamp\nsecret"为密钥对空串签名，签名放在请求体中
+		if w.config.Secret != "" {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			mac := hmac.New(sha256.New, []byte(timestamp+"\n"+w.config.Secret))
+			msg["timestamp"] = timestamp
+			msg["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		}
+		body = msg
+	default:
+		return nil, "", fmt.Errorf("unsupported webhook type: %s", w.config.Type)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshal webhook payload failed: %w", err)
+	}
+	return data, target, nil
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func intPtr(v int) *int { return &v }
+
+// max_retries表示首次失败后的重试次数，0表示只投递一次
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries *int
+		want       int32
+	}{
+		{"no retries", intPtr(0), 1},
+		{"one retry", intPtr(1), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			w := newWebhook(WebhookConfig{Name: "test", URL: server.URL, MaxRetries: tt.maxRetries})
+			if err := w.send(context.Background(), Event{Type: EventScanCompleted}); err == nil {
+				t.Fatal("send succeeded, want error")
+			}
+			if got := atomic.LoadInt32(&attempts); got != tt.want {
+				t.Errorf("attempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if w := newWebhook(WebhookConfig{Name: "default"}); w.retries != defaultMaxRetries {
+		t.Errorf("default retries = %d, want %d", w.retries, defaultMaxRetries)
+	}
+}
+
+// 队列满时等待投递协程腾出位置，超时后才丢弃事件
+func TestWebhookEnqueueWaitsForSpace(t *testing.T) {
+	w := newWebhook(WebhookConfig{Name: "test", URL: "http://127.0.0.1"})
+	for i := 0; i < webhookQueueSize; i++ {
+		w.enqueue(Event{Type: EventScanStarted}, time.Second)
+	}
+
+	// 超时前没有腾出位置，事件被丢弃
+	w.enqueue(Event{Type: EventScanFailed}, 10*time.Millisecond)
+	if len(w.queue) != webhookQueueSize {
+		t.Fatalf("queue length = %d, want %d", len(w.queue), webhookQueueSize)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.enqueue(Event{Type: EventScanCompleted}, 5*time.Second)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("enqueue returned while the queue was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-w.queue
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("enqueue did not return after space was freed")
+	}
+
+	var last Event
+	for len(w.queue) > 0 {
+		last = <-w.queue
+	}
+	if last.Type != EventScanCompleted {
+		t.Errorf("last queued event = %s, want %s", last.Type, EventScanCompleted)
+	}
+}
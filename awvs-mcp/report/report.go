@@ -0,0 +1,319 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// 报告格式常量
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// 模板文件名，用户可在模板目录中放置同名文件覆盖默认模板
+const (
+	markdownTemplateName = "report.md.tmpl"
+	htmlTemplateName     = "report.html.tmpl"
+)
+
+// 默认展示的高频问题数量
+const defaultTopIssues = 10
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// SeverityCount 表示某个严重级别的漏洞数量
+type SeverityCount struct {
+	Name    string
+	Count   int
+	Percent float64 // 占全部漏洞的百分比，用于绘制图表
+}
+
+// Issue 表示按漏洞类型聚合的问题
+type Issue struct {
+	Name     string
+	Severity string
+	Count    int
+	Targets  int // 受影响的目标数量
+}
+
+// TargetSummary 表示单个目标的漏洞统计
+type TargetSummary struct {
+	TargetID   string
+	Address    string
+	Scans      int
+	Severities []SeverityCount
+	Total      int
+}
+
+// Summary 表示渲染报告所需的汇总数据
+type Summary struct {
+	Title           string
+	GeneratedAt     time.Time
+	TargetCount     int
+	ScanCount       int
+	Total           int
+	Severities      []SeverityCount
+	TopIssues       []Issue
+	Targets         []TargetSummary
+	Vulnerabilities []awvs.Vulnerability
+}
+
+// 报告中展示的严重级别顺序，从高到低
+var severityOrder = []int{
+	awvs.SeverityCritical,
+	awvs.SeverityHigh,
+	awvs.SeverityMedium,
+	awvs.SeverityLow,
+	awvs.SeverityInfo,
+}
+
+// Collect 从AWVS拉取目标、扫描和漏洞数据并生成汇总，targetID为空时汇总全部目标
+func Collect(client *awvs.Client, targetID string) (*Summary, error) {
+	// 逐页拉取全部目标和扫描，大规模部署时不会只统计第一页
+	targets, err := client.ListAllTargets("")
+	if err != nil {
+		return nil, err
+	}
+
+	scans, err := client.ListAllScans("")
+	if err != nil {
+		return nil, err
+	}
+
+	query := ""
+	if targetID != "" {
+		query = "target_id:" + targetID
+	}
+	vulns, err := client.ListVulnerabilities(query)
+	if err != nil {
+		return nil, fmt.Errorf("list vulnerabilities failed: %w", err)
+	}
+
+	if targetID != "" {
+		targets = filterTargets(targets, targetID)
+		scans = filterScans(scans, targetID)
+	}
+
+	return Summarize(targets, scans, vulns), nil
+}
+
+// Summarize 根据目标、扫描和漏洞数据计算汇总信息
+func Summarize(targets []awvs.Target, scans []awvs.Scan, vulns []awvs.Vulnerability) *Summary {
+	summary := &Summary{
+		Title:           "AWVS 漏洞扫描报告",
+		GeneratedAt:     time.Now(),
+		TargetCount:     len(targets),
+		ScanCount:       len(scans),
+		Total:           len(vulns),
+		Vulnerabilities: vulns,
+	}
+
+	// 统计全局严重级别分布
+	counts := make(map[int]int)
+	for _, v := range vulns {
+		counts[v.Severity]++
+	}
+	summary.Severities = severityCounts(counts, len(vulns))
+
+	// 按漏洞类型聚合高频问题
+	type issueKey struct {
+		name     string
+		severity int
+	}
+	issues := make(map[issueKey]*Issue)
+	issueTargets := make(map[issueKey]map[string]bool)
+	for _, v := range vulns {
+		key := issueKey{name: v.VtName, severity: v.Severity}
+		issue, ok := issues[key]
+		if !ok {
+			issue = &Issue{Name: v.VtName, Severity: awvs.SeverityName(v.Severity)}
+			issues[key] = issue
+			issueTargets[key] = make(map[string]bool)
+		}
+		issue.Count++
+		issueTargets[key][v.TargetID] = true
+	}
+	keys := make([]issueKey, 0, len(issues))
+	for key, issue := range issues {
+		issue.Targets = len(issueTargets[key])
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].severity != keys[j].severity {
+			return keys[i].severity > keys[j].severity
+		}
+		if issues[keys[i]].Count != issues[keys[j]].Count {
+			return issues[keys[i]].Count > issues[keys[j]].Count
+		}
+		return keys[i].name < keys[j].name
+	})
+	for i, key := range keys {
+		if i >= defaultTopIssues {
+			break
+		}
+		summary.TopIssues = append(summary.TopIssues, *issues[key])
+	}
+
+	// 按目标统计
+	scanCounts := make(map[string]int)
+	for _, s := range scans {
+		scanCounts[s.TargetID]++
+	}
+	targetCounts := make(map[string]map[int]int)
+	targetTotals := make(map[string]int)
+	for _, v := range vulns {
+		if targetCounts[v.TargetID] == nil {
+			targetCounts[v.TargetID] = make(map[int]int)
+		}
+		targetCounts[v.TargetID][v.Severity]++
+		targetTotals[v.TargetID]++
+	}
+	for _, t := range targets {
+		summary.Targets = append(summary.Targets, TargetSummary{
+			TargetID:   t.TargetID,
+			Address:    t.Address,
+			Scans:      scanCounts[t.TargetID],
+			Severities: severityCounts(targetCounts[t.TargetID], targetTotals[t.TargetID]),
+			Total:      targetTotals[t.TargetID],
+		})
+	}
+	sort.SliceStable(summary.Targets, func(i, j int) bool {
+		return summary.Targets[i].Total > summary.Targets[j].Total
+	})
+
+	return summary
+}
+
+// severityCounts 按固定顺序生成严重级别统计
+func severityCounts(counts map[int]int, total int) []SeverityCount {
+	result := make([]SeverityCount, 0, len(severityOrder))
+	for _, severity := range severityOrder {
+		count := counts[severity]
+		percent := 0.0
+		if total > 0 {
+			percent = float64(count) * 100 / float64(total)
+		}
+		result = append(result, SeverityCount{
+			Name:    awvs.SeverityName(severity),
+			Count:   count,
+			Percent: percent,
+		})
+	}
+	return result
+}
+
+func filterTargets(targets []awvs.Target, targetID string) []awvs.Target {
+	var result []awvs.Target
+	for _, t := range targets {
+		if t.TargetID == targetID {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func filterScans(scans []awvs.Scan, targetID string) []awvs.Scan {
+	var result []awvs.Scan
+	for _, s := range scans {
+		if s.TargetID == targetID {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Renderer 基于Go模板渲染报告
+type Renderer struct {
+	markdown *texttemplate.Template
+	html     *htmltemplate.Template
+}
+
+// 模板中可用的辅助函数
+var funcMap = map[string]interface{}{
+	"upper": strings.ToUpper,
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"percent": func(p float64) string {
+		return fmt.Sprintf("%.1f%%", p)
+	},
+	"bar": func(p float64) string {
+		// Markdown中使用字符绘制简单的条形图
+		return strings.Repeat("█", int(p/5+0.5))
+	},
+	"cell": markdownCell,
+}
+
+// markdownCellReplacer 转义会破坏Markdown表格的字符
+var markdownCellReplacer = strings.NewReplacer("|", "\\|", "\r\n", " ", "\n", " ", "\r", " ")
+
+// markdownCell 转义写入Markdown表格单元格的文本
+func markdownCell(s string) string {
+	return markdownCellReplacer.Replace(s)
+}
+
+// NewRenderer 创建报告渲染器，templateDir不为空时优先使用其中的同名模板
+func NewRenderer(templateDir string) (*Renderer, error) {
+	mdSource, err := loadTemplate(templateDir, markdownTemplateName)
+	if err != nil {
+		return nil, err
+	}
+	md, err := texttemplate.New(markdownTemplateName).Funcs(funcMap).Parse(mdSource)
+	if err != nil {
+		return nil, fmt.Errorf("parse markdown template failed: %w", err)
+	}
+
+	htmlSource, err := loadTemplate(templateDir, htmlTemplateName)
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.New(htmlTemplateName).Funcs(funcMap).Parse(htmlSource)
+	if err != nil {
+		return nil, fmt.Errorf("parse html template failed: %w", err)
+	}
+
+	return &Renderer{markdown: md, html: html}, nil
+}
+
+// loadTemplate 读取模板内容，用户模板不存在时回退到内置模板
+func loadTemplate(templateDir, name string) (string, error) {
+	if templateDir != "" {
+		data, err := os.ReadFile(filepath.Join(templateDir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("read template %s failed: %w", name, err)
+		}
+	}
+
+	data, err := defaultTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", fmt.Errorf("read default template %s failed: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// Render 按指定格式渲染报告
+func (r *Renderer) Render(w io.Writer, format string, summary *Summary) error {
+	switch format {
+	case FormatMarkdown, "md", "":
+		return r.markdown.Execute(w, summary)
+	case FormatHTML:
+		return r.html.Execute(w, summary)
+	default:
+		return fmt.Errorf("unsupported report format: %s", format)
+	}
+}
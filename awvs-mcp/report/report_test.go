@@ -0,0 +1,96 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+func testSummary() *Summary {
+	targets := []awvs.Target{{TargetID: "t1", Address: "https://a.example.com"}}
+	scans := []awvs.Scan{{ScanID: "s1", TargetID: "t1"}}
+	vulns := []awvs.Vulnerability{
+		{VulnID: "v1", TargetID: "t1", Severity: awvs.SeverityHigh, VtName: "SQL Injection"},
+		{VulnID: "v2", TargetID: "t1", Severity: awvs.SeverityMedium, VtName: "<script>|XSS"},
+	}
+	return Summarize(targets, scans, vulns)
+}
+
+// 未指定模板目录时使用内置模板，并转义表格和HTML中的特殊字符
+func TestRenderDefaultTemplates(t *testing.T) {
+	renderer, err := NewRenderer("")
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	summary := testSummary()
+
+	var md strings.Builder
+	if err := renderer.Render(&md, FormatMarkdown, summary); err != nil {
+		t.Fatalf("Render markdown: %v", err)
+	}
+	for _, want := range []string{"# AWVS 漏洞扫描报告", "SQL Injection", `<script>\|XSS`, "https://a.example.com"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown report missing %q:\n%s", want, md.String())
+		}
+	}
+
+	var html strings.Builder
+	if err := renderer.Render(&html, FormatHTML, summary); err != nil {
+		t.Fatalf("Render html: %v", err)
+	}
+	if strings.Contains(html.String(), "<script>|XSS") {
+		t.Error("html report contains unescaped vulnerability name")
+	}
+	if !strings.Contains(html.String(), "&lt;script&gt;") {
+		t.Errorf("html report missing escaped vulnerability name:\n%s", html.String())
+	}
+
+	if err := renderer.Render(&md, "pdf", summary); err == nil {
+		t.Error("Render with unsupported format succeeded, want error")
+	}
+}
+
+// 模板目录中的同名文件覆盖内置模板，缺失的模板回退到内置版本
+func TestRenderOverrideTemplate(t *testing.T) {
+	dir := t.TempDir()
+	custom := "custom {{.Title}} total={{.Total}}"
+	if err := os.WriteFile(filepath.Join(dir, markdownTemplateName), []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	renderer, err := NewRenderer(dir)
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	summary := testSummary()
+
+	var md strings.Builder
+	if err := renderer.Render(&md, FormatMarkdown, summary); err != nil {
+		t.Fatalf("Render markdown: %v", err)
+	}
+	if got, want := md.String(), "custom AWVS 漏洞扫描报告 total=2"; got != want {
+		t.Errorf("markdown = %q, want %q", got, want)
+	}
+
+	var html strings.Builder
+	if err := renderer.Render(&html, FormatHTML, summary); err != nil {
+		t.Fatalf("Render html: %v", err)
+	}
+	if !strings.Contains(html.String(), "SQL Injection") {
+		t.Errorf("html report did not fall back to the default template:\n%s", html.String())
+	}
+}
+
+// 用户模板语法错误时返回错误而不是静默回退
+func TestRenderInvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, htmlTemplateName), []byte("{{.Title"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRenderer(dir); err == nil {
+		t.Fatal("NewRenderer with broken template succeeded, want error")
+	}
+}
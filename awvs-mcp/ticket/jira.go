@@ -0,0 +1,139 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// jiraClient 兼容Jira REST API v2的最小客户端
+type jiraClient struct {
+	baseURL  string
+	username string
+	token    string
+	httpCli  *http.Client
+}
+
+// Issue 表示创建成功的工单
+type Issue struct {
+	ID   string `json:"id"`
+	Key  string `json:"key"`
+	Self string `json:"self"`
+}
+
+type transition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		Name string `json:"name"`
+	} `json:"to"`
+}
+
+type transitionsResponse struct {
+	Transitions []transition `json:"transitions"`
+}
+
+func newJiraClient(baseURL, username, token string) *jiraClient {
+	return &jiraClient{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		username: username,
+		token:    token,
+		httpCli:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// request 执行Jira API请求，ctx取消时请求随之中止
+func (j *jiraClient) request(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request body failed: %w", err)
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, j.baseURL+path, bodyReader)
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	// 配置了用户名时使用Basic认证（Jira Cloud），否则使用Bearer令牌（Jira Server/DC个人访问令牌）
+	if j.username != "" {
+		req.SetBasicAuth(j.username, j.token)
+	} else if j.token != "" {
+		req.Header.Set("Authorization", "Bearer "+j.token)
+	}
+
+	resp, err := j.httpCli.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response body failed: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("unmarshal response failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// createIssue 创建工单
+func (j *jiraClient) createIssue(ctx context.Context, fields map[string]interface{}) (*Issue, error) {
+	var issue Issue
+	if err := j.request(ctx, http.MethodPost, "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &issue); err != nil {
+		return nil, fmt.Errorf("create issue failed: %w", err)
+	}
+	return &issue, nil
+}
+
+// transition 将工单流转到指定状态，name可以是流转名称或目标状态名称
+func (j *jiraClient) transition(ctx context.Context, key, name string) error {
+	path := fmt.Sprintf("/rest/api/2/issue/%s/transitions", url.PathEscape(key))
+
+	var resp transitionsResponse
+	if err := j.request(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return fmt.Errorf("get transitions failed: %w", err)
+	}
+
+	for _, t := range resp.Transitions {
+		if strings.EqualFold(t.Name, name) || strings.EqualFold(t.To.Name, name) {
+			body := map[string]interface{}{
+				"transition": map[string]string{"id": t.ID},
+			}
+			if err := j.request(ctx, http.MethodPost, path, body, nil); err != nil {
+				return fmt.Errorf("transition issue failed: %w", err)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("transition %q not available for issue %s", name, key)
+}
+
+// addComment 为工单添加评论
+func (j *jiraClient) addComment(ctx context.Context, key, comment string) error {
+	path := fmt.Sprintf("/rest/api/2/issue/%s/comment", url.PathEscape(key))
+	if err := j.request(ctx, http.MethodPost, path, map[string]string{"body": comment}, nil); err != nil {
+		return fmt.Errorf("add comment failed: %w", err)
+	}
+	return nil
+}
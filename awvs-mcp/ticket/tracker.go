@@ -0,0 +1,406 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// 漏洞修复后在AWVS中的状态
+const vulnStatusFixed = "fixed"
+
+// 工单状态
+const (
+	StatusOpen     = "open"
+	StatusResolved = "resolved"
+)
+
+// 默认严重级别到优先级的映射
+var defaultPriorityMap = map[string]string{
+	"critical": "Highest",
+	"high":     "High",
+	"medium":   "Medium",
+	"low":      "Low",
+	"info":     "Lowest",
+}
+
+// Config 工单系统配置
+type Config struct {
+	BaseURL        string            // Jira兼容API地址
+	Username       string            // 用户名，为空时使用Bearer令牌认证
+	APIToken       string            // API令牌
+	Project        string            // 项目Key
+	IssueType      string            // 工单类型
+	PriorityMap    map[string]string // 严重级别到优先级的映射
+	FieldMap       map[string]string // 自定义字段到漏洞属性的映射
+	Labels         []string          // 创建工单时附加的标签
+	DoneTransition string            // 漏洞修复后使用的流转名称
+	StateFile      string            // 已创建工单记录文件
+}
+
+// Record 表示一条漏洞与工单的关联记录
+type Record struct {
+	Instance  string    `json:"instance,omitempty"`
+	VulnID    string    `json:"vuln_id"`
+	TargetID  string    `json:"target_id"`
+	VtName    string    `json:"vt_name"`
+	IssueKey  string    `json:"issue_key"`
+	Status    string    `json:"status"`
+	Commented bool      `json:"commented,omitempty"` // 已添加修复评论
+	CreatedAt time.Time `json:"created_at"`
+	SyncedAt  time.Time `json:"synced_at,omitempty"`
+}
+
+// Result 表示单个漏洞的建单结果
+type Result struct {
+	VulnID   string `json:"vuln_id"`
+	IssueKey string `json:"issue_key,omitempty"`
+	Created  bool   `json:"created"`
+	Error    string `json:"error,omitempty"`
+}
+
+// SyncResult 表示单个工单的状态同步结果
+type SyncResult struct {
+	VulnID   string `json:"vuln_id"`
+	IssueKey string `json:"issue_key"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Tracker 负责根据AWVS漏洞创建工单并同步状态
+type Tracker struct {
+	config *Config
+	pool   *awvs.Pool
+	jira   *jiraClient
+
+	mu      sync.Mutex
+	records map[string]*Record       // 以漏洞指纹为键
+	filing  map[string]chan struct{} // 正在建单的漏洞指纹，建单结束后关闭
+}
+
+// NewTracker 创建工单跟踪器并加载已创建工单记录
+func NewTracker(config *Config, pool *awvs.Pool) (*Tracker, error) {
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("tracker base_url is required")
+	}
+	if config.Project == "" {
+		return nil, fmt.Errorf("tracker project is required")
+	}
+	if config.IssueType == "" {
+		config.IssueType = "Bug"
+	}
+	if config.DoneTransition == "" {
+		config.DoneTransition = "Done"
+	}
+	if config.StateFile == "" {
+		config.StateFile = "tickets.json"
+	}
+
+	t := &Tracker{
+		config:  config,
+		pool:    pool,
+		jira:    newJiraClient(config.BaseURL, config.Username, config.APIToken),
+		records: make(map[string]*Record),
+		filing:  make(map[string]chan struct{}),
+	}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// fingerprint 生成漏洞指纹，同一目标同一位置的同类漏洞视为重复
+func fingerprint(v *awvs.Vulnerability) string {
+	return strings.Join([]string{v.TargetID, v.VtID, v.AffectsURL, v.AffectsDetail}, "|")
+}
+
+// FileTickets 为指定实例上的漏洞创建工单，已建单的漏洞直接返回已有工单，
+// 已修复关闭后再次出现的漏洞会重新建单，已修复且未建单的漏洞不建单
+func (t *Tracker) FileTickets(ctx context.Context, instance string, vulnIDs []string) ([]Result, error) {
+	instance, client, err := t.pool.Resolve(instance)
+	if err != nil {
+		return nil, err
+	}
+	client = client.WithContext(ctx)
+
+	results := make([]Result, 0, len(vulnIDs))
+	for _, id := range vulnIDs {
+		results = append(results, t.fileTicket(ctx, instance, client, id))
+	}
+	return results, nil
+}
+
+// fileTicket 为单个漏洞建单。调用工单系统时不持有锁，只在锁内占用漏洞指纹，
+// 工单系统响应慢时不会阻塞其他漏洞的建单和状态同步
+func (t *Tracker) fileTicket(ctx context.Context, instance string, client *awvs.Client, vulnID string) Result {
+	result := Result{VulnID: vulnID}
+
+	vuln, err := client.GetVulnerability(vulnID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	key := fingerprint(vuln)
+	t.mu.Lock()
+	// 同一漏洞正在建单时等待其完成，再按建单结果去重
+	for {
+		done, ok := t.filing[key]
+		if !ok {
+			break
+		}
+		t.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			result.Error = ctx.Err().Error()
+			return result
+		}
+		t.mu.Lock()
+	}
+
+	fields := t.issueFields(vuln)
+	record, ok := t.records[key]
+	switch {
+	case ok && (record.Status != StatusResolved || vuln.Status == vulnStatusFixed):
+		t.mu.Unlock()
+		result.IssueKey = record.IssueKey
+		return result
+	case ok:
+		// 工单已因修复关闭而漏洞再次出现时视为回归，重新建单
+		fields["description"] = fmt.Sprintf("%s\n该漏洞曾在工单 %s 中修复，复测时再次出现。\n", fields["description"], record.IssueKey)
+	case vuln.Status == vulnStatusFixed:
+		t.mu.Unlock()
+		result.Error = "vulnerability is already fixed, no ticket filed"
+		return result
+	}
+	done := make(chan struct{})
+	t.filing[key] = done
+	t.mu.Unlock()
+
+	issue, err := t.jira.createIssue(ctx, fields)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.filing, key)
+	close(done)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	t.records[key] = &Record{
+		Instance:  instance,
+		VulnID:    vuln.VulnID,
+		TargetID:  vuln.TargetID,
+		VtName:    vuln.VtName,
+		IssueKey:  issue.Key,
+		Status:    StatusOpen,
+		CreatedAt: time.Now(),
+	}
+	if err := t.save(); err != nil {
+		result.Error = err.Error()
+	}
+
+	result.IssueKey = issue.Key
+	result.Created = true
+	return result
+}
+
+// issueFields 根据漏洞构建工单字段
+func (t *Tracker) issueFields(v *awvs.Vulnerability) map[string]interface{} {
+	severity := awvs.SeverityName(v.Severity)
+
+	priorityMap := t.config.PriorityMap
+	if len(priorityMap) == 0 {
+		priorityMap = defaultPriorityMap
+	}
+
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": t.config.Project},
+		"issuetype":   map[string]string{"name": t.config.IssueType},
+		"summary":     fmt.Sprintf("[AWVS][%s] %s - %s", strings.ToUpper(severity), v.VtName, v.AffectsURL),
+		"description": description(v),
+		"labels":      append([]string{"awvs"}, t.config.Labels...),
+	}
+	if priority, ok := priorityMap[severity]; ok {
+		fields["priority"] = map[string]string{"name": priority}
+	}
+	for field, attr := range t.config.FieldMap {
+		fields[field] = vulnAttribute(v, attr)
+	}
+
+	return fields
+}
+
+// description 生成工单描述（Jira wiki格式）
+func description(v *awvs.Vulnerability) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*漏洞名称*: %s\n", v.VtName)
+	fmt.Fprintf(&b, "*严重级别*: %s\n", awvs.SeverityName(v.Severity))
+	fmt.Fprintf(&b, "*影响地址*: %s\n", v.AffectsURL)
+	if v.AffectsDetail != "" {
+		fmt.Fprintf(&b, "*影响参数*: %s\n", v.AffectsDetail)
+	}
+	fmt.Fprintf(&b, "*置信度*: %d\n", v.Confidence)
+	fmt.Fprintf(&b, "*最后发现*: %s\n", v.LastSeen)
+	fmt.Fprintf(&b, "\nAWVS 漏洞ID: %s，目标ID: %s\n", v.VulnID, v.TargetID)
+	return b.String()
+}
+
+// vulnAttribute 返回字段映射中引用的漏洞属性
+func vulnAttribute(v *awvs.Vulnerability, attr string) interface{} {
+	switch attr {
+	case "vuln_id":
+		return v.VulnID
+	case "target_id":
+		return v.TargetID
+	case "vt_id":
+		return v.VtID
+	case "vt_name":
+		return v.VtName
+	case "severity":
+		return awvs.SeverityName(v.Severity)
+	case "confidence":
+		return v.Confidence
+	case "affects_url":
+		return v.AffectsURL
+	case "affects_detail":
+		return v.AffectsDetail
+	case "last_seen":
+		return v.LastSeen
+	default:
+		// 未知属性按字面值写入，便于设置固定值
+		return attr
+	}
+}
+
+// Sync 检查已建单漏洞在AWVS中的状态，已修复的漏洞将对应工单流转为完成
+// 查询AWVS和调用工单系统时不持有锁，避免同步期间阻塞建单
+func (t *Tracker) Sync(ctx context.Context) []SyncResult {
+	t.mu.Lock()
+	pending := make(map[string]Record)
+	for key, record := range t.records {
+		if record.Status == StatusOpen {
+			pending[key] = *record
+		}
+	}
+	t.mu.Unlock()
+
+	var results []SyncResult
+	updates := make(map[string]Record)
+	for key, record := range pending {
+		result := SyncResult{VulnID: record.VulnID, IssueKey: record.IssueKey, Status: record.Status}
+		client, err := t.pool.Get(record.Instance)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		vuln, err := client.WithContext(ctx).GetVulnerability(record.VulnID)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		if vuln.Status != vulnStatusFixed {
+			results = append(results, result)
+			continue
+		}
+
+		// 评论只添加一次，流转失败后再次同步时不会重复评论
+		if !record.Commented {
+			comment := fmt.Sprintf("AWVS 复测确认漏洞已修复（最后发现时间 %s），自动关闭。", vuln.LastSeen)
+			if err := t.jira.addComment(ctx, record.IssueKey, comment); err != nil {
+				result.Error = err.Error()
+				results = append(results, result)
+				continue
+			}
+			record.Commented = true
+			updates[key] = record
+		}
+		if err := t.jira.transition(ctx, record.IssueKey, t.config.DoneTransition); err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		record.Status = StatusResolved
+		record.SyncedAt = time.Now()
+		updates[key] = record
+		result.Status = StatusResolved
+		results = append(results, result)
+	}
+
+	if len(updates) > 0 {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for key, record := range updates {
+			if existing, ok := t.records[key]; ok {
+				existing.Commented = record.Commented
+				existing.Status = record.Status
+				existing.SyncedAt = record.SyncedAt
+			}
+		}
+		if err := t.save(); err != nil {
+			results = append(results, SyncResult{Error: err.Error()})
+		}
+	}
+	return results
+}
+
+// Records 返回所有已创建工单记录
+func (t *Tracker) Records() []Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	records := make([]Record, 0, len(t.records))
+	for _, record := range t.records {
+		records = append(records, *record)
+	}
+	return records
+}
+
+// load 从记录文件加载已创建工单
+func (t *Tracker) load() error {
+	data, err := os.ReadFile(t.config.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read ticket state failed: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.records); err != nil {
+		return fmt.Errorf("parse ticket state failed: %w", err)
+	}
+	return nil
+}
+
+// save 将工单记录写入文件，先写临时文件再重命名以免写坏
+func (t *Tracker) save() error {
+	data, err := json.MarshalIndent(t.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal ticket state failed: %w", err)
+	}
+
+	tmp := t.config.StateFile + ".tmp"
+	if dir := filepath.Dir(t.config.StateFile); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("create ticket state dir failed: %w", err)
+		}
+	}
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write ticket state failed: %w", err)
+	}
+	if err := os.Rename(tmp, t.config.StateFile); err != nil {
+		return fmt.Errorf("write ticket state failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,378 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taoing/awvs-mcp/awvs"
+)
+
+// fakeJira 模拟Jira REST API v2中建单、评论和流转接口
+type fakeJira struct {
+	mu          sync.Mutex
+	issues      []map[string]interface{}
+	comments    map[string]int
+	transitions []string // 可用的流转名称
+	transited   map[string]string
+
+	// hold 不为空时第一个建单请求在arrived中通知后等待hold关闭或请求取消
+	hold    chan struct{}
+	arrived chan struct{}
+}
+
+func newFakeJira(transitions ...string) *fakeJira {
+	return &fakeJira{
+		comments:    make(map[string]int),
+		transitions: transitions,
+		transited:   make(map[string]string),
+	}
+}
+
+func (j *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue" {
+		j.mu.Lock()
+		hold := j.hold
+		j.hold = nil
+		j.mu.Unlock()
+		if hold != nil {
+			close(j.arrived)
+			select {
+			case <-hold:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/rest/api/2/issue")
+	switch {
+	case r.Method == http.MethodPost && path == "":
+		var body struct {
+			Fields map[string]interface{} `json:"fields"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		j.issues = append(j.issues, body.Fields)
+		key := fmt.Sprintf("SEC-%d", len(j.issues))
+		json.NewEncoder(w).Encode(Issue{ID: fmt.Sprint(len(j.issues)), Key: key})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/comment"):
+		j.comments[strings.Split(path, "/")[1]]++
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/transitions"):
+		var resp transitionsResponse
+		for i, name := range j.transitions {
+			t := transition{ID: fmt.Sprint(i + 1), Name: name}
+			t.To.Name = name
+			resp.Transitions = append(resp.Transitions, t)
+		}
+		json.NewEncoder(w).Encode(resp)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/transitions"):
+		var body struct {
+			Transition struct {
+				ID string `json:"id"`
+			} `json:"transition"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		j.transited[strings.Split(path, "/")[1]] = body.Transition.ID
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeAWVS 模拟AWVS漏洞详情接口
+type fakeAWVS struct {
+	mu    sync.Mutex
+	vulns map[string]*awvs.Vulnerability
+}
+
+func (a *fakeAWVS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/vulnerabilities/")
+	vuln, ok := a.vulns[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(vuln)
+}
+
+func (a *fakeAWVS) setStatus(id, status string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.vulns[id].Status = status
+}
+
+func newTestTracker(t *testing.T, jira *fakeJira) (*Tracker, *fakeAWVS) {
+	t.Helper()
+
+	scanner := &fakeAWVS{vulns: map[string]*awvs.Vulnerability{
+		"v1": {VulnID: "v1", TargetID: "t1", VtID: "xss", VtName: "XSS", Severity: 3, AffectsURL: "https://example.com/a", Status: "open"},
+		// 与v1同一目标同一位置的同类漏洞
+		"v2": {VulnID: "v2", TargetID: "t1", VtID: "xss", VtName: "XSS", Severity: 3, AffectsURL: "https://example.com/a", Status: "open"},
+		"v3": {VulnID: "v3", TargetID: "t1", VtID: "sqli", VtName: "SQL Injection", Severity: 4, AffectsURL: "https://example.com/b", Status: "open"},
+	}}
+	awvsServer := httptest.NewServer(scanner)
+	t.Cleanup(awvsServer.Close)
+	jiraServer := httptest.NewServer(jira)
+	t.Cleanup(jiraServer.Close)
+
+	client, err := awvs.NewClient(&awvs.Config{Name: "test", APIURL: awvsServer.URL, APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := awvs.NewPool()
+	if err := pool.Add(awvs.DefaultInstance, client); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker, err := NewTracker(&Config{
+		BaseURL:   jiraServer.URL,
+		APIToken:  "token",
+		Project:   "SEC",
+		StateFile: filepath.Join(t.TempDir(), "tickets.json"),
+	}, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tracker, scanner
+}
+
+func TestFileTicketsCreatesAndDeduplicates(t *testing.T) {
+	jira := newFakeJira("Done")
+	tracker, _ := newTestTracker(t, jira)
+
+	results, err := tracker.FileTickets(context.Background(), "", []string{"v1", "v2", "v3", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !results[0].Created || results[0].IssueKey != "SEC-1" {
+		t.Errorf("v1: got %+v, want created SEC-1", results[0])
+	}
+	if results[1].Created || results[1].IssueKey != "SEC-1" {
+		t.Errorf("v2: got %+v, want existing SEC-1", results[1])
+	}
+	if !results[2].Created || results[2].IssueKey != "SEC-2" {
+		t.Errorf("v3: got %+v, want created SEC-2", results[2])
+	}
+	if results[3].Error == "" {
+		t.Errorf("missing: got %+v, want an error", results[3])
+	}
+	if len(jira.issues) != 2 {
+		t.Fatalf("created %d issues, want 2", len(jira.issues))
+	}
+
+	fields := jira.issues[1]
+	if priority := fields["priority"].(map[string]interface{})["name"]; priority != "Highest" {
+		t.Errorf("priority = %v, want Highest", priority)
+	}
+	if project := fields["project"].(map[string]interface{})["key"]; project != "SEC" {
+		t.Errorf("project = %v, want SEC", project)
+	}
+
+	// 重新加载记录文件后仍然去重
+	reloaded, err := NewTracker(tracker.config, tracker.pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = reloaded.FileTickets(context.Background(), "", []string{"v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Created || results[0].IssueKey != "SEC-1" {
+		t.Errorf("after reload: got %+v, want existing SEC-1", results[0])
+	}
+}
+
+func TestSyncTransitionsFixedVulnerabilities(t *testing.T) {
+	jira := newFakeJira("In Progress", "Done")
+	tracker, scanner := newTestTracker(t, jira)
+
+	if _, err := tracker.FileTickets(context.Background(), "", []string{"v1", "v3"}); err != nil {
+		t.Fatal(err)
+	}
+	scanner.setStatus("v1", vulnStatusFixed)
+
+	results := tracker.Sync(context.Background())
+	status := make(map[string]string)
+	for _, result := range results {
+		if result.Error != "" {
+			t.Errorf("%s: unexpected error %s", result.VulnID, result.Error)
+		}
+		status[result.VulnID] = result.Status
+	}
+	if status["v1"] != StatusResolved || status["v3"] != StatusOpen {
+		t.Errorf("statuses = %v, want v1 resolved and v3 open", status)
+	}
+	if jira.transited["SEC-1"] != "2" {
+		t.Errorf("SEC-1 transition = %q, want 2 (Done)", jira.transited["SEC-1"])
+	}
+	if jira.comments["SEC-1"] != 1 || jira.comments["SEC-2"] != 0 {
+		t.Errorf("comments = %v, want one on SEC-1", jira.comments)
+	}
+
+	// 已关闭的工单不会再次同步
+	if results := tracker.Sync(context.Background()); len(results) != 1 {
+		t.Errorf("second sync returned %d results, want only v3", len(results))
+	}
+}
+
+func TestSyncDoesNotRepeatCommentWhenTransitionFails(t *testing.T) {
+	// 工作流中没有Done流转
+	jira := newFakeJira("In Progress")
+	tracker, scanner := newTestTracker(t, jira)
+
+	if _, err := tracker.FileTickets(context.Background(), "", []string{"v1"}); err != nil {
+		t.Fatal(err)
+	}
+	scanner.setStatus("v1", vulnStatusFixed)
+
+	for i := 0; i < 3; i++ {
+		results := tracker.Sync(context.Background())
+		if len(results) != 1 || results[0].Error == "" || results[0].Status != StatusOpen {
+			t.Fatalf("sync %d: got %+v, want an open ticket with a transition error", i, results)
+		}
+	}
+	if jira.comments["SEC-1"] != 1 {
+		t.Errorf("posted %d comments, want 1", jira.comments["SEC-1"])
+	}
+
+	// 工作流加上Done后可以完成同步
+	jira.mu.Lock()
+	jira.transitions = append(jira.transitions, "Done")
+	jira.mu.Unlock()
+	results := tracker.Sync(context.Background())
+	if len(results) != 1 || results[0].Status != StatusResolved {
+		t.Fatalf("got %+v, want resolved", results)
+	}
+	if jira.comments["SEC-1"] != 1 {
+		t.Errorf("posted %d comments, want 1", jira.comments["SEC-1"])
+	}
+}
+
+// 工单关闭后漏洞再次出现时重新建单，而不是返回已关闭的工单
+func TestFileTicketsRefilesRegressions(t *testing.T) {
+	jira := newFakeJira("Done")
+	tracker, scanner := newTestTracker(t, jira)
+
+	if _, err := tracker.FileTickets(context.Background(), "", []string{"v1"}); err != nil {
+		t.Fatal(err)
+	}
+	scanner.setStatus("v1", vulnStatusFixed)
+	if results := tracker.Sync(context.Background()); len(results) != 1 || results[0].Status != StatusResolved {
+		t.Fatalf("sync: got %+v, want resolved", results)
+	}
+
+	// 已修复的漏洞仍返回原工单
+	results, err := tracker.FileTickets(context.Background(), "", []string{"v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Created || results[0].IssueKey != "SEC-1" {
+		t.Errorf("fixed: got %+v, want existing SEC-1", results[0])
+	}
+
+	scanner.setStatus("v1", "open")
+	results, err = tracker.FileTickets(context.Background(), "", []string{"v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Created || results[0].IssueKey != "SEC-2" {
+		t.Errorf("regression: got %+v, want created SEC-2", results[0])
+	}
+	if desc, _ := jira.issues[1]["description"].(string); !strings.Contains(desc, "SEC-1") {
+		t.Errorf("description = %q, want a reference to SEC-1", desc)
+	}
+
+	// 新工单按未关闭状态继续同步
+	records := tracker.Records()
+	if len(records) != 1 || records[0].IssueKey != "SEC-2" || records[0].Status != StatusOpen {
+		t.Errorf("records = %+v, want one open SEC-2", records)
+	}
+}
+
+// 已修复且未建单的漏洞不建单
+func TestFileTicketsSkipsFixedVulnerabilities(t *testing.T) {
+	jira := newFakeJira("Done")
+	tracker, scanner := newTestTracker(t, jira)
+	scanner.setStatus("v3", vulnStatusFixed)
+
+	results, err := tracker.FileTickets(context.Background(), "", []string{"v3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Created || results[0].Error == "" {
+		t.Errorf("got %+v, want an error and no ticket", results[0])
+	}
+	if len(jira.issues) != 0 || len(tracker.Records()) != 0 {
+		t.Errorf("issues = %d, records = %d, want none", len(jira.issues), len(tracker.Records()))
+	}
+}
+
+// 工单系统响应慢时不阻塞其他漏洞的建单，取消调用时建单请求随之中止
+func TestFileTicketsDoesNotHoldLockDuringCreate(t *testing.T) {
+	jira := newFakeJira("Done")
+	hold := make(chan struct{})
+	jira.hold = hold
+	jira.arrived = make(chan struct{})
+	tracker, _ := newTestTracker(t, jira)
+	// 服务端读完请求体前察觉不到客户端断开，关闭服务器前放行被挂起的请求
+	t.Cleanup(func() { close(hold) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	slow := make(chan []Result)
+	go func() {
+		results, _ := tracker.FileTickets(ctx, "", []string{"v1"})
+		slow <- results
+	}()
+	<-jira.arrived
+
+	done := make(chan []Result)
+	go func() {
+		results, _ := tracker.FileTickets(context.Background(), "", []string{"v3"})
+		done <- results
+	}()
+	select {
+	case results := <-done:
+		if !results[0].Created {
+			t.Errorf("v3: got %+v, want created", results[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("filing v3 waited for the slow v1 request")
+	}
+
+	cancel()
+	select {
+	case results := <-slow:
+		if results[0].Created || results[0].Error == "" {
+			t.Errorf("canceled v1: got %+v, want an error", results[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceling did not abort the Jira request")
+	}
+
+	// 取消后不再占用指纹，可以重新建单
+	results, err := tracker.FileTickets(context.Background(), "", []string{"v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Created {
+		t.Errorf("v1 after cancel: got %+v, want created", results[0])
+	}
+}